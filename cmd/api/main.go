@@ -12,13 +12,21 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/config"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/jobs"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/logger"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/payments"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/storage"
 	_ "github.com/lib/pq"
 )
 
 var (
-	cfg config.Config
-	db  *sql.DB
+	cfg   config.Config
+	db    *sql.DB
+	store storage.Storage
+	rates fx.Provider
+	gate  payments.Provider
 )
 
 func main() {
@@ -59,6 +67,130 @@ func main() {
 	}
 	cfg.DB.MaxOpenConns = maxOpenConns
 
+	cfg.Storage.Backend = os.Getenv("STORAGE_BACKEND")
+	if cfg.Storage.Backend == "" {
+		cfg.Storage.Backend = "local"
+	}
+	cfg.Storage.UploadDir = os.Getenv("UPLOAD_DIR")
+	if cfg.Storage.UploadDir == "" {
+		cfg.Storage.UploadDir = "./uploads"
+	}
+	cfg.Storage.BaseURL = os.Getenv("UPLOAD_BASE_URL")
+	if cfg.Storage.BaseURL == "" {
+		cfg.Storage.BaseURL = "http://localhost:" + cfg.Port + "/uploads"
+	}
+	cfg.Storage.MaxUploadSize = 10 << 20
+	if v := os.Getenv("MAX_UPLOAD_SIZE"); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size <= 0 {
+			cfg.Logger.Error("Invalid MAX_UPLOAD_SIZE, using default", "Error", err)
+		} else {
+			cfg.Storage.MaxUploadSize = size
+		}
+	}
+	cfg.Storage.S3.Endpoint = os.Getenv("S3_ENDPOINT")
+	cfg.Storage.S3.Region = os.Getenv("S3_REGION")
+	cfg.Storage.S3.Bucket = os.Getenv("S3_BUCKET")
+	cfg.Storage.S3.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	cfg.Storage.S3.SecretKey = os.Getenv("S3_SECRET_KEY")
+	cfg.Storage.S3.PublicURL = os.Getenv("S3_PUBLIC_URL")
+
+	cfg.PropertyRetention = 30 * 24 * time.Hour
+	if v := os.Getenv("PROPERTY_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			cfg.Logger.Error("Invalid PROPERTY_RETENTION_DAYS, using default", "Error", err)
+		} else {
+			cfg.PropertyRetention = time.Duration(days) * 24 * time.Hour
+		}
+	}
+
+	cfg.ICal.RefreshInterval = 30 * time.Minute
+	if v := os.Getenv("ICAL_REFRESH_MINUTES"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 {
+			cfg.Logger.Error("Invalid ICAL_REFRESH_MINUTES, using default", "Error", err)
+		} else {
+			cfg.ICal.RefreshInterval = time.Duration(minutes) * time.Minute
+		}
+	}
+	cfg.ICal.AllowPrivateHosts = os.Getenv("ICAL_ALLOW_PRIVATE_HOSTS") == "true"
+
+	cfg.Payments.Provider = os.Getenv("PAYMENT_PROVIDER")
+	if cfg.Payments.Provider == "" {
+		cfg.Payments.Provider = "fake"
+	}
+	cfg.Payments.WebhookSecret = os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	cfg.Payments.Timeout = 15 * time.Minute
+	if v := os.Getenv("PAYMENT_TIMEOUT_MINUTES"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 {
+			cfg.Logger.Error("Invalid PAYMENT_TIMEOUT_MINUTES, using default", "Error", err)
+		} else {
+			cfg.Payments.Timeout = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.Holds.Default, cfg.Holds.Max = 10*time.Minute, 30*time.Minute
+	for env, dst := range map[string]*time.Duration{"BOOKING_HOLD_MINUTES": &cfg.Holds.Default, "BOOKING_HOLD_MAX_MINUTES": &cfg.Holds.Max} {
+		if v := os.Getenv(env); v != "" {
+			minutes, err := strconv.Atoi(v)
+			if err != nil || minutes <= 0 {
+				cfg.Logger.Error("Invalid "+env+", using default", "Error", err)
+			} else {
+				*dst = time.Duration(minutes) * time.Minute
+			}
+		}
+	}
+	if cfg.Holds.Default > cfg.Holds.Max {
+		cfg.Holds.Default = cfg.Holds.Max
+	}
+	cfg.Holds.MaxPerUser, cfg.Holds.MaxPerProperty = 3, 10
+	for env, dst := range map[string]*int{"BOOKING_HOLD_MAX_PER_USER": &cfg.Holds.MaxPerUser, "BOOKING_HOLD_MAX_PER_PROPERTY": &cfg.Holds.MaxPerProperty} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				cfg.Logger.Error("Invalid "+env+", using default", "Error", err)
+			} else {
+				*dst = n
+			}
+		}
+	}
+
+	cfg.RequestWindow = 24 * time.Hour
+	if v := os.Getenv("BOOKING_REQUEST_HOURS"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours <= 0 {
+			cfg.Logger.Error("Invalid BOOKING_REQUEST_HOURS, using default", "Error", err)
+		} else {
+			cfg.RequestWindow = time.Duration(hours) * time.Hour
+		}
+	}
+
+	// TRUSTED_PROXIES lists the addresses or CIDR ranges of the reverse
+	// proxies in front of the API, separated by commas.
+	cfg.TrustedProxies, err = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		cfg.Logger.Fatal("Invalid TRUSTED_PROXIES", "error", err)
+	}
+
+	// FX_RATES_FILE points at a rates table shaped like internal/fx/rates.json;
+	// without it the table built into the binary is used.
+	rates, err = fx.LoadTable(os.Getenv("FX_RATES_FILE"))
+	if err != nil {
+		cfg.Logger.Fatal("Failed to load exchange rates", "error", err)
+	}
+
+	gate, err = NewPaymentProvider()
+	if err != nil {
+		cfg.Logger.Fatal("Failed to configure payments", "error", err)
+	}
+
+	store, err = NewStorage()
+	if err != nil {
+		cfg.Logger.Fatal("Failed to configure storage", "error", err)
+	}
+
 	db, err = ConnectDB()
 	if err != nil {
 		cfg.Logger.Fatal("Failed to connect to database", "error", err)
@@ -66,6 +198,11 @@ func main() {
 
 	defer db.Close()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs.NewRunner(&cfg, repository.NewRepositoryUser(db), store, gate).Start(ctx)
+
 	srv := http.Server{
 		Addr:    ":" + cfg.Port,
 		Handler: routes(),
@@ -107,3 +244,24 @@ func ConnectDB() (*sql.DB, error) {
 
 	return db, nil
 }
+
+func NewStorage() (storage.Storage, error) {
+	switch cfg.Storage.Backend {
+	case "local":
+		return storage.NewLocal(cfg.Storage.UploadDir, cfg.Storage.BaseURL)
+	case "s3":
+		s3 := cfg.Storage.S3
+		return storage.NewS3(s3.Endpoint, s3.Region, s3.Bucket, s3.AccessKey, s3.SecretKey, s3.PublicURL)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+func NewPaymentProvider() (payments.Provider, error) {
+	switch cfg.Payments.Provider {
+	case "fake":
+		return payments.NewFake(cfg.Payments.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Payments.Provider)
+	}
+}
@@ -1,15 +1,39 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"time"
 
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 )
 
+// idempotencyKeyTTL is how long a response is replayed to retries.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyClaimLease is how long a request holds its Idempotency-Key
+// before a retry may take it over, should the request never finish.
+const idempotencyClaimLease = time.Minute
+
+// maxIdempotentBody bounds the request body read to fingerprint a request.
+const maxIdempotentBody = 1 << 20
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
 func RoleMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -64,6 +88,221 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// OptionalAuthMiddleware identifies the caller when a valid bearer token is
+// sent, so public routes can show more to signed-in users. Requests without
+// a usable token continue anonymously.
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := helper.VerifyToken(parts[1], cfg.JwtSecret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "userID", token["userID"])
+		ctx = context.WithValue(ctx, "role", token["role"])
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// IdempotencyMiddleware makes retries of a mutating request safe when the
+// client sends an Idempotency-Key header: the first response is stored per
+// user and replayed, with Idempotent-Replayed set, to later requests with
+// the same key for a day. Reusing a key for a different request is a 422
+// and retrying while the first is still running a 409, until its claim
+// lapses after idempotencyClaimLease. Bodies over 1 MiB are a 413. Server
+// errors are not stored, so the request can be retried. It must run after
+// AuthMiddleware; requests without the header pass through.
+func IdempotencyMiddleware(repo *repository.Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			userVal, _ := r.Context().Value("userID").(string)
+			userID, err := uuid.Parse(userVal)
+			if err != nil {
+				http.Error(w, "User is not in the context", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			stored, err := repo.ClaimIdempotencyKey(userID, key, requestFingerprint(r, body),
+				idempotencyClaimLease, idempotencyKeyTTL)
+			switch {
+			case errors.Is(err, repository.ErrIdempotencyKeyReused):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			case errors.Is(err, repository.ErrIdempotencyInProgress):
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case err != nil:
+				cfg.Logger.Error("Failed to claim idempotency key", "Error", err)
+				http.Error(w, "Failed to process request", http.StatusInternalServerError)
+				return
+			case stored != nil:
+				if stored.ContentType != "" {
+					w.Header().Set("Content-Type", stored.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+				return
+			}
+
+			var buf bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+
+			saved := false
+			// a panicking handler leaves the key free for a retry
+			defer func() {
+				if saved {
+					return
+				}
+				if err := repo.ReleaseIdempotencyKey(userID, key); err != nil {
+					cfg.Logger.Error("Failed to release idempotency key", "Error", err)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			res := models.StoredResponse{
+				StatusCode:  status,
+				ContentType: ww.Header().Get("Content-Type"),
+				Body:        buf.Bytes(),
+			}
+			if err := repo.SaveIdempotentResponse(userID, key, res); err != nil {
+				cfg.Logger.Error("Failed to store idempotent response", "Error", err)
+				return
+			}
+			saved = true
+		})
+	}
+}
+
+// requestFingerprint identifies what a request asks for, to tell a retry
+// from a different request reusing its idempotency key.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Accept-Currency")} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseTrustedProxies reads a comma-separated list of addresses and CIDR
+// ranges.
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an address or CIDR range", entry)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR range", entry)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range cfg.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIPMiddleware sets RemoteAddr to the client's address reported by a
+// trusted proxy. X-Forwarded-For is read from the right, skipping the
+// trusted proxies the request passed through, so addresses the client
+// put there itself are never taken; X-Real-IP is used without it. Headers
+// from any other caller are ignored and RemoteAddr is left alone.
+func RealIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		peer, err := netip.ParseAddr(host)
+		if err != nil || !isTrustedProxy(peer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := ""
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			hops := strings.Split(strings.Join(xff, ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				client = addr.Unmap().String()
+				if !isTrustedProxy(addr) {
+					break
+				}
+			}
+		} else if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			client = addr.Unmap().String()
+		}
+
+		if client != "" {
+			r.RemoteAddr = client
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -5,25 +5,30 @@ import (
 
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/handler"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/storage"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
 func routes() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.RequestID)
+	r.Use(RealIPMiddleware)
 	r.Use(LoggingMiddleware)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://*", "https://*"},
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodOptions},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Idempotent-Replayed"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
 	repo := repository.NewRepositoryUser(db)
-	h := handler.NewHandler(&cfg, repo)
+	h := handler.NewHandler(&cfg, repo, store, rates, gate)
+	idempotent := IdempotencyMiddleware(repo)
 
 	api := chi.NewRouter()
 
@@ -39,16 +44,54 @@ func routes() http.Handler {
 	api.Route("/properties", func(r chi.Router) {
 		// public
 		r.Get("/", h.GetAllProperties)
-		r.Get("/{id}", h.GetPropertyByID)
+		// signed-in hosts, admins and booked guests see the exact location
+		r.With(OptionalAuthMiddleware).Get("/{id}", h.GetPropertyByID)
 		r.Get("/{id}/availability", h.SearchAvailability)
+		// per-day availability and price; hosts and admins also see blocks
+		r.With(OptionalAuthMiddleware).Get("/{id}/calendar", h.GetPropertyCalendar)
+		// itemised price of a stay: nightly rates, discounts, fees and
+		// ?coupon; signed-in guests are checked against per-guest limits
+		r.With(OptionalAuthMiddleware).Get("/{id}/quote", h.GetQuote)
 
 		// protected: only users with appropriate role (e.g. host/admin)
 		r.With(AuthMiddleware, RoleMiddleware).Post("/", h.PostProperty)
-		r.With(AuthMiddleware, RoleMiddleware).Put("/", h.UpdateProperty)
+		// PUT needs If-Match with the ETag from GET; PATCH takes a JSON Merge Patch
+		r.With(AuthMiddleware, RoleMiddleware).Put("/{id}", h.UpdateProperty)
+		r.With(AuthMiddleware, RoleMiddleware).Patch("/{id}", h.PatchProperty)
+
+		// revision history
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/revisions", h.GetPropertyRevisions)
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/revisions/diff", h.DiffPropertyRevisions)
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/revisions/{version}/rollback", h.RollbackProperty)
 		r.With(AuthMiddleware, RoleMiddleware).Delete("/{id}", h.DeleteProperty)
 
+		// listing workflow: draft -> pending_review -> published
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/submit", h.ListingAction("submit"))
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/archive", h.ListingAction("archive"))
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/unarchive", h.ListingAction("unarchive"))
+
+		// availability calendar: blocked dates and min/max stay rules
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/calendar/blocks", h.PostCalendarBlock)
+		r.With(AuthMiddleware, RoleMiddleware).Delete("/{id}/calendar/blocks/{blockID}", h.DeleteCalendarBlock)
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/calendar/rules", h.GetStayRules)
+		r.With(AuthMiddleware, RoleMiddleware).Put("/{id}/calendar/rules", h.PutStayRules)
+		// seasonal/weekday rates, length-of-stay discounts and fees
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/pricing", h.GetPricingRules)
+		r.With(AuthMiddleware, RoleMiddleware).Put("/{id}/pricing", h.PutPricingRules)
+
+		// iCal sync with other platforms
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/calendar/export", h.GetICalExport(false))
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/calendar/export/rotate", h.GetICalExport(true))
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/calendar/imports", h.GetCalendarImports)
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/calendar/imports", h.PostCalendarImport)
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/calendar/imports/{importID}/sync", h.SyncCalendarImport)
+		r.With(AuthMiddleware, RoleMiddleware).Delete("/{id}/calendar/imports/{importID}", h.DeleteCalendarImport)
+
 		// property images
 		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/images", h.PostImage)
+		r.With(AuthMiddleware, RoleMiddleware).Post("/{id}/images/upload", h.UploadPropertyImages)
+		r.With(AuthMiddleware, RoleMiddleware).Put("/{id}/images/order", h.ReorderPropertyImages)
+		r.With(AuthMiddleware, RoleMiddleware).Patch("/{id}/images/{imageID}", h.UpdatePropertyImage)
 		r.With(AuthMiddleware, RoleMiddleware).Delete("/{id}/images/{imageID}", h.DeletePropertyImage)
 	})
 
@@ -56,24 +99,93 @@ func routes() http.Handler {
 	api.Route("/amenities", func(r chi.Router) {
 		// anyone can list amenities
 		r.Get("/", h.GetAmenities)
-		// only admins/hosts can create or attach
+		// only admins/hosts can manage the catalog or attach/detach
 		r.With(AuthMiddleware, RoleMiddleware).Post("/", h.AddAmenity)
+		r.With(AuthMiddleware, RoleMiddleware).Patch("/{amenityID}", h.UpdateAmenity)
+		r.With(AuthMiddleware, RoleMiddleware).Delete("/{amenityID}", h.DeleteAmenity)
 		r.With(AuthMiddleware, RoleMiddleware).Post("/{propertyID}", h.PostPropertyAmenities)
+		// replace a property's whole amenity set, or detach a single amenity
+		r.With(AuthMiddleware, RoleMiddleware).Put("/{propertyID}", h.ReplacePropertyAmenities)
+		r.With(AuthMiddleware, RoleMiddleware).Delete("/{propertyID}/{amenityID}", h.DetachPropertyAmenity)
+	})
+
+	// --- Coupons ---
+	api.Route("/coupons", func(r chi.Router) {
+		// check a promo code against a stay and quote it with the discount
+		r.With(OptionalAuthMiddleware).Post("/validate", h.ValidateCoupon)
 	})
 
 	// --- Bookings ---
 	api.Route("/bookings", func(r chi.Router) {
-		// user must be authenticated to access bookings
+		// user must be authenticated to access bookings; writes can be
+		// retried safely with an Idempotency-Key header
 		r.With(AuthMiddleware).Get("/", h.GetBookings)
-		r.With(AuthMiddleware).Post("/", h.CreateBooking)
-		r.With(AuthMiddleware).Get("/{id}", h.GetBookingByID)
+		r.With(AuthMiddleware, idempotent).Post("/", h.CreateBooking)
+		// keep dates for a few minutes during checkout, then confirm or let go
+		r.With(AuthMiddleware, idempotent).Post("/holds", h.CreateHold)
+		r.With(AuthMiddleware, idempotent).Post("/holds/{id}/confirm", h.ConfirmHold)
+		r.With(AuthMiddleware, idempotent).Delete("/holds/{id}", h.ReleaseHold)
+		// requests waiting for the host on listings booked on request
+		r.With(AuthMiddleware, RoleMiddleware).Get("/requests", h.GetBookingRequests)
+		// only the guest, the listing's host and admins can see a booking
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}", h.GetBookingByID)
+		r.With(AuthMiddleware, RoleMiddleware, idempotent).Post("/{id}/accept", h.AcceptBookingRequest)
+		r.With(AuthMiddleware, RoleMiddleware, idempotent).Post("/{id}/decline", h.DeclineBookingRequest)
 		// partial update for status changes (cancel, check-in, etc.)
-		r.With(AuthMiddleware).Patch("/{id}", h.CancelBooking)
+		r.With(AuthMiddleware, idempotent).Patch("/{id}", h.CancelBooking)
+		// the listing's host cancels: full refund to the guest, penalty to the host
+		r.With(AuthMiddleware, RoleMiddleware, idempotent).Post("/{id}/host-cancel", h.HostCancelBooking)
+		// change dates or guests; applied at once or left for the host to accept
+		r.With(AuthMiddleware, idempotent).Post("/{id}/modifications", h.RequestModification)
+		r.With(AuthMiddleware, RoleMiddleware).Get("/{id}/modifications", h.GetModifications)
+		r.With(AuthMiddleware, RoleMiddleware, idempotent).Post("/{id}/modifications/{modID}/accept", h.AcceptModification)
+		r.With(AuthMiddleware, RoleMiddleware, idempotent).Post("/{id}/modifications/{modID}/decline", h.DeclineModification)
 	})
 
+	// --- Admin ---
+	api.Route("/admin", func(r chi.Router) {
+		r.Use(AuthMiddleware, RoleMiddleware)
+		// audit trail filtered by entity, actor and time range
+		r.Get("/audit", h.GetAuditLog)
+		// listing moderation
+		r.Get("/moderation", h.GetModerationQueue)
+		r.Post("/moderation/{id}/approve", h.ListingAction("approve"))
+		r.Post("/moderation/{id}/reject", h.ListingAction("reject"))
+		r.Post("/properties/{id}/suspend", h.ListingAction("suspend"))
+		r.Post("/properties/{id}/reinstate", h.ListingAction("reinstate"))
+		// undo a soft delete before the retention job purges it
+		r.Post("/properties/{id}/restore", h.RestoreProperty)
+		// tax rules by jurisdiction, and tax collected split from revenue
+		r.Get("/taxes", h.GetTaxRules)
+		r.Post("/taxes", h.PostTaxRule)
+		r.Get("/taxes/report", h.GetTaxReport)
+		r.Get("/taxes/{id}", h.GetTaxRule)
+		r.Put("/taxes/{id}", h.PutTaxRule)
+		r.Delete("/taxes/{id}", h.DeleteTaxRule)
+		// promo codes
+		r.Get("/coupons", h.GetCoupons)
+		r.Post("/coupons", h.PostCoupon)
+		r.Get("/coupons/{id}", h.GetCoupon)
+		r.Put("/coupons/{id}", h.PutCoupon)
+		r.Delete("/coupons/{id}", h.DeleteCoupon)
+		// how many holds turned into bookings
+		r.Get("/holds/report", h.GetHoldReport)
+	})
+
+	// payment gateway notifications, authenticated by their signature
+	api.Post("/payments/webhook", h.PaymentWebhook)
+
+	// secret per-property iCal feed; the token in the URL is the credential
+	api.Get("/ical/{token}.ics", h.ICalFeed)
+
 	// health check
 	api.Get("/healthz", h.Healthz)
 
+	// files uploaded to the local storage backend
+	if local, ok := store.(*storage.Local); ok {
+		r.Handle("/uploads/*", http.StripPrefix("/uploads", local.Handler()))
+	}
+
 	// mount versioned API
 	r.Mount("/api/v1", api)
 
@@ -0,0 +1,59 @@
+// Command icalstub serves an iCalendar feed on localhost, standing in for
+// another listing platform when trying out calendar imports. Run the API
+// with ICAL_ALLOW_PRIVATE_HOSTS=true so it may fetch from it.
+//
+//	go run ./cmd/icalstub -addr :8090            # generated sample feed
+//	go run ./cmd/icalstub -file other.ics        # serve a saved export
+//
+// Every path returns the same calendar, e.g. http://localhost:8090/feed.ics.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/ical"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	file := flag.String("file", "", "serve this .ics file instead of a generated one")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := feed(*file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Write(body)
+	})
+
+	log.Printf("serving iCal feed on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// feed reads file on every request so it can be edited while running, or
+// builds two stays a week and a month from today.
+func feed(file string) ([]byte, error) {
+	if file != "" {
+		return os.ReadFile(file)
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	events := []ical.Event{
+		{UID: "stub-1@icalstub", Summary: "Reserved", Start: today.AddDate(0, 0, 7), End: today.AddDate(0, 0, 10)},
+		{UID: "stub-2@icalstub", Summary: "Not available", Start: today.AddDate(0, 1, 0), End: today.AddDate(0, 1, 2)},
+	}
+
+	var buf bytes.Buffer
+	err := ical.Write(&buf, "icalstub", events, now)
+	return buf.Bytes(), err
+}
@@ -94,9 +94,12 @@ export default function BookingsPage() {
   };
 
   const getStatusColor = (status: string) => {
-    if (status === "cancelled") {
+    if (status === "cancelled" || status === "payment_failed") {
       return "bg-red-100 text-red-800 border-red-200";
     }
+    if (status === "pending_payment") {
+      return "bg-yellow-100 text-yellow-800 border-yellow-200";
+    }
     return "bg-green-100 text-green-800 border-green-200";
   };
 
@@ -162,7 +165,7 @@ export default function BookingsPage() {
                         booking.status
                       )}`}
                     >
-                      {booking.status.charAt(0).toUpperCase() + booking.status.slice(1)}
+                      {booking.status.charAt(0).toUpperCase() + booking.status.slice(1).replace("_", " ")}
                     </span>
                   </div>
                 </CardHeader>
@@ -209,7 +212,7 @@ export default function BookingsPage() {
                     <div className="text-xs text-muted-foreground">
                       Booking ID: {booking.id?.slice(0, 8) || "N/A"}...
                     </div>
-                    {booking.status === "booked" && (
+                    {(booking.status === "confirmed" || booking.status === "pending_payment") && (
                       <Button
                         variant="destructive"
                         onClick={() => openCancelDialog(booking.id)}
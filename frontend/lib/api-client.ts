@@ -63,7 +63,18 @@ export interface Booking {
   start_date: string;
   end_date: string;
   total_price: number;
-  status: "booked" | "cancelled";
+  status:
+    | "held"
+    | "hold_expired"
+    | "hold_released"
+    | "requested"
+    | "declined"
+    | "request_expired"
+    | "pending_payment"
+    | "confirmed"
+    | "payment_failed"
+    | "cancelled";
+  respond_by?: string;
   created_at: string;
 }
 
@@ -182,12 +193,19 @@ export const bookingsApi = {
 
   getById: (id: string) => api.get<Booking>(`/bookings/${id}`),
 
-  create: (data: {
-    property_id: string;
-    start_date: string;
-    end_date: string;
-    total_price: number;
-  }) => api.post<Booking>("/bookings", data),
+  // pass the same idempotencyKey when retrying so the booking is made once
+  create: (
+    data: {
+      property_id: string;
+      start_date: string;
+      end_date: string;
+      total_price: number;
+    },
+    idempotencyKey?: string
+  ) =>
+    api.post<Booking>("/bookings", data, {
+      headers: idempotencyKey ? { "Idempotency-Key": idempotencyKey } : undefined,
+    }),
 
   cancel: (id: string) => api.patch<Booking>(`/bookings/${id}`),
 };
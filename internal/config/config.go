@@ -1,6 +1,9 @@
 package config
 
 import (
+	"net/netip"
+	"time"
+
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/logger"
 )
 
@@ -15,4 +18,41 @@ type Config struct {
 	}
 	Logger    *logger.AppLogger
 	JwtSecret string
+	// PropertyRetention is how long soft-deleted properties are kept before
+	// they are purged.
+	PropertyRetention time.Duration
+	Storage           struct {
+		Backend       string // "local" or "s3"
+		UploadDir     string // local backend root directory
+		BaseURL       string // public URL prefix for local uploads
+		MaxUploadSize int64  // per-file limit in bytes
+		S3            struct {
+			Endpoint  string
+			Region    string
+			Bucket    string
+			AccessKey string
+			SecretKey string
+			PublicURL string
+		}
+	}
+	ICal struct {
+		RefreshInterval   time.Duration // how often imported calendars are re-fetched
+		AllowPrivateHosts bool          // let imports reach local addresses, for the stub server
+	}
+	Payments struct {
+		Provider      string        // only "fake" for now
+		WebhookSecret string        // signs the provider's webhooks
+		Timeout       time.Duration // how long a booking holds its dates waiting for payment
+	}
+	Holds struct {
+		Default        time.Duration // how long a hold keeps its dates when the guest does not say
+		Max            time.Duration // longest hold a guest can ask for
+		MaxPerUser     int           // holds a guest can have active at once
+		MaxPerProperty int           // holds a listing can have active at once
+	}
+	// RequestWindow is how long a host has to answer a booking request.
+	RequestWindow time.Duration
+	// TrustedProxies are the addresses whose X-Forwarded-For and X-Real-IP
+	// headers are believed; anyone else could forge them.
+	TrustedProxies []netip.Prefix
 }
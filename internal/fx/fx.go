@@ -0,0 +1,94 @@
+// Package fx converts prices between currencies. Rates come from a Provider;
+// the built-in Table reads them from a JSON file so conversion works offline.
+package fx
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+var ErrNoRate = errors.New("no exchange rate for currency pair")
+
+// Rate converts amounts in From to To: one unit of From buys Value units of
+// To.
+type Rate struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Value float64   `json:"rate"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// Convert converts an amount in r.From to r.To, rounded to the decimals of
+// r.To.
+func (r Rate) Convert(a money.Amount) money.Amount {
+	return a.Mul(r.Value).Round(r.To)
+}
+
+// Provider supplies exchange rates.
+type Provider interface {
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+//go:embed rates.json
+var defaultRates []byte
+
+// Table is a Provider backed by a fixed set of rates against one base
+// currency. Cross rates go through the base.
+type Table struct {
+	Base  string             `json:"base"`
+	AsOf  time.Time          `json:"as_of"`
+	Rates map[string]float64 `json:"rates"` // units of each currency per unit of Base
+}
+
+// LoadTable reads a table from a JSON file shaped like rates.json. An empty
+// path loads the table built into the binary.
+func LoadTable(path string) (*Table, error) {
+	data := defaultRates
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid exchange rate table: %w", err)
+	}
+	t.Base = strings.ToUpper(t.Base)
+	if t.Rates == nil {
+		t.Rates = map[string]float64{}
+	}
+	t.Rates[t.Base] = 1
+
+	for code, rate := range t.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate table: rate for %s must be positive", code)
+		}
+	}
+
+	return &t, nil
+}
+
+func (t *Table) Rate(_ context.Context, from, to string) (Rate, error) {
+	if from == to {
+		return Rate{From: from, To: to, Value: 1, AsOf: t.AsOf}, nil
+	}
+
+	fromRate, ok1 := t.Rates[from]
+	toRate, ok2 := t.Rates[to]
+	if !ok1 || !ok2 {
+		return Rate{}, fmt.Errorf("%w: %s to %s", ErrNoRate, from, to)
+	}
+
+	return Rate{From: from, To: to, Value: toRate / fromRate, AsOf: t.AsOf}, nil
+}
@@ -0,0 +1,126 @@
+// Package geo holds the small amount of spherical geometry the listings
+// need: coordinate validation, great-circle distance and location fuzzing.
+package geo
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+)
+
+// EarthRadiusKm is the mean Earth radius used for distance calculations.
+const EarthRadiusKm = 6371.0
+
+// FuzzRadiusKm is how far a listing's public position may be from its real
+// one. Large enough to hide the building, small enough to keep the map useful.
+const FuzzRadiusKm = 0.5
+
+var ErrInvalidCoordinates = errors.New("latitude must be within [-90, 90] and longitude within [-180, 180], and both must be set together")
+
+// Validate checks an optional coordinate pair. Both must be set or neither.
+func Validate(lat, lng *float64) error {
+	if lat == nil && lng == nil {
+		return nil
+	}
+	if lat == nil || lng == nil {
+		return ErrInvalidCoordinates
+	}
+	if math.IsNaN(*lat) || math.IsNaN(*lng) || *lat < -90 || *lat > 90 || *lng < -180 || *lng > 180 {
+		return ErrInvalidCoordinates
+	}
+	return nil
+}
+
+// Distance returns the great-circle distance in kilometres between two
+// points using the haversine formula.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Fuzz returns a point chosen uniformly at random within FuzzRadiusKm of
+// (lat, lng), rounded to about 100 m. Callers store the result rather than
+// recomputing it per request, so the real position cannot be recovered by
+// averaging many responses.
+func Fuzz(lat, lng float64) (float64, float64) {
+	distance := FuzzRadiusKm * math.Sqrt(rand.Float64())
+	bearing := 2 * math.Pi * rand.Float64()
+
+	dLat := distance / EarthRadiusKm * math.Cos(bearing)
+	dLng := distance / EarthRadiusKm * math.Sin(bearing) / math.Max(math.Cos(radians(lat)), 0.01)
+
+	fLat := math.Max(-90, math.Min(90, lat+degrees(dLat)))
+	fLng := wrap(lng + degrees(dLng))
+
+	return round(fLat, 3), round(fLng, 3)
+}
+
+// BoundingBox is a map viewport. West may be greater than East when the box
+// crosses the antimeridian.
+type BoundingBox struct {
+	West, South, East, North float64
+}
+
+func (b BoundingBox) Validate() error {
+	if b.South > b.North {
+		return errors.New("bbox south must not exceed north")
+	}
+	for _, lat := range []float64{b.South, b.North} {
+		if lat < -90 || lat > 90 {
+			return ErrInvalidCoordinates
+		}
+	}
+	for _, lng := range []float64{b.West, b.East} {
+		if lng < -180 || lng > 180 {
+			return ErrInvalidCoordinates
+		}
+	}
+	return nil
+}
+
+// Around returns a box that contains every point within radiusKm of
+// (lat, lng); it is used to narrow radius searches with an index.
+func Around(lat, lng, radiusKm float64) BoundingBox {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	box := BoundingBox{
+		South: math.Max(-90, lat-dLat),
+		North: math.Min(90, lat+dLat),
+		West:  -180,
+		East:  180,
+	}
+
+	// Near the poles every longitude is within range.
+	if box.South > -90 && box.North < 90 {
+		dLng := degrees(math.Asin(math.Min(1, math.Sin(radiusKm/EarthRadiusKm)/math.Cos(radians(lat)))))
+		if dLng < 180 {
+			box.West = wrap(lng - dLng)
+			box.East = wrap(lng + dLng)
+		}
+	}
+
+	return box
+}
+
+func wrap(lng float64) float64 {
+	if lng > 180 {
+		return lng - 360
+	}
+	if lng < -180 {
+		return lng + 360
+	}
+	return lng
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
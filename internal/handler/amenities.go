@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) UpdateAmenity(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	amenityID, err := uuid.Parse(r.PathValue("amenityID"))
+	if err != nil {
+		http.Error(w, "invalid amenity id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateAmenityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == nil && req.Category == nil && req.Icon == nil {
+		http.Error(w, "nothing to update: provide name, category and/or icon", http.StatusBadRequest)
+		return
+	}
+
+	if (req.Name != nil && *req.Name == "") || (req.Category != nil && *req.Category == "") {
+		http.Error(w, "name and category cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	amenity, err := h.repo.UpdateAmenity(amenityID, req, actorFromRequest(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "amenity not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrConflict):
+			http.Error(w, "an amenity with this name already exists", http.StatusConflict)
+		default:
+			h.cfg.Logger.Error("Failed to update amenity", "error", err)
+			http.Error(w, "failed to update amenity", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := helper.WriteJSON(w, amenity, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) DeleteAmenity(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	amenityID, err := uuid.Parse(r.PathValue("amenityID"))
+	if err != nil {
+		http.Error(w, "invalid amenity id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteAmenity(amenityID, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "amenity not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to delete amenity", "error", err)
+		http.Error(w, "failed to delete amenity", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully deleted amenity"}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) DetachPropertyAmenity(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("propertyID"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	amenityID, err := uuid.Parse(r.PathValue("amenityID"))
+	if err != nil {
+		http.Error(w, "invalid amenity id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DetachPropertyAmenity(propertyID, amenityID, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "amenity is not attached to this property", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to detach property amenity", "error", err)
+		http.Error(w, "failed to detach amenity", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully detached amenity"}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) ReplacePropertyAmenities(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("propertyID"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AddAmenitiesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.cfg.Logger.Error("Invalid JSON body", "error", err)
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	// An empty list is valid and clears every amenity.
+	if err := h.repo.ReplacePropertyAmenities(propertyID, req.AmenityID, actorFromRequest(r)); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "property not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrUnknownAmenity):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.cfg.Logger.Error("Failed to replace property amenities", "error", err)
+			http.Error(w, "failed to replace amenities", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully replaced amenities"}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+
+	filter := models.AuditFilter{
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+	}
+
+	if actor := q.Get("actor_id"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			http.Error(w, "invalid actor_id", http.StatusBadRequest)
+			return
+		}
+		filter.ActorID = actorID
+	}
+
+	for param, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := q.Get(param)
+		if value == "" {
+			continue
+		}
+
+		t, err := parseAuditTime(value)
+		if err != nil {
+			http.Error(w, "invalid "+param+", use RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		*dst = &t
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.repo.GetAuditLog(filter)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get audit log", "error", err)
+		http.Error(w, "failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, entries, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
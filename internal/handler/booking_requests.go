@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+// requestBooking sends stay to the host of a listing booked on request,
+// answering 202 with the deadline the host has to accept it by.
+func (h *Handler) requestBooking(w http.ResponseWriter, stay models.StayRequest, currency string, req bookingRequest, actor models.Actor) {
+	id, respondBy, quote, err := h.repo.RequestBooking(stay, currency, req.ExpectedTotal, h.rates,
+		strings.TrimSpace(req.PaymentMethod), h.cfg.RequestWindow, actor)
+	if err != nil {
+		h.bookingError(w, err, currency, quote)
+		return
+	}
+
+	res := map[string]any{"id": id, "status": models.BookingRequested, "respond_by": respondBy, "quote": quote}
+	if err := helper.WriteJSON(w, res, http.StatusAccepted); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+	}
+}
+
+// GetBookingRequests lists the booking requests waiting for the caller to
+// answer, or every host's for admins.
+func (h *Handler) GetBookingRequests(w http.ResponseWriter, r *http.Request) {
+	var hostID uuid.NullUUID
+	if role, _ := r.Context().Value("role").(string); role != "admin" {
+		hostID = uuid.NullUUID{UUID: actorFromRequest(r).UserID, Valid: true}
+	}
+
+	requests, err := h.repo.GetBookingRequests(hostID)
+	if err != nil {
+		h.requestError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, requests, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// AcceptBookingRequest lets the listing's host, or an admin, accept a
+// booking request. The guest is charged with the payment method they left,
+// answering like CreateBooking.
+func (h *Handler) AcceptBookingRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.managedRequest(w, r)
+	if !ok {
+		return
+	}
+
+	actor := actorFromRequest(r)
+	payment, paymentMethod, quote, err := h.repo.AcceptBookingRequest(id, h.gate.Name(), h.cfg.Payments.Timeout, actor)
+	if err != nil {
+		h.requestError(w, err)
+		return
+	}
+
+	h.writeBookingPayment(w, r, payment, paymentMethod, quote, actor)
+}
+
+// DeclineBookingRequest lets the listing's host, or an admin, turn down a
+// booking request with an optional reason, giving back its dates.
+func (h *Handler) DeclineBookingRequest(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	id, ok := h.managedRequest(w, r)
+	if !ok {
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if err := h.repo.DeclineBookingRequest(id, reason, actorFromRequest(r)); err != nil {
+		h.requestError(w, err)
+		return
+	}
+
+	res := map[string]any{"id": id, "status": models.BookingDeclined, "reason": reason}
+	if err := helper.WriteJSON(w, res, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+	}
+}
+
+// managedRequest reads the booking id in the request path, writing an error
+// and returning false unless the caller manages the booked listing.
+func (h *Handler) managedRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid booking id", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	ok, err := h.canManageBooking(r, id)
+	if err != nil {
+		h.requestError(w, err)
+		return uuid.Nil, false
+	}
+	if !ok {
+		http.Error(w, "forbidden: not your listing", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *Handler) requestError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "booking not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrNotRequested):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrRequestExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+	default:
+		h.cfg.Logger.Error("Failed booking request update", "Error", err)
+		http.Error(w, "failed to update booking request", http.StatusInternalServerError)
+	}
+}
@@ -1,12 +1,22 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
 	"github.com/google/uuid"
 )
 
@@ -34,8 +44,29 @@ func (h *Handler) GetBookings(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetBookingByID shows a booking, with its payments and co-guests, to its
+// guest and the listing's host; anyone else gets a 404.
 func (h *Handler) GetBookingByID(w http.ResponseWriter, r *http.Request) {
-	bookingID := uuid.MustParse(r.PathValue("id"))
+	bookingID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid booking id", http.StatusBadRequest)
+		return
+	}
+
+	guestID, err := h.repo.BookingGuestID(bookingID)
+	ok := err == nil && guestID == actorFromRequest(r).UserID
+	if err == nil && !ok {
+		ok, err = h.canManageBooking(r, bookingID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.cfg.Logger.Error("Unable to get booking", "Error", err)
+		http.Error(w, "failed to fetch booking", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "booking not found", http.StatusNotFound)
+		return
+	}
 
 	res, err := h.repo.GetBookingByID(bookingID)
 	if err != nil {
@@ -50,6 +81,56 @@ func (h *Handler) GetBookingByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// bookingRequest is the body of a booking or a hold.
+type bookingRequest struct {
+	PropertyID uuid.UUID `json:"property_id"`
+	StartDate  string    `json:"start_date"`
+	EndDate    string    `json:"end_date"`
+	partyRequest
+	// CoGuests optionally names the rest of the party.
+	CoGuests []models.CoGuest `json:"co_guests"`
+	// ExpectedTotal is the quoted total the guest agreed to, in the
+	// display currency; the booking fails with 409 if the price has
+	// changed since. The total itself is always computed by the server.
+	ExpectedTotal *money.Amount `json:"expected_total"`
+	// CouponCode is an optional promo code, redeemed by the booking.
+	CouponCode string `json:"coupon_code"`
+	// PaymentMethod is the token the client got from the payment
+	// gateway.
+	PaymentMethod string `json:"payment_method"`
+}
+
+// stay validates the request as a stay for userID.
+func (req bookingRequest) stay(userID uuid.UUID) (models.StayRequest, error) {
+	startDate, err1 := time.Parse("2006-01-02", req.StartDate)
+	endDate, err2 := time.Parse("2006-01-02", req.EndDate)
+	if err1 != nil || err2 != nil {
+		return models.StayRequest{}, errors.New("Invalid date format, use YYYY-MM-DD")
+	}
+
+	if !endDate.After(startDate) {
+		return models.StayRequest{}, errors.New("End date must be after start date")
+	}
+
+	party, err := req.party()
+	if err == nil {
+		err = normalizeCoGuests(req.CoGuests, party)
+	}
+	if err != nil {
+		return models.StayRequest{}, err
+	}
+
+	return models.StayRequest{
+		PropertyID: req.PropertyID,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Party:      party,
+		CoGuests:   req.CoGuests,
+		CouponCode: strings.TrimSpace(req.CouponCode),
+		UserID:     userID,
+	}, nil
+}
+
 func (h *Handler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 	userVal := r.Context().Value("userID")
 
@@ -61,11 +142,11 @@ func (h *Handler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 
 	userID := uuid.MustParse(userVal.(string))
 
-	var req struct {
-		PropertyID uuid.UUID `json:"property_id"`
-		StartDate  string    `json:"start_date"`
-		EndDate    string    `json:"end_date"`
-		TotalPrice int       `json:"total_price"`
+	var req bookingRequest
+
+	currency, ok := requireDisplayCurrency(w, r)
+	if !ok {
+		return
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -74,28 +155,157 @@ func (h *Handler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startDate, err1 := time.Parse("2006-01-02", req.StartDate)
-	endDate, err2 := time.Parse("2006-01-02", req.EndDate)
-	if err1 != nil || err2 != nil {
-		h.cfg.Logger.Error("Invalid date format", "Error", err1, "Error", err2)
-		http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
+	stay, err := req.stay(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if !endDate.After(startDate) {
-		h.cfg.Logger.Error("End date must be after start date")
-		http.Error(w, "End date must be after start date", http.StatusBadRequest)
+	actor := actorFromRequest(r)
+	payment, quote, err := h.repo.CreateBooking(stay, currency, req.ExpectedTotal, h.rates,
+		h.gate.Name(), h.cfg.Payments.Timeout, actor)
+	if errors.Is(err, repository.ErrRequestToBook) {
+		h.requestBooking(w, stay, currency, req, actor)
 		return
 	}
-
-	res, err := h.repo.CreateBooking(userID, req.PropertyID, startDate, endDate, req.TotalPrice)
 	if err != nil {
+		h.bookingError(w, err, currency, quote)
+		return
+	}
+
+	h.writeBookingPayment(w, r, payment, req.PaymentMethod, quote, actor)
+}
+
+// writeBookingPayment collects a new booking's payment and writes the
+// booking's outcome: 201 once paid, 402 if the payment failed and 202 while
+// it is still being processed.
+func (h *Handler) writeBookingPayment(w http.ResponseWriter, r *http.Request, payment models.Payment, paymentMethod string, quote models.PriceQuote, actor models.Actor) {
+	// the booking holds its dates until its payment is captured, fails or
+	// times out
+	payment = h.collectPayment(r.Context(), payment, paymentMethod, actor)
+
+	res := map[string]any{"id": payment.BookingID, "quote": quote, "payment": payment}
+	switch payment.Status {
+	case models.PaymentCaptured:
+		res["status"] = models.BookingConfirmed
+		helper.WriteJSON(w, res, http.StatusCreated)
+	case models.PaymentFailed:
+		res["status"] = models.BookingPaymentFailed
+		res["error"] = "payment failed"
+		helper.WriteJSON(w, res, http.StatusPaymentRequired)
+	default:
+		res["status"] = models.BookingPendingPayment
+		helper.WriteJSON(w, res, http.StatusAccepted)
+	}
+}
+
+// bookingError writes the response for a booking or hold that could not be
+// placed; quote is the current price, shown when it has changed.
+func (h *Handler) bookingError(w http.ResponseWriter, err error, currency string, quote models.PriceQuote) {
+	var (
+		stayErr   *repository.StayRuleError
+		couponErr *repository.CouponError
+	)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "property not found", http.StatusNotFound)
+	case errors.As(err, &stayErr):
+		http.Error(w, stayErr.Error(), http.StatusUnprocessableEntity)
+	case errors.As(err, &couponErr):
+		http.Error(w, couponErr.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, repository.ErrDatesUnavailable):
+		http.Error(w, "the property is not available for these dates", http.StatusConflict)
+	case errors.Is(err, repository.ErrRequestToBook), errors.Is(err, repository.ErrInstantBook):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrTooManyHolds):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, fx.ErrNoRate):
+		http.Error(w, "prices cannot be shown in "+currency, http.StatusUnprocessableEntity)
+	case errors.Is(err, repository.ErrPriceChanged):
+		helper.WriteJSON(w, map[string]any{"error": "the price has changed", "quote": quote}, http.StatusConflict)
+	default:
 		h.cfg.Logger.Error("Unable to create booking", "Error", err)
 		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
-		return
 	}
+}
 
-	helper.WriteJSON(w, res, http.StatusAccepted)
+// partyRequest is who is coming on a stay, in a request body. Guests is
+// the single head count older clients send; it is read as adults when
+// neither adults nor children are given.
+type partyRequest struct {
+	Guests   int `json:"guests"`
+	Adults   int `json:"adults"`
+	Children int `json:"children"`
+	Infants  int `json:"infants"`
+	Pets     int `json:"pets"`
+}
+
+func (p partyRequest) party() (models.GuestCount, error) {
+	if p.Guests < 0 || p.Adults < 0 || p.Children < 0 || p.Infants < 0 || p.Pets < 0 {
+		return models.GuestCount{}, errors.New("guest counts must not be negative")
+	}
+
+	party := models.GuestCount{Adults: p.Adults, Children: p.Children, Infants: p.Infants, Pets: p.Pets}
+	if party.Adults == 0 && party.Children == 0 {
+		party.Adults = max(p.Guests, 1)
+	}
+	if party.Adults < 1 {
+		return party, errors.New("at least one adult is required")
+	}
+	return party, nil
+}
+
+// partyFromQuery reads a partyRequest from the guests, adults, children,
+// infants and pets query parameters.
+func partyFromQuery(q url.Values) (models.GuestCount, error) {
+	var p partyRequest
+	for name, dst := range map[string]*int{
+		"guests": &p.Guests, "adults": &p.Adults, "children": &p.Children, "infants": &p.Infants, "pets": &p.Pets,
+	} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return models.GuestCount{}, fmt.Errorf("%s must be an integer", name)
+			}
+			*dst = n
+		}
+	}
+	return p.party()
+}
+
+// normalizeCoGuests trims the names of a booking's co-guests, defaults
+// their age group to adult, and checks they fit in party alongside the
+// guest who booked.
+func normalizeCoGuests(guests []models.CoGuest, party models.GuestCount) error {
+	counts := map[string]int{}
+	for i := range guests {
+		g := &guests[i]
+		g.FirstName = strings.TrimSpace(g.FirstName)
+		g.LastName = strings.TrimSpace(g.LastName)
+		if g.FirstName == "" {
+			return errors.New("co-guests need a first_name")
+		}
+		if g.AgeGroup == "" {
+			g.AgeGroup = models.AgeAdult
+		}
+		counts[g.AgeGroup]++
+	}
+
+	limit := map[string]int{
+		models.AgeAdult:  party.Adults - 1,
+		models.AgeChild:  party.Children,
+		models.AgeInfant: party.Infants,
+	}
+	for group, n := range counts {
+		allowed, ok := limit[group]
+		if !ok {
+			return fmt.Errorf("unknown co-guest age_group %q", group)
+		}
+		if n > allowed {
+			return fmt.Errorf("more %s co-guests than the party has", group)
+		}
+	}
+	return nil
 }
 
 func (h *Handler) CancelBooking(w http.ResponseWriter, r *http.Request) {
@@ -110,16 +320,108 @@ func (h *Handler) CancelBooking(w http.ResponseWriter, r *http.Request) {
 
 	userID := uuid.MustParse(userVal.(string))
 
-	res, err := h.repo.CancelBooking(bookingID, userID)
+	actor := actorFromRequest(r)
+	res, err := h.repo.CancelBooking(bookingID, userID, time.Now().UTC(), actor)
 	if err != nil {
-		h.cfg.Logger.Error("Failed Cancellation", "Error", err)
-		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
+		h.cancellationError(w, err)
+		return
+	}
+
+	res = h.refundCancellation(r.Context(), res, actor)
+
+	if err := helper.WriteJSON(w, res, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+
+}
+
+// HostCancelBooking lets the host of the booked listing, or an admin, cancel
+// a booking with a reason. The guest gets everything back and the host is
+// charged a penalty.
+func (h *Handler) HostCancelBooking(w http.ResponseWriter, r *http.Request) {
+	bookingID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid booking id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, repository.ErrReasonRequired.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ok, err := h.canManageBooking(r, bookingID)
+	if err != nil {
+		h.cancellationError(w, err)
+		return
+	}
+	if !ok {
+		http.Error(w, "forbidden: not your listing", http.StatusForbidden)
+		return
+	}
+
+	actor := actorFromRequest(r)
+	res, err := h.repo.HostCancelBooking(bookingID, req.Reason, time.Now().UTC(), actor)
+	if err != nil {
+		h.cancellationError(w, err)
 		return
 	}
 
+	res = h.refundCancellation(r.Context(), res, actor)
+
 	if err := helper.WriteJSON(w, res, http.StatusOK); err != nil {
 		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
 		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
 	}
+}
+
+// refundCancellation pays back the refunds the cancellation recorded on
+// the booking's captured payments, voids authorizations that will no longer
+// be captured, and lists the payments afterwards. A refund the gateway
+// turns down stays due on its payment, shown as failed, and is retried in
+// the background.
+func (h *Handler) refundCancellation(ctx context.Context, c models.Cancellation, actor models.Actor) models.Cancellation {
+	payments, err := h.repo.GetBookingPayments(c.BookingID)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get booking payments", "Error", err)
+		return c
+	}
 
+	for i, p := range payments {
+		switch {
+		case p.RefundDue > 0:
+			payments[i] = h.settleRefund(ctx, p, actor)
+		case p.Status == models.PaymentAuthorized:
+			failed, err := h.updatePayment(ctx, p, models.PaymentFailed, "", "booking cancelled", actor)
+			if err != nil {
+				h.cfg.Logger.Error("Failed to release payment", "payment", p.ID, "Error", err)
+				continue
+			}
+			payments[i] = failed
+		}
+	}
+
+	c.Payments = payments
+	return c
+}
+
+func (h *Handler) cancellationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "booking not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrNotCancellable), errors.Is(err, repository.ErrStayCompleted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.cfg.Logger.Error("Failed Cancellation", "Error", err)
+		http.Error(w, "failed to cancel booking", http.StatusInternalServerError)
+	}
 }
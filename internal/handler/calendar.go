@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+// GetPropertyCalendar returns per-day availability, stay rules and price for
+// the month in ?month=YYYY-MM (default: the current month). Hosts and admins
+// also get the month's blocked ranges.
+func (h *Handler) GetPropertyCalendar(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	month := time.Now().UTC()
+	if value := r.URL.Query().Get("month"); value != "" {
+		month, err = time.Parse("2006-01", value)
+		if err != nil {
+			http.Error(w, "invalid month, use YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	currency, ok := requireDisplayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	property, err := h.repo.GetPropertyByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "property not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Unable to get a property", "Error", err)
+		http.Error(w, "failed to fetch calendar", http.StatusInternalServerError)
+		return
+	}
+
+	manager := canManageListing(r, property)
+	if property.Status != models.ListingPublished && !manager {
+		http.Error(w, "property not found", http.StatusNotFound)
+		return
+	}
+
+	calendar, err := h.repo.GetPropertyCalendar(id, month)
+	if err != nil {
+		h.cfg.Logger.Error("Unable to get property calendar", "Error", err)
+		http.Error(w, "failed to fetch calendar", http.StatusInternalServerError)
+		return
+	}
+
+	// Block reasons are the host's own notes.
+	if !manager {
+		calendar.Blocks = nil
+	}
+
+	if rate, err := h.rateTo(r.Context(), calendar.Currency, currency); err == nil && rate != nil {
+		for i := range calendar.Days {
+			calendar.Days[i].Price = rate.Convert(calendar.Days[i].Price)
+		}
+		calendar.Currency = currency
+	}
+
+	if err := helper.WriteJSON(w, calendar, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) PostCalendarBlock(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	var block models.CalendarBlock
+	if err := json.NewDecoder(r.Body).Decode(&block); err != nil {
+		h.cfg.Logger.Error("Invalid JSON body", "error", err)
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateDateRange(block.StartDate, block.EndDate); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	block.Reason = strings.TrimSpace(block.Reason)
+
+	blockID, err := h.repo.AddCalendarBlock(id, block, actorFromRequest(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "property not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrDatesUnavailable):
+			http.Error(w, "cannot block nights that are already booked", http.StatusConflict)
+		default:
+			h.cfg.Logger.Error("Failed to block dates", "error", err)
+			http.Error(w, "failed to block dates", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	block.ID = blockID
+	if err := helper.WriteJSON(w, block, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) DeleteCalendarBlock(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err1 := uuid.Parse(r.PathValue("id"))
+	blockID, err2 := uuid.Parse(r.PathValue("blockID"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteCalendarBlock(id, blockID, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "block not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to delete calendar block", "error", err)
+		http.Error(w, "failed to delete block", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetStayRules(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.repo.GetStayRules(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "property not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to get stay rules", "error", err)
+		http.Error(w, "failed to fetch stay rules", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, rules, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// PutStayRules replaces the property's default stay rule and its dated
+// overrides.
+func (h *Handler) PutStayRules(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	var rules models.StayRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		h.cfg.Logger.Error("Invalid JSON body", "error", err)
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := normalizeStayRules(&rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.SetStayRules(id, rules, actorFromRequest(r)); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "property not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrConflict):
+			http.Error(w, "stay rule overrides must not overlap", http.StatusBadRequest)
+		default:
+			h.cfg.Logger.Error("Failed to set stay rules", "error", err)
+			http.Error(w, "failed to update stay rules", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := helper.WriteJSON(w, rules, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// normalizeStayRules validates the rules, defaults the minimum stay to one
+// night and sorts check-in days and overrides.
+func normalizeStayRules(rules *models.StayRules) error {
+	def := &rules.Default
+	def.StartDate, def.EndDate = "", ""
+	if def.MinNights == nil {
+		one := 1
+		def.MinNights = &one
+	}
+	if err := validateStayRule(*def); err != nil {
+		return err
+	}
+
+	if rules.Overrides == nil {
+		rules.Overrides = []models.StayRule{}
+	}
+	for i := range rules.Overrides {
+		o := &rules.Overrides[i]
+		if err := validateDateRange(o.StartDate, o.EndDate); err != nil {
+			return fmt.Errorf("override %d: %w", i, err)
+		}
+		if err := validateStayRule(*o); err != nil {
+			return fmt.Errorf("override %d: %w", i, err)
+		}
+		if len(o.CheckInDays) == 0 {
+			o.CheckInDays = nil
+		}
+	}
+
+	slices.SortFunc(rules.Overrides, func(a, b models.StayRule) int { return strings.Compare(a.StartDate, b.StartDate) })
+	for i := 1; i < len(rules.Overrides); i++ {
+		if rules.Overrides[i].StartDate < rules.Overrides[i-1].EndDate {
+			return errors.New("stay rule overrides must not overlap")
+		}
+	}
+
+	return nil
+}
+
+func validateStayRule(rule models.StayRule) error {
+	if rule.MinNights != nil && *rule.MinNights < 1 {
+		return errors.New("min_nights must be at least 1")
+	}
+	if rule.MaxNights != nil && *rule.MaxNights < 1 {
+		return errors.New("max_nights must be at least 1")
+	}
+	if rule.MinNights != nil && rule.MaxNights != nil && *rule.MaxNights < *rule.MinNights {
+		return errors.New("max_nights must not be less than min_nights")
+	}
+
+	for _, d := range rule.CheckInDays {
+		if d < 0 || d > 6 {
+			return errors.New("check_in_days must be weekday numbers from 0 (Sunday) to 6")
+		}
+	}
+	slices.Sort(rule.CheckInDays)
+	if len(slices.Compact(slices.Clone(rule.CheckInDays))) != len(rule.CheckInDays) {
+		return errors.New("check_in_days must not repeat a day")
+	}
+
+	return nil
+}
+
+// validateDateRange checks a half-open YYYY-MM-DD range.
+func validateDateRange(start, end string) error {
+	startDate, err1 := time.Parse("2006-01-02", start)
+	endDate, err2 := time.Parse("2006-01-02", end)
+	if err1 != nil || err2 != nil {
+		return errors.New("invalid date format, use YYYY-MM-DD")
+	}
+	if !endDate.After(startDate) {
+		return errors.New("end_date must be after start_date")
+	}
+	return nil
+}
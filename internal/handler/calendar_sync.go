@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/ical"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+)
+
+const maxImportNameLength = 100
+
+// GetICalExport returns the property's secret iCal feed URL, creating the
+// feed on first use. POST .../export/rotate replaces the URL, cutting off
+// anyone holding the old one.
+func (h *Handler) GetICalExport(rotate bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.requireAdmin(w, r) {
+			return
+		}
+
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid property id", http.StatusBadRequest)
+			return
+		}
+
+		token, err := h.repo.ICalToken(id, rotate, actorFromRequest(r))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "property not found", http.StatusNotFound)
+				return
+			}
+			h.cfg.Logger.Error("Failed to get iCal token", "error", err)
+			http.Error(w, "failed to get calendar feed", http.StatusInternalServerError)
+			return
+		}
+
+		if err := helper.WriteJSON(w, map[string]string{"url": feedURL(r, token)}, http.StatusOK); err != nil {
+			h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+			http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+		}
+	}
+}
+
+// feedURL builds the public feed URL from the host the request came in on.
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/api/v1/ical/" + token + ".ics"
+}
+
+// ICalFeed serves the booked and blocked dates of the property owning the
+// token as an iCalendar feed. The token is the only credential.
+func (h *Handler) ICalFeed(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	title, blocks, err := h.repo.GetICalFeed(token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "calendar not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to get iCal feed", "error", err)
+		http.Error(w, "failed to get calendar", http.StatusInternalServerError)
+		return
+	}
+
+	events := make([]ical.Event, 0, len(blocks))
+	for _, b := range blocks {
+		start, _ := time.Parse("2006-01-02", b.StartDate)
+		end, _ := time.Parse("2006-01-02", b.EndDate)
+		events = append(events, ical.Event{
+			UID:     b.ID.String() + "@bookbnb",
+			Summary: b.Reason,
+			Start:   start,
+			End:     end,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := ical.Write(&buf, title, events, time.Now()); err != nil {
+		h.cfg.Logger.Error("Failed to encode iCal feed", "error", err)
+		http.Error(w, "failed to get calendar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(buf.Bytes())
+}
+
+func (h *Handler) GetCalendarImports(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	imports, err := h.repo.GetCalendarImports(id)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get calendar imports", "error", err)
+		http.Error(w, "failed to fetch calendar imports", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, imports, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// PostCalendarImport adds an external calendar. A JSON body with a name and
+// url registers a feed that the refresh job keeps in sync; a text/calendar
+// body (name in ?name=) or a multipart "calendar" file is imported once.
+func (h *Handler) PostCalendarImport(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		req    models.CalendarImportRequest
+		url    *string
+		events []ical.Event
+	)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/calendar", "multipart/form-data":
+		req.Name = r.URL.Query().Get("name")
+		events, err = readUploadedCalendar(w, r, mediaType)
+		if err != nil {
+			http.Error(w, "invalid calendar file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.cfg.Logger.Error("Invalid JSON body", "error", err)
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		normalized, err := ical.NormalizeURL(req.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		url = &normalized
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > maxImportNameLength {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	actor := actorFromRequest(r)
+	imp, err := h.repo.CreateCalendarImport(id, req.Name, url, actor)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "property not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to create calendar import", "error", err)
+		http.Error(w, "failed to import calendar", http.StatusInternalServerError)
+		return
+	}
+
+	if url == nil {
+		imp, err = h.repo.SyncCalendarImport(imp.ID, events, 0, actor)
+		if err != nil {
+			h.cfg.Logger.Error("Failed to apply uploaded calendar", "error", err)
+			http.Error(w, "failed to import calendar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := helper.WriteJSON(w, imp, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func readUploadedCalendar(w http.ResponseWriter, r *http.Request, mediaType string) ([]ical.Event, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, ical.MaxFeedSize+1<<16)
+
+	var body io.Reader = r.Body
+	if mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("calendar")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	return ical.Parse(body)
+}
+
+// SyncCalendarImport queues a feed import for the next refresh run.
+func (h *Handler) SyncCalendarImport(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err1 := uuid.Parse(r.PathValue("id"))
+	importID, err2 := uuid.Parse(r.PathValue("importID"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.ScheduleCalendarImport(id, importID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "calendar feed not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to schedule calendar sync", "error", err)
+		http.Error(w, "failed to schedule sync", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) DeleteCalendarImport(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err1 := uuid.Parse(r.PathValue("id"))
+	importID, err2 := uuid.Parse(r.PathValue("importID"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteCalendarImport(id, importID, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "calendar import not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to delete calendar import", "error", err)
+		http.Error(w, "failed to delete calendar import", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
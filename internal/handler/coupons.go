@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+const maxCouponDescriptionLength = 500
+
+var couponCode = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+func (h *Handler) GetCoupons(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	coupons, err := h.repo.GetCoupons()
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get coupons", "error", err)
+		http.Error(w, "failed to fetch coupons", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, coupons, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid coupon id", http.StatusBadRequest)
+		return
+	}
+
+	coupon, err := h.repo.GetCoupon(id)
+	if err != nil {
+		h.couponError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, coupon, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// PostCoupon creates a promo code; PUT .../{id} replaces one.
+func (h *Handler) PostCoupon(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	coupon, ok := decodeCoupon(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.repo.CreateCoupon(coupon, actorFromRequest(r))
+	if err != nil {
+		h.couponError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, created, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) PutCoupon(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid coupon id", http.StatusBadRequest)
+		return
+	}
+
+	coupon, ok := decodeCoupon(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.UpdateCoupon(id, coupon, actorFromRequest(r))
+	if err != nil {
+		h.couponError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, updated, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid coupon id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteCoupon(id, actorFromRequest(r)); err != nil {
+		h.couponError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) couponError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "coupon not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrConflict):
+		http.Error(w, "a coupon with this code already exists", http.StatusConflict)
+	case errors.Is(err, repository.ErrUnknownCouponScope):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.cfg.Logger.Error("Failed to save coupon", "error", err)
+		http.Error(w, "failed to save coupon", http.StatusInternalServerError)
+	}
+}
+
+func decodeCoupon(w http.ResponseWriter, r *http.Request) (models.Coupon, bool) {
+	var coupon models.Coupon
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return coupon, false
+	}
+
+	if err := normalizeCoupon(&coupon); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return coupon, false
+	}
+	return coupon, true
+}
+
+func normalizeCoupon(c *models.Coupon) error {
+	c.Code = strings.ToUpper(strings.TrimSpace(c.Code))
+	if !couponCode.MatchString(c.Code) {
+		return errors.New("code must be 3 to 32 letters, digits, '-' or '_'")
+	}
+	c.Description = strings.TrimSpace(c.Description)
+	if len([]rune(c.Description)) > maxCouponDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", maxCouponDescriptionLength)
+	}
+
+	switch c.Kind {
+	case models.CouponPercentage:
+		if c.Percent == nil || *c.Percent <= 0 || *c.Percent > 100 {
+			return errors.New("percentage coupons need a percent above 0 and at most 100")
+		}
+		c.Amount = nil
+	case models.CouponFixed:
+		if c.Amount == nil || c.Amount.Amount <= 0 {
+			return errors.New("fixed coupons need a positive amount")
+		}
+		currency, err := money.NormalizeCurrency(c.Amount.Currency)
+		if err != nil {
+			return err
+		}
+		c.Amount.Currency = currency
+		c.Percent = nil
+	default:
+		return fmt.Errorf("kind must be %q or %q", models.CouponPercentage, models.CouponFixed)
+	}
+
+	if c.ValidUntil != nil {
+		from := time.Now()
+		if c.ValidFrom != nil {
+			from = *c.ValidFrom
+		}
+		if !c.ValidUntil.After(from) {
+			return errors.New("valid_until must be after valid_from")
+		}
+	}
+
+	for _, limit := range []*int{c.MaxRedemptions, c.MaxRedemptionsPerUser} {
+		if limit != nil && *limit < 1 {
+			return errors.New("redemption limits must be at least 1")
+		}
+	}
+	if c.MinNights == 0 {
+		c.MinNights = 1
+	}
+	if c.MinNights < 1 {
+		return errors.New("min_nights must be at least 1")
+	}
+
+	if c.PropertyID != nil && c.HostID != nil {
+		return errors.New("a coupon can be limited to a property or a host, not both")
+	}
+
+	if c.Active == nil {
+		active := true
+		c.Active = &active
+	}
+	return nil
+}
+
+// ValidateCoupon checks a promo code against a stay and, when it can be
+// used, returns the quote with its discount in the display currency.
+// Signed-in guests are also held to the code's per-guest limit.
+func (h *Handler) ValidateCoupon(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code       string    `json:"code"`
+		PropertyID uuid.UUID `json:"property_id"`
+		StartDate  string    `json:"start_date"`
+		EndDate    string    `json:"end_date"`
+		partyRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateDateRange(req.StartDate, req.EndDate); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, _ := time.Parse("2006-01-02", req.StartDate)
+	end, _ := time.Parse("2006-01-02", req.EndDate)
+
+	party, err := req.party()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currency, ok := requireDisplayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	quote, err := h.repo.QuoteStay(models.StayRequest{
+		PropertyID: req.PropertyID,
+		StartDate:  start,
+		EndDate:    end,
+		Party:      party,
+		CouponCode: req.Code,
+		UserID:     actorFromRequest(r).UserID,
+	}, h.rates)
+	if err == nil {
+		quote, err = h.convertQuote(r.Context(), quote, currency)
+	}
+	if err != nil {
+		var couponErr *repository.CouponError
+		if errors.As(err, &couponErr) {
+			helper.WriteJSON(w, map[string]any{"valid": false, "error": couponErr.Problem}, http.StatusUnprocessableEntity)
+			return
+		}
+		h.quoteError(w, err, currency)
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"valid": true, "coupon": quote.Coupon, "quote": quote}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
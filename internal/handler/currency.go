@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
+)
+
+// displayCurrency is the currency the caller wants prices in: ?currency=
+// first, then the Accept-Currency header. "" means each property's own
+// currency.
+func displayCurrency(r *http.Request) (string, error) {
+	code := r.URL.Query().Get("currency")
+	if code == "" {
+		// Accept-Currency may list several codes; the first is preferred.
+		code, _, _ = strings.Cut(r.Header.Get("Accept-Currency"), ",")
+		code, _, _ = strings.Cut(code, ";")
+	}
+	if strings.TrimSpace(code) == "" {
+		return "", nil
+	}
+	return money.NormalizeCurrency(code)
+}
+
+// requireDisplayCurrency reads the display currency, answering 400 when it
+// is not supported.
+func requireDisplayCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	currency, err := displayCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return currency, true
+}
+
+// rateTo returns the rate from one currency to another, or nil when no
+// conversion is needed.
+func (h *Handler) rateTo(ctx context.Context, from, to string) (*fx.Rate, error) {
+	if to == "" || to == from {
+		return nil, nil
+	}
+	rate, err := h.rates.Rate(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// priceRates maps every supported currency to its rate into currency, for
+// price filters given in currency. Currencies without a rate are left out.
+func (h *Handler) priceRates(ctx context.Context, currency string) map[string]float64 {
+	rates := map[string]float64{}
+	for _, from := range money.Currencies() {
+		if rate, err := h.rates.Rate(ctx, from, currency); err == nil {
+			rates[from] = rate.Value
+		}
+	}
+	return rates
+}
+
+// convertQuote returns quote in currency.
+func (h *Handler) convertQuote(ctx context.Context, quote models.PriceQuote, currency string) (models.PriceQuote, error) {
+	rate, err := h.rateTo(ctx, quote.Currency, currency)
+	if err != nil || rate == nil {
+		return quote, err
+	}
+	return pricing.Convert(quote, *rate), nil
+}
+
+// convertListings converts the prices of search results into currency.
+// Listings in a currency without a rate keep their own; every result says
+// which currency it is in.
+func (h *Handler) convertListings(ctx context.Context, props []models.GetProperty, currency string) {
+	for i := range props {
+		p := &props[i]
+		rate, err := h.rateTo(ctx, p.Currency, currency)
+		if err != nil || rate == nil {
+			continue
+		}
+		p.PricePerNight, p.Currency = rate.Convert(p.PricePerNight), currency
+		if p.Quote != nil {
+			quote := pricing.Convert(*p.Quote, *rate)
+			p.Quote = &quote
+		}
+	}
+}
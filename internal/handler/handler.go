@@ -2,11 +2,18 @@ package handler
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/config"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/payments"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 )
 
 type envelope map[string]any
@@ -16,17 +23,64 @@ type envelope map[string]any
 // const userIDKey contextKey = "user_id"
 
 type Handler struct {
-	cfg  *config.Config
-	repo *repository.Repository
+	cfg   *config.Config
+	repo  *repository.Repository
+	store storage.Storage
+	rates fx.Provider
+	gate  payments.Provider
 }
 
-func NewHandler(cfg *config.Config, repo *repository.Repository) *Handler {
+func NewHandler(cfg *config.Config, repo *repository.Repository, store storage.Storage, rates fx.Provider, gate payments.Provider) *Handler {
 	return &Handler{
-		cfg:  cfg,
-		repo: repo,
+		cfg:   cfg,
+		repo:  repo,
+		store: store,
+		rates: rates,
+		gate:  gate,
 	}
 }
 
+// actorFromRequest describes who is making the request so repository writes
+// can be attributed in the audit log.
+func actorFromRequest(r *http.Request) models.Actor {
+	actor := models.Actor{
+		RequestID: middleware.GetReqID(r.Context()),
+		IP:        r.RemoteAddr,
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		actor.IP = host
+	}
+
+	if userID, ok := r.Context().Value("userID").(string); ok {
+		if id, err := uuid.Parse(userID); err == nil {
+			actor.UserID = id
+		}
+	}
+
+	return actor
+}
+
+// requireAdmin writes a 401/403 and returns false unless the request was
+// made by an admin.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	roleVal := r.Context().Value("role")
+	if roleVal == nil {
+		h.cfg.Logger.Error("Missing role in context")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	role, ok := roleVal.(string)
+	if !ok || role != "admin" {
+		h.cfg.Logger.Error("Forbidden: insufficient permission", "role", roleVal)
+		http.Error(w, "forbidden: admin only", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
 func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 	data := envelope{
 		"status": "available",
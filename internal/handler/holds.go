@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CreateHold reserves a stay's dates for a few minutes at the quoted price
+// while the guest checks out. The body is a booking's, without a payment
+// method, plus an optional number of minutes to hold for.
+func (h *Handler) CreateHold(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		bookingRequest
+		Minutes int `json:"minutes"`
+	}
+
+	currency, ok := requireDisplayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	holdFor := h.cfg.Holds.Default
+	if req.Minutes < 0 {
+		http.Error(w, "minutes must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.Minutes > 0 {
+		holdFor = min(time.Duration(req.Minutes)*time.Minute, h.cfg.Holds.Max)
+	}
+
+	actor := actorFromRequest(r)
+	stay, err := req.stay(actor.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, expiresAt, quote, err := h.repo.HoldStay(stay, currency, req.ExpectedTotal, h.rates, holdFor, repository.HoldLimits{
+		PerUser:     h.cfg.Holds.MaxPerUser,
+		PerProperty: h.cfg.Holds.MaxPerProperty,
+	}, actor)
+	if err != nil {
+		h.bookingError(w, err, currency, quote)
+		return
+	}
+
+	res := map[string]any{"id": id, "status": models.BookingHeld, "hold_expires_at": expiresAt, "quote": quote}
+	if err := helper.WriteJSON(w, res, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+	}
+}
+
+// ConfirmHold turns the caller's hold into a booking at the held price and
+// collects its payment, answering like CreateBooking.
+func (h *Handler) ConfirmHold(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid hold id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	actor := actorFromRequest(r)
+	payment, quote, err := h.repo.ConfirmHold(id, actor.UserID, h.gate.Name(), h.cfg.Payments.Timeout, actor)
+	if err != nil {
+		h.holdError(w, err)
+		return
+	}
+
+	h.writeBookingPayment(w, r, payment, req.PaymentMethod, quote, actor)
+}
+
+// ReleaseHold lets the guest give back a hold's dates before it expires.
+func (h *Handler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid hold id", http.StatusBadRequest)
+		return
+	}
+
+	actor := actorFromRequest(r)
+	if err := h.repo.ReleaseHold(id, actor.UserID, actor); err != nil {
+		h.holdError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetHoldReport shows how many holds placed between from and to (YYYY-MM-DD,
+// to exclusive, defaulting to the current month) were confirmed, expired or
+// released, and how long confirming took.
+func (h *Handler) GetHoldReport(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	q := r.URL.Query()
+	for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := q.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "invalid "+param+", use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		*dst = t
+	}
+	if !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.repo.HoldReport(from, to)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to build hold report", "error", err)
+		http.Error(w, "failed to build hold report", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, report, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) holdError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "hold not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrNotHeld):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, repository.ErrHoldExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+	default:
+		h.cfg.Logger.Error("Failed hold update", "Error", err)
+		http.Error(w, "failed to update hold", http.StatusInternalServerError)
+	}
+}
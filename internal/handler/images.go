@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/imaging"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+const maxUploadFiles = 10
+
+// allowedImageTypes maps sniffed content types to the extension used for
+// the stored blob. Only formats internal/imaging can decode are accepted.
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (h *Handler) UploadPropertyImages(w http.ResponseWriter, r *http.Request) {
+	userVal := r.Context().Value("userID")
+	roleVal := r.Context().Value("role")
+
+	if userVal == nil || roleVal == nil {
+		h.cfg.Logger.Error("Missing user or role in context")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	role, ok := roleVal.(string)
+	if !ok {
+		h.cfg.Logger.Error("Invalid role type in context")
+		http.Error(w, "invalid role type", http.StatusInternalServerError)
+		return
+	}
+
+	if role != "admin" {
+		h.cfg.Logger.Error("Forbidden: insufficient permission", "role", role)
+		http.Error(w, "forbidden: only admin can add images", http.StatusForbidden)
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	maxFileSize := h.cfg.Storage.MaxUploadSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileSize*maxUploadFiles+1<<20)
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["images"]
+	captions := r.MultipartForm.Value["captions"]
+
+	if len(files) == 0 {
+		http.Error(w, "no images provided", http.StatusBadRequest)
+		return
+	}
+
+	if len(files) > maxUploadFiles {
+		http.Error(w, fmt.Sprintf("at most %d images per upload", maxUploadFiles), http.StatusBadRequest)
+		return
+	}
+
+	req := models.AddImagesRequest{PropertyID: propertyID.String()}
+	var stored []string
+
+	// Remove whatever was already written if a later file or the insert fails.
+	cleanup := func() {
+		for _, key := range stored {
+			if err := h.store.Delete(r.Context(), key); err != nil {
+				h.cfg.Logger.Error("Failed to clean up uploaded image", "key", key, "error", err)
+			}
+		}
+	}
+
+	for i, fh := range files {
+		if fh.Size > maxFileSize {
+			cleanup()
+			http.Error(w, fmt.Sprintf("%s exceeds the %d byte limit", fh.Filename, maxFileSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		data, contentType, err := readUpload(fh, maxFileSize)
+		if err != nil {
+			cleanup()
+			h.cfg.Logger.Error("Failed to read uploaded image", "file", fh.Filename, "error", err)
+			http.Error(w, "failed to read "+fh.Filename, http.StatusBadRequest)
+			return
+		}
+
+		ext, ok := allowedImageTypes[contentType]
+		if !ok {
+			cleanup()
+			http.Error(w, fmt.Sprintf("%s is not a supported image (%s)", fh.Filename, contentType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if contentType == "image/jpeg" {
+			data = imaging.StripGPS(data)
+		}
+
+		key := fmt.Sprintf("properties/%s/%s%s", propertyID, uuid.New(), ext)
+		url, err := h.store.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType)
+		if err != nil {
+			cleanup()
+			h.cfg.Logger.Error("Failed to store uploaded image", "key", key, "error", err)
+			http.Error(w, "failed to store image", http.StatusInternalServerError)
+			return
+		}
+		stored = append(stored, key)
+
+		caption := ""
+		if i < len(captions) {
+			caption = captions[i]
+		}
+
+		req.Images = append(req.Images, models.AddImage{
+			ImageURL:   url,
+			Caption:    caption,
+			StorageKey: key,
+		})
+	}
+
+	ids, err := h.repo.PostPropertyImages(req, actorFromRequest(r))
+	if err != nil {
+		cleanup()
+		h.cfg.Logger.Error("Failed to add property images", "error", err)
+		http.Error(w, "failed to add images", http.StatusInternalServerError)
+		return
+	}
+
+	uploaded := make([]models.UploadedImage, len(ids))
+	for i, id := range ids {
+		uploaded[i] = models.UploadedImage{
+			ImageID:  id,
+			ImageURL: req.Images[i].ImageURL,
+			Caption:  req.Images[i].Caption,
+		}
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"images": uploaded}, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// readUpload reads a multipart file and sniffs its content type from the
+// bytes rather than trusting the client-supplied header.
+func readUpload(fh *multipart.FileHeader, limit int64) ([]byte, string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("file exceeds %d bytes", limit)
+	}
+
+	return data, http.DetectContentType(data), nil
+}
+
+func (h *Handler) UpdatePropertyImage(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	imageID, err := uuid.Parse(r.PathValue("imageID"))
+	if err != nil {
+		http.Error(w, "invalid image id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.cfg.Logger.Error("Invalid JSON body", "error", err)
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Caption == nil && !req.Cover {
+		http.Error(w, "nothing to update: provide caption and/or cover", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.UpdatePropertyImage(propertyID, imageID, req, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "image not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to update property image", "error", err)
+		http.Error(w, "failed to update image", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully image updated"}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) ReorderPropertyImages(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReorderImagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.cfg.Logger.Error("Invalid JSON body", "error", err)
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.ImageIDs) == 0 {
+		http.Error(w, "image_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.ReorderPropertyImages(propertyID, req.ImageIDs, actorFromRequest(r)); err != nil {
+		if errors.Is(err, repository.ErrInvalidImageOrder) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.cfg.Logger.Error("Failed to reorder property images", "error", err)
+		http.Error(w, "failed to reorder images", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully images reordered"}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ListingAction returns a handler that applies a listing workflow action
+// (submit, approve, reject, suspend, reinstate, archive, unarchive) to the
+// property in the {id} path parameter. Reject and suspend take a JSON body
+// with a reason.
+func (h *Handler) ListingAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.requireAdmin(w, r) {
+			return
+		}
+
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid property id", http.StatusBadRequest)
+			return
+		}
+
+		var req models.ListingActionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			h.cfg.Logger.Error("Invalid JSON body", "error", err)
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.repo.TransitionListing(id, action, strings.TrimSpace(req.Reason), actorFromRequest(r))
+		if err != nil {
+			var incomplete *repository.IncompleteListingError
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "property not found", http.StatusNotFound)
+			case errors.As(err, &incomplete):
+				helper.WriteJSON(w, map[string]any{"error": "listing is incomplete", "problems": incomplete.Problems}, http.StatusUnprocessableEntity)
+			case errors.Is(err, repository.ErrInvalidTransition):
+				http.Error(w, "cannot "+action+" a listing in its current status", http.StatusConflict)
+			case errors.Is(err, repository.ErrReasonRequired):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				h.cfg.Logger.Error("Failed to update listing status", "action", action, "error", err)
+				http.Error(w, "failed to update listing", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if err := helper.WriteJSON(w, map[string]any{"id": id, "status": status}, http.StatusOK); err != nil {
+			h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+			http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+		}
+	}
+}
+
+func (h *Handler) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	items, err := h.repo.GetModerationQueue()
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get moderation queue", "error", err)
+		http.Error(w, "failed to fetch moderation queue", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, items, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
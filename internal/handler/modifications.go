@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+// errModificationPayment is returned when a modification's price increase
+// could not be collected.
+var errModificationPayment = errors.New("payment for the price difference failed")
+
+// RequestModification lets a guest change the dates or party of a
+// confirmed booking. Changes within the booked nights that add no one are
+// applied at once (201); others wait for the host (202).
+func (h *Handler) RequestModification(w http.ResponseWriter, r *http.Request) {
+	bookingID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid booking id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		partyRequest
+		// PaymentMethod is charged if the new stay costs more.
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	startDate, err1 := time.Parse("2006-01-02", req.StartDate)
+	endDate, err2 := time.Parse("2006-01-02", req.EndDate)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if !endDate.After(startDate) {
+		http.Error(w, "End date must be after start date", http.StatusBadRequest)
+		return
+	}
+	party, err := req.party()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actor := actorFromRequest(r)
+	stay := models.StayRequest{
+		StartDate: startDate,
+		EndDate:   endDate,
+		Party:     party,
+		UserID:    actor.UserID,
+		BookingID: bookingID,
+	}
+
+	mod, err := h.repo.RequestModification(stay, strings.TrimSpace(req.PaymentMethod), h.rates, time.Now().UTC(), actor)
+	if err != nil {
+		h.modificationError(w, err)
+		return
+	}
+
+	if mod.RequiresApproval {
+		helper.WriteJSON(w, mod, http.StatusAccepted)
+		return
+	}
+
+	mod, err = h.applyModification(r.Context(), mod, actor)
+	if err != nil {
+		h.modificationError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, mod, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+	}
+}
+
+// GetModifications lists the changes requested to a booking, for its guest
+// or the listing's host.
+func (h *Handler) GetModifications(w http.ResponseWriter, r *http.Request) {
+	bookingID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid booking id", http.StatusBadRequest)
+		return
+	}
+
+	guestID, err := h.repo.BookingGuestID(bookingID)
+	if err != nil {
+		h.modificationError(w, err)
+		return
+	}
+	if guestID != actorFromRequest(r).UserID {
+		ok, err := h.canManageBooking(r, bookingID)
+		if err != nil {
+			h.modificationError(w, err)
+			return
+		}
+		if !ok {
+			http.Error(w, "booking not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	mods, err := h.repo.GetBookingModifications(bookingID)
+	if err != nil {
+		h.modificationError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, mods, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// AcceptModification lets the listing's host, or an admin, approve a
+// pending change. The guest is charged or refunded the difference.
+func (h *Handler) AcceptModification(w http.ResponseWriter, r *http.Request) {
+	mod, ok := h.pendingModification(w, r)
+	if !ok {
+		return
+	}
+
+	mod, err := h.applyModification(r.Context(), mod, actorFromRequest(r))
+	if err != nil {
+		h.modificationError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, mod, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+	}
+}
+
+// DeclineModification lets the listing's host, or an admin, turn down a
+// pending change with an optional reason. The booking is left as it was.
+func (h *Handler) DeclineModification(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	mod, ok := h.pendingModification(w, r)
+	if !ok {
+		return
+	}
+
+	mod, err := h.repo.CloseModification(mod.ID, models.ModificationDeclined, strings.TrimSpace(req.Reason), actorFromRequest(r))
+	if err != nil {
+		h.modificationError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, mod, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// pendingModification loads the modification in the request path, writing
+// an error and returning false unless it is pending and the caller manages
+// the booked listing.
+func (h *Handler) pendingModification(w http.ResponseWriter, r *http.Request) (models.BookingModification, bool) {
+	bookingID, err1 := uuid.Parse(r.PathValue("id"))
+	modID, err2 := uuid.Parse(r.PathValue("modID"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return models.BookingModification{}, false
+	}
+
+	ok, err := h.canManageBooking(r, bookingID)
+	if err != nil {
+		h.modificationError(w, err)
+		return models.BookingModification{}, false
+	}
+	if !ok {
+		http.Error(w, "forbidden: not your listing", http.StatusForbidden)
+		return models.BookingModification{}, false
+	}
+
+	mod, err := h.repo.GetModification(bookingID, modID)
+	if err == nil && mod.Status != models.ModificationPending {
+		err = repository.ErrModificationState
+	}
+	if err != nil {
+		h.modificationError(w, err)
+		return mod, false
+	}
+	return mod, true
+}
+
+// canManageBooking reports whether the caller manages the listing a booking
+// is for.
+func (h *Handler) canManageBooking(r *http.Request, bookingID uuid.UUID) (bool, error) {
+	propertyID, err := h.repo.BookingPropertyID(bookingID)
+	if err != nil {
+		return false, err
+	}
+	property, err := h.repo.GetPropertyByID(propertyID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	return canManageListing(r, property), nil
+}
+
+// applyModification collects a modification's price increase, applies it to
+// the booking and refunds a decrease. When the increase cannot be captured
+// straight away the modification fails; a capture arriving later is
+// refunded. If the booking cannot take the change after all, what was
+// collected for it is refunded.
+func (h *Handler) applyModification(ctx context.Context, mod models.BookingModification, actor models.Actor) (models.BookingModification, error) {
+	var charge *models.Payment
+	if mod.PaymentDelta > 0 {
+		payment, err := h.repo.CreateModificationPayment(mod, h.gate.Name(), h.cfg.Payments.Timeout)
+		if err != nil {
+			return mod, err
+		}
+		payment = h.collectPayment(ctx, payment, mod.PaymentMethod, actor)
+		if payment.Status == models.PaymentAuthorized {
+			// the capture did not go through; release the funds held for it
+			if _, err := h.updatePayment(ctx, payment, models.PaymentFailed, "", errModificationPayment.Error(), actor); err != nil {
+				h.cfg.Logger.Error("Failed to release payment", "payment", payment.ID, "Error", err)
+			}
+		}
+		if payment.Status != models.PaymentCaptured {
+			if _, err := h.repo.CloseModification(mod.ID, models.ModificationPaymentFailed, errModificationPayment.Error(), actor); err != nil {
+				return mod, err
+			}
+			return mod, errModificationPayment
+		}
+		charge = &payment
+	}
+
+	applied, err := h.repo.ApplyModification(mod.ID, actor)
+	if err != nil {
+		if charge != nil {
+			if _, err := h.refundPayment(ctx, *charge, charge.Amount, "booking modification not applied", actor); err != nil {
+				h.cfg.Logger.Error("Failed to refund payment", "payment", charge.ID, "Error", err)
+			}
+		}
+		var stayErr *repository.StayRuleError
+		if errors.Is(err, repository.ErrDatesUnavailable) || errors.As(err, &stayErr) {
+			if _, err := h.repo.CloseModification(mod.ID, models.ModificationDeclined, err.Error(), actor); err != nil {
+				h.cfg.Logger.Error("Failed to close modification", "modification", mod.ID, "Error", err)
+			}
+		}
+		return mod, err
+	}
+
+	if applied.PaymentDelta < 0 {
+		h.refundDifference(ctx, applied, -applied.PaymentDelta, actor)
+	}
+
+	if applied.Payments, err = h.repo.GetBookingPayments(applied.BookingID); err != nil {
+		h.cfg.Logger.Error("Failed to get booking payments", "Error", err)
+	}
+	return applied, nil
+}
+
+// refundDifference refunds amount of a booking's captured payments after a
+// modification made it cheaper, newest payments first. Failed refunds are
+// logged and can be seen on the payments.
+func (h *Handler) refundDifference(ctx context.Context, mod models.BookingModification, amount money.Amount, actor models.Actor) {
+	payments, err := h.repo.GetBookingPayments(mod.BookingID)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get booking payments", "Error", err)
+		return
+	}
+
+	for i := len(payments) - 1; i >= 0 && amount > 0; i-- {
+		p := payments[i]
+		if p.Status != models.PaymentCaptured && p.Status != models.PaymentPartiallyRefunded {
+			continue
+		}
+		part := min(amount, p.Amount-p.RefundedAmount)
+		if part <= 0 {
+			continue
+		}
+		if _, err := h.refundPayment(ctx, p, part, "booking modified", actor); err != nil {
+			h.cfg.Logger.Error("Failed to refund payment", "payment", p.ID, "Error", err)
+			continue
+		}
+		amount -= part
+	}
+}
+
+func (h *Handler) modificationError(w http.ResponseWriter, err error) {
+	var stayErr *repository.StayRuleError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "booking not found", http.StatusNotFound)
+	case errors.As(err, &stayErr):
+		http.Error(w, stayErr.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, repository.ErrNothingToModify):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errModificationPayment):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, repository.ErrDatesUnavailable):
+		http.Error(w, "the property is not available for these dates", http.StatusConflict)
+	case errors.Is(err, repository.ErrNotModifiable), errors.Is(err, repository.ErrStayCompleted),
+		errors.Is(err, repository.ErrModificationPending), errors.Is(err, repository.ErrModificationState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, fx.ErrNoRate):
+		http.Error(w, "the new price cannot be converted to the booking's currency", http.StatusUnprocessableEntity)
+	default:
+		h.cfg.Logger.Error("Failed booking modification", "Error", err)
+		http.Error(w, "failed to modify booking", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/payments"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+)
+
+const maxWebhookSize = 1 << 20
+
+// collectPayment authorizes and captures a new booking's payment with the
+// guest's payment method. When the gateway cannot be reached the payment is
+// left pending, to be settled by a webhook or expired with the booking's
+// hold.
+func (h *Handler) collectPayment(ctx context.Context, payment models.Payment, method string, actor models.Actor) models.Payment {
+	res, err := h.gate.Authorize(ctx, payments.Charge{
+		Reference: payment.ID.String(),
+		Amount:    money.Money{Amount: payment.Amount, Currency: payment.Currency},
+		Method:    method,
+	})
+	if err == nil {
+		payment, err = h.settlePayment(ctx, payment, res, actor)
+	}
+	if err != nil {
+		h.cfg.Logger.Error("Failed to collect payment", "payment", payment.ID, "error", err)
+	}
+	return payment
+}
+
+// settlePayment records the gateway's answer for a payment, and captures
+// it once it is authorized.
+func (h *Handler) settlePayment(ctx context.Context, payment models.Payment, res payments.Result, actor models.Actor) (models.Payment, error) {
+	switch res.Status {
+	case payments.StatusPending:
+		return h.updatePayment(ctx, payment, models.PaymentPending, res.Ref, "", actor)
+	case payments.StatusFailed:
+		return h.updatePayment(ctx, payment, models.PaymentFailed, res.Ref, res.FailureReason, actor)
+	case payments.StatusCaptured:
+		return h.updatePayment(ctx, payment, models.PaymentCaptured, res.Ref, "", actor)
+	case payments.StatusAuthorized:
+	default:
+		return payment, errors.New("unexpected payment status " + res.Status)
+	}
+
+	payment, err := h.updatePayment(ctx, payment, models.PaymentAuthorized, res.Ref, "", actor)
+	if err != nil {
+		return payment, err
+	}
+
+	res, err = h.gate.Capture(ctx, *payment.ProviderRef, money.Money{Amount: payment.Amount, Currency: payment.Currency})
+	if err != nil {
+		// left authorized; it expires with the booking's hold
+		return payment, err
+	}
+	if res.Status != payments.StatusCaptured {
+		return h.updatePayment(ctx, payment, models.PaymentFailed, "", res.FailureReason, actor)
+	}
+	return h.updatePayment(ctx, payment, models.PaymentCaptured, "", "", actor)
+}
+
+// updatePayment moves a payment to status, refunding it in full if it was
+// captured after its booking had already been released, and voiding its
+// authorization if an authorized payment fails.
+func (h *Handler) updatePayment(ctx context.Context, payment models.Payment, status, ref, reason string, actor models.Actor) (models.Payment, error) {
+	updated, err := h.repo.UpdatePaymentStatus(payment.ID, status, ref, reason, actor)
+	if errors.Is(err, repository.ErrBookingReleased) {
+		return h.refundPayment(ctx, updated, updated.Amount, "booking released before payment was captured", actor)
+	}
+	if err != nil {
+		return payment, err
+	}
+	if payment.Status == models.PaymentAuthorized && (status == models.PaymentFailed || status == models.PaymentExpired) {
+		h.voidPayment(ctx, updated)
+	}
+	return updated, nil
+}
+
+// voidPayment releases the authorization of a payment that will not be
+// captured. A failure is only logged: the funds stay held until the
+// authorization lapses on the gateway's side.
+func (h *Handler) voidPayment(ctx context.Context, payment models.Payment) {
+	if payment.ProviderRef == nil {
+		return
+	}
+	if err := h.gate.Void(ctx, *payment.ProviderRef); err != nil {
+		h.cfg.Logger.Error("Failed to void payment", "payment", payment.ID, "Error", err)
+	}
+}
+
+// refundPayment returns amount of a captured payment to the guest.
+func (h *Handler) refundPayment(ctx context.Context, payment models.Payment, amount money.Amount, reason string, actor models.Actor) (models.Payment, error) {
+	if payment.ProviderRef == nil {
+		return payment, repository.ErrPaymentState
+	}
+
+	refund, err := h.gate.Refund(ctx, *payment.ProviderRef, money.Money{Amount: amount, Currency: payment.Currency})
+	if err != nil {
+		return payment, err
+	}
+
+	return h.repo.RecordRefund(payment.ID, amount, refund.Ref, reason, actor)
+}
+
+// settleRefund pays back the refund due on a payment. A refund the gateway
+// turns down is recorded on the payment, which is returned as it stands.
+func (h *Handler) settleRefund(ctx context.Context, payment models.Payment, actor models.Actor) models.Payment {
+	if payment.ProviderRef == nil {
+		return payment
+	}
+
+	refund, err := h.gate.Refund(ctx, *payment.ProviderRef, money.Money{Amount: payment.RefundDue, Currency: payment.Currency})
+	if err != nil {
+		h.cfg.Logger.Error("Failed to refund payment", "payment", payment.ID, "Error", err)
+		failed, err := h.repo.RecordRefundFailure(payment.ID, err.Error(), actor)
+		if err != nil {
+			h.cfg.Logger.Error("Failed to record refund failure", "payment", payment.ID, "Error", err)
+			return payment
+		}
+		return failed
+	}
+
+	refunded, err := h.repo.RecordRefund(payment.ID, payment.RefundDue, refund.Ref, payment.RefundReason, actor)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to record refund", "payment", payment.ID, "refund", refund.Ref, "Error", err)
+		return payment
+	}
+	return refunded
+}
+
+// PaymentWebhook applies an event sent by the payment gateway. Deliveries
+// the gateway retries are acknowledged without being applied again.
+func (h *Handler) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		http.Error(w, "invalid webhook body", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.gate.ParseWebhook(body, r.Header)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	provider := h.gate.Name()
+	isNew, err := h.repo.RecordPaymentEvent(provider, event.ID, event.Type, body)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to record payment event", "error", err)
+		http.Error(w, "failed to record event", http.StatusInternalServerError)
+		return
+	}
+	if !isNew {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.applyPaymentEvent(r.Context(), provider, event, actorFromRequest(r)); err != nil {
+		h.cfg.Logger.Error("Failed to apply payment event", "event", event.ID, "error", err)
+		// forget the delivery so the gateway's retry is applied
+		if err := h.repo.ForgetPaymentEvent(provider, event.ID); err != nil {
+			h.cfg.Logger.Error("Failed to forget payment event", "event", event.ID, "error", err)
+		}
+		http.Error(w, "failed to apply event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) applyPaymentEvent(ctx context.Context, provider string, event payments.Event, actor models.Actor) error {
+	payment, err := h.repo.GetPaymentByRef(provider, event.Ref)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.cfg.Logger.Error("Payment event for unknown payment", "event", event.ID, "ref", event.Ref)
+			return nil
+		}
+		return err
+	}
+
+	switch event.Type {
+	case payments.EventAuthorized:
+		_, err = h.settlePayment(ctx, payment, payments.Result{Ref: event.Ref, Status: payments.StatusAuthorized}, actor)
+	case payments.EventCaptured:
+		_, err = h.updatePayment(ctx, payment, models.PaymentCaptured, "", "", actor)
+	case payments.EventFailed:
+		_, err = h.updatePayment(ctx, payment, models.PaymentFailed, "", event.FailureReason, actor)
+	default:
+		// refunds are recorded when they are made
+		return nil
+	}
+
+	// the event was already applied by the request that made the payment
+	if errors.Is(err, repository.ErrPaymentState) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+const maxRateRules = 100
+
+func (h *Handler) GetPricingRules(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	rules, err := h.repo.GetPricingRules(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "property not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to get pricing rules", "error", err)
+		http.Error(w, "failed to fetch pricing rules", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, rules, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// PutPricingRules replaces the property's fees, discounts and rate rules.
+func (h *Handler) PutPricingRules(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	var rules models.PricingRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		h.cfg.Logger.Error("Invalid JSON body", "error", err)
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := normalizePricingRules(&rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.SetPricingRules(id, rules, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "property not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to set pricing rules", "error", err)
+		http.Error(w, "failed to update pricing rules", http.StatusInternalServerError)
+		return
+	}
+
+	rules, err = h.repo.GetPricingRules(id)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get pricing rules", "error", err)
+		http.Error(w, "failed to fetch pricing rules", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, rules, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func normalizePricingRules(rules *models.PricingRules) error {
+	if rules.CleaningFee < 0 || rules.ExtraGuestFee < 0 {
+		return errors.New("fees must not be negative")
+	}
+	if rules.GuestsIncluded == 0 {
+		rules.GuestsIncluded = 1
+	}
+	if rules.GuestsIncluded < 1 {
+		return errors.New("guests_included must be at least 1")
+	}
+	for _, pct := range []float64{rules.WeeklyDiscountPercent, rules.MonthlyDiscountPercent} {
+		if pct < 0 || pct > 100 {
+			return errors.New("discount percentages must be between 0 and 100")
+		}
+	}
+
+	if rules.Rates == nil {
+		rules.Rates = []models.RateRule{}
+	}
+	if len(rules.Rates) > maxRateRules {
+		return fmt.Errorf("at most %d rate rules are allowed", maxRateRules)
+	}
+	for i := range rules.Rates {
+		rate := &rules.Rates[i]
+		rate.Name = strings.TrimSpace(rate.Name)
+
+		if rate.PricePerNight < 0 {
+			return fmt.Errorf("rate %d: price_per_night must not be negative", i)
+		}
+		if rate.StartDate != "" || rate.EndDate != "" {
+			if err := validateDateRange(rate.StartDate, rate.EndDate); err != nil {
+				return fmt.Errorf("rate %d: %w", i, err)
+			}
+		}
+		for _, d := range rate.Weekdays {
+			if d < 0 || d > 6 {
+				return fmt.Errorf("rate %d: weekdays must be numbers from 0 (Sunday) to 6", i)
+			}
+		}
+		slices.Sort(rate.Weekdays)
+		rate.Weekdays = slices.Compact(rate.Weekdays)
+
+		if rate.StartDate == "" && len(rate.Weekdays) == 0 {
+			return fmt.Errorf("rate %d: needs a date range, weekdays or both", i)
+		}
+	}
+
+	return nil
+}
+
+// GetQuote prices a stay from ?start_date to ?end_date (check-out) for
+// ?guests, with the nightly breakdown, discounts and fees, in the display
+// currency. ?coupon applies a promo code; signed-in guests are also held to
+// its per-guest limit.
+func (h *Handler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	if err := validateDateRange(q.Get("start_date"), q.Get("end_date")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, _ := time.Parse("2006-01-02", q.Get("start_date"))
+	end, _ := time.Parse("2006-01-02", q.Get("end_date"))
+
+	party, err := partyFromQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currency, ok := requireDisplayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	quote, err := h.repo.QuoteStay(models.StayRequest{
+		PropertyID: id,
+		StartDate:  start,
+		EndDate:    end,
+		Party:      party,
+		CouponCode: strings.TrimSpace(q.Get("coupon")),
+		UserID:     actorFromRequest(r).UserID,
+	}, h.rates)
+	if err == nil {
+		quote, err = h.convertQuote(r.Context(), quote, currency)
+	}
+	if err != nil {
+		h.quoteError(w, err, currency)
+		return
+	}
+
+	if err := helper.WriteJSON(w, quote, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) quoteError(w http.ResponseWriter, err error, currency string) {
+	var (
+		stayErr   *repository.StayRuleError
+		couponErr *repository.CouponError
+	)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, repository.ErrListingUnavailable):
+		http.Error(w, "property not found", http.StatusNotFound)
+	case errors.As(err, &stayErr):
+		http.Error(w, stayErr.Error(), http.StatusUnprocessableEntity)
+	case errors.As(err, &couponErr):
+		http.Error(w, couponErr.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, repository.ErrDatesUnavailable):
+		http.Error(w, "the property is not available for these dates", http.StatusConflict)
+	case errors.Is(err, fx.ErrNoRate):
+		http.Error(w, "prices cannot be shown in "+currency, http.StatusUnprocessableEntity)
+	default:
+		h.cfg.Logger.Error("Failed to quote stay", "error", err)
+		http.Error(w, "failed to quote stay", http.StatusInternalServerError)
+	}
+}
@@ -1,24 +1,51 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/geo"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
 	"github.com/google/uuid"
 )
 
+const (
+	defaultSearchRadiusKm = 10.0
+	maxSearchRadiusKm     = 500.0
+)
+
 func (h *Handler) GetAllProperties(w http.ResponseWriter, r *http.Request) {
-	properties, err := h.repo.GetAllProperties()
+	searchParams, err := parseSearchParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if searchParams.Currency != "" {
+		searchParams.PriceRates = h.priceRates(r.Context(), searchParams.Currency)
+	}
+
+	properties, err := h.repo.GetAllProperties(searchParams)
 	if err != nil {
 		h.cfg.Logger.Error("Unable to get all properties", "Error", err)
 		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
 		return
 	}
+	h.convertListings(r.Context(), properties, searchParams.Currency)
 
 	if err := helper.WriteJSON(w, properties, http.StatusCreated); err != nil {
 		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
@@ -36,12 +63,94 @@ func (h *Handler) GetPropertyByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Unpublished listings are only visible to their host and admins.
+	if property.Status != models.ListingPublished && !canManageListing(r, property) {
+		http.Error(w, "property not found", http.StatusNotFound)
+		return
+	}
+
+	if !canManageListing(r, property) {
+		property.ReviewNote = nil
+	}
+
+	exact, err := h.canSeeExactLocation(r, property)
+	if err != nil {
+		h.cfg.Logger.Error("Unable to check booking for location access", "Error", err)
+		http.Error(w, "failed to fetch property", http.StatusInternalServerError)
+		return
+	}
+
+	property.LocationExact = exact
+	if !exact {
+		property.Latitude, property.Longitude = property.PublicLatitude, property.PublicLongitude
+	}
+
+	currency, err := displayCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rate, err := h.rateTo(r.Context(), property.Currency, currency); err == nil && rate != nil {
+		property.DisplayPrice = &models.DisplayPrice{
+			PricePerNight: money.Money{Amount: rate.Convert(property.PricePerNight), Currency: currency},
+			ExchangeRate:  *rate,
+		}
+	}
+
+	// The body differs per viewer (exact location, review notes), so caches
+	// must key on the credentials too. Images, their derivatives and
+	// amenities change without a new version, so the ETag also covers the
+	// body.
+	body, err := json.Marshal(property)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+		return
+	}
+	etag := propertyContentETag(property.Version, body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Authorization, Accept-Currency")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	if err := helper.WriteJSON(w, property, http.StatusCreated); err != nil {
 		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
 		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
 	}
 }
 
+// canManageListing reports whether the caller is an admin or the listing's
+// host.
+func canManageListing(r *http.Request, property models.Property) bool {
+	if role, _ := r.Context().Value("role").(string); role == "admin" {
+		return true
+	}
+
+	userID := actorFromRequest(r).UserID
+	return userID != uuid.Nil && property.HostID.Valid && property.HostID.UUID == userID
+}
+
+// canSeeExactLocation reports whether the caller may see the property's real
+// coordinates: admins, the host, and guests with a confirmed booking.
+func (h *Handler) canSeeExactLocation(r *http.Request, property models.Property) (bool, error) {
+	if property.Latitude == nil {
+		return false, nil
+	}
+
+	if canManageListing(r, property) {
+		return true, nil
+	}
+
+	userID := actorFromRequest(r).UserID
+	if userID == uuid.Nil {
+		return false, nil
+	}
+
+	return h.repo.HasConfirmedBooking(userID, property.ID)
+}
+
 func (h *Handler) PostProperty(w http.ResponseWriter, r *http.Request) {
 	userVal := r.Context().Value("userID")
 	roleVal := r.Context().Value("role")
@@ -80,9 +189,34 @@ func (h *Handler) PostProperty(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := geo.Validate(property.Latitude, property.Longitude); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := normalizeAttributes(&property.PropertyAttributes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if property.Currency == "" {
+		property.Currency = money.DefaultCurrency
+	}
+	currency, err := money.NormalizeCurrency(property.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	property.Currency = currency
+
+	if err := normalizeJurisdiction(&property.Jurisdiction); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	property.UserID = uuid.MustParse(userID)
 
-	id, err := h.repo.PostProperty(property)
+	id, err := h.repo.PostProperty(property, actorFromRequest(r))
 	if err != nil {
 		h.cfg.Logger.Error("Unable to post a property", "Error", err)
 		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
@@ -91,6 +225,7 @@ func (h *Handler) PostProperty(w http.ResponseWriter, r *http.Request) {
 
 	message := map[string]any{
 		"id":      id,
+		"status":  models.ListingDraft,
 		"message": "Successfully created a property",
 	}
 
@@ -130,6 +265,22 @@ func (h *Handler) UpdateProperty(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	ifMatch, present, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !present {
+		http.Error(w, "If-Match header is required; fetch the property for its ETag", http.StatusPreconditionRequired)
+		return
+	}
+
 	var property models.Property
 
 	if err := json.NewDecoder(r.Body).Decode(&property); err != nil {
@@ -137,17 +288,42 @@ func (h *Handler) UpdateProperty(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
 		return
 	}
+	// the path names the property; a body id may only repeat it
+	if property.ID != uuid.Nil && property.ID != id {
+		http.Error(w, "body id does not match the property in the path", http.StatusBadRequest)
+		return
+	}
 
-	err := h.repo.UpdateProperty(property)
+	// PUT replaces every editable field, so missing required fields are
+	// rejected rather than stored as zero values.
+	version, err := h.repo.UpdateProperty(id, ifMatch, func(p *models.EditableProperty) error {
+		update := models.EditableProperty{
+			Title:              property.Title,
+			Location:           property.Location,
+			Description:        property.Description,
+			PricePerNight:      property.PricePerNight,
+			MaxGuests:          property.MaxGuests,
+			Latitude:           property.Latitude,
+			Longitude:          property.Longitude,
+			PropertyAttributes: property.PropertyAttributes,
+		}
+		if err := validateEditable(&update); err != nil {
+			return err
+		}
+		*p = update
+		return nil
+	}, actorFromRequest(r))
 	if err != nil {
-		h.cfg.Logger.Error("Unable to update a property", "Error", err)
-		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
+		h.updatePropertyError(w, err)
 		return
 	}
 
+	w.Header().Set("ETag", propertyETag(version))
+
 	message := map[string]any{
 		"message": "Updated successfully",
 		"userID":  userID,
+		"version": version,
 	}
 
 	if err := helper.WriteJSON(w, message, http.StatusCreated); err != nil {
@@ -157,6 +333,157 @@ func (h *Handler) UpdateProperty(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// PatchProperty applies a JSON Merge Patch (RFC 7396) to the property's
+// editable fields. If-Match is optional; when sent it must be current.
+func (h *Handler) PatchProperty(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ifMatch, _, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || !json.Valid(patch) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	version, err := h.repo.UpdateProperty(id, ifMatch, func(p *models.EditableProperty) error {
+		current, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+
+		merged, err := helper.MergePatch(current, patch)
+		if err != nil {
+			return invalidInput{err}
+		}
+
+		var update models.EditableProperty
+		dec := json.NewDecoder(bytes.NewReader(merged))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&update); err != nil {
+			return invalidInput{fmt.Errorf("invalid patch: %w", err)}
+		}
+
+		if err := validateEditable(&update); err != nil {
+			return err
+		}
+		*p = update
+		return nil
+	}, actorFromRequest(r))
+	if err != nil {
+		h.updatePropertyError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", propertyETag(version))
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Updated successfully", "version": version}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) updatePropertyError(w http.ResponseWriter, err error) {
+	var invalid invalidInput
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "property not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrVersionMismatch):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	default:
+		h.cfg.Logger.Error("Unable to update a property", "Error", err)
+		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
+	}
+}
+
+// invalidInput marks an error caused by the request content, reported as 400.
+type invalidInput struct{ error }
+
+// validateEditable checks a complete set of editable fields.
+func validateEditable(p *models.EditableProperty) error {
+	if strings.TrimSpace(p.Title) == "" || strings.TrimSpace(p.Location) == "" || strings.TrimSpace(p.Description) == "" {
+		return invalidInput{errors.New("title, location and description are required")}
+	}
+	if p.PricePerNight < 0 {
+		return invalidInput{errors.New("price_per_night cannot be negative")}
+	}
+	if p.Currency != "" {
+		currency, err := money.NormalizeCurrency(p.Currency)
+		if err != nil {
+			return invalidInput{err}
+		}
+		p.Currency = currency
+	}
+	if p.Jurisdiction != nil {
+		if err := normalizeJurisdiction(p.Jurisdiction); err != nil {
+			return invalidInput{err}
+		}
+	}
+	if p.MaxGuests < 1 {
+		return invalidInput{errors.New("max_guests must be at least 1")}
+	}
+	if err := geo.Validate(p.Latitude, p.Longitude); err != nil {
+		return invalidInput{err}
+	}
+	if err := normalizeAttributes(&p.PropertyAttributes); err != nil {
+		return invalidInput{err}
+	}
+	return nil
+}
+
+func propertyETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// propertyContentETag is the ETag of a property as read: its version and a
+// hash of the body, so that any change to what is returned changes it.
+func propertyContentETag(version int, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%d-%x"`, version, sum[:8])
+}
+
+// parseIfMatch reads an If-Match header holding one of our ETags. present
+// reports whether the header was sent; version is nil for "*". Only the
+// version is compared: updates replace the editable fields, which always
+// bump it.
+func parseIfMatch(r *http.Request) (version *int, present bool, err error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		return nil, false, nil
+	}
+	if value == "*" {
+		return nil, true, nil
+	}
+
+	versionPart, _, _ := strings.Cut(strings.Trim(value, `"`), "-")
+	n, err := strconv.Atoi(versionPart)
+	if err != nil || strings.HasPrefix(value, "W/") {
+		return nil, true, errors.New("If-Match must be an ETag returned by this API")
+	}
+
+	return &n, true, nil
+}
+
 func (h *Handler) DeleteProperty(w http.ResponseWriter, r *http.Request) {
 	roleVal := r.Context().Value("role")
 	id := uuid.MustParse(r.PathValue("id"))
@@ -174,8 +501,12 @@ func (h *Handler) DeleteProperty(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := h.repo.DeleteProperty(id)
+	_, err := h.repo.DeleteProperty(id, actorFromRequest(r))
 	if err != nil {
+		if errors.Is(err, repository.ErrActiveBookings) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		h.cfg.Logger.Error("Unable to delete a property", "Error", err)
 		http.Error(w, fmt.Sprintf("Error:%v", err), http.StatusConflict)
 		return
@@ -188,6 +519,33 @@ func (h *Handler) DeleteProperty(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *Handler) RestoreProperty(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.RestoreProperty(id, actorFromRequest(r)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "no deleted property with that id", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Unable to restore a property", "Error", err)
+		http.Error(w, "failed to restore property", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully restored"}, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
 func (h *Handler) PostImage(w http.ResponseWriter, r *http.Request) {
 	userVal := r.Context().Value("userID")
 	roleVal := r.Context().Value("role")
@@ -233,7 +591,11 @@ func (h *Handler) PostImage(w http.ResponseWriter, r *http.Request) {
 
 	req.PropertyID = propertyID.String()
 
-	if err := h.repo.PostPropertyImages(req); err != nil {
+	if _, err := h.repo.PostPropertyImages(req, actorFromRequest(r)); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			http.Error(w, "display_order already used by another image", http.StatusConflict)
+			return
+		}
 		h.cfg.Logger.Error("Failed to add property images", "error", err)
 		http.Error(w, "failed to add images", http.StatusInternalServerError)
 		return
@@ -269,7 +631,13 @@ func (h *Handler) DeletePropertyImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	imageIDParam := r.PathValue("id")
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	imageIDParam := r.PathValue("imageID")
 
 	imageID, err := uuid.Parse(imageIDParam)
 	if err != nil {
@@ -277,7 +645,7 @@ func (h *Handler) DeletePropertyImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.repo.DeletePropertyImage(imageID)
+	storageKeys, err := h.repo.DeletePropertyImage(propertyID, imageID, actorFromRequest(r))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "image not found", http.StatusNotFound)
@@ -289,6 +657,14 @@ func (h *Handler) DeletePropertyImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The row is gone at this point; a failed blob delete only leaves an
+	// orphaned file behind, so log it rather than failing the request.
+	for _, key := range storageKeys {
+		if err := h.store.Delete(r.Context(), key); err != nil {
+			h.cfg.Logger.Error("Failed to delete image blob", "key", key, "error", err)
+		}
+	}
+
 	if err := helper.WriteJSON(w, map[string]any{"message": "Successfully image deleted"}, http.StatusCreated); err != nil {
 		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
 		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
@@ -334,8 +710,12 @@ func (h *Handler) AddAmenity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newAmenity, err := h.repo.AddAmenity(req.Name)
+	newAmenity, err := h.repo.AddAmenity(req, actorFromRequest(r))
 	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			http.Error(w, "an amenity with this name already exists", http.StatusConflict)
+			return
+		}
 		h.cfg.Logger.Error("Failed to add amenity", "error", err)
 		http.Error(w, "failed to add amenity", http.StatusInternalServerError)
 		return
@@ -348,31 +728,41 @@ func (h *Handler) AddAmenity(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) SearchAvailability(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-
-	location := q.Get("location")
-	start_date := q.Get("startDate")
-	end_date := q.Get("endDate")
+	searchParams, err := parseSearchParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if start_date == "" || end_date == "" {
+	if searchParams.StartDate == "" || searchParams.EndDate == "" {
 		h.cfg.Logger.Error("start date and end date are required")
 		http.Error(w, "start date and end date are required", http.StatusBadRequest)
 		return
 	}
 
-	searchParams := models.SearchPropertyParams{
-		Location:  location,
-		StartDate: start_date,
-		EndDate:   end_date,
+	startDate, err1 := time.Parse("2006-01-02", searchParams.StartDate)
+	endDate, err2 := time.Parse("2006-01-02", searchParams.EndDate)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "Invalid date format, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if !endDate.After(startDate) {
+		http.Error(w, "End date must be after start date", http.StatusBadRequest)
+		return
 	}
 
-	data, err := h.repo.SearchAvailability(searchParams)
+	if searchParams.Currency != "" {
+		searchParams.PriceRates = h.priceRates(r.Context(), searchParams.Currency)
+	}
+
+	data, err := h.repo.SearchAvailability(searchParams, h.rates)
 
 	if err != nil {
 		h.cfg.Logger.Error("Failed to search properties", "error", err)
 		http.Error(w, "failed to search properties", http.StatusInternalServerError)
 		return
 	}
+	h.convertListings(r.Context(), data, searchParams.Currency)
 
 	if err := helper.WriteJSON(w, data, http.StatusCreated); err != nil {
 		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
@@ -433,7 +823,7 @@ func (h *Handler) PostPropertyAmenities(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if err := h.repo.PostPropertyAmenity(amenities); err != nil {
+	if err := h.repo.PostPropertyAmenity(amenities, actorFromRequest(r)); err != nil {
 		h.cfg.Logger.Error("Failed to add property amenities", "error", err)
 		http.Error(w, "failed to add amenities", http.StatusInternalServerError)
 		return
@@ -444,3 +834,163 @@ func (h *Handler) PostPropertyAmenities(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
 	}
 }
+
+// normalizeAttributes fills in defaults for omitted attributes and rejects
+// values the schema would refuse.
+func normalizeAttributes(a *models.PropertyAttributes) error {
+	if a.PropertyType == "" {
+		a.PropertyType = "other"
+	}
+	if !slices.Contains(models.PropertyTypes, a.PropertyType) {
+		return fmt.Errorf("property_type must be one of %s", strings.Join(models.PropertyTypes, ", "))
+	}
+
+	if a.Bedrooms < 0 || a.Beds < 0 || a.Bathrooms < 0 {
+		return errors.New("bedrooms, beds and bathrooms cannot be negative")
+	}
+
+	if a.CheckInTime == "" {
+		a.CheckInTime = "15:00"
+	}
+	if a.CheckOutTime == "" {
+		a.CheckOutTime = "11:00"
+	}
+	for name, value := range map[string]string{"check_in_time": a.CheckInTime, "check_out_time": a.CheckOutTime} {
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("%s must be HH:MM", name)
+		}
+	}
+
+	if a.CancellationPolicy == "" {
+		a.CancellationPolicy = models.PolicyModerate
+	}
+	if !slices.Contains(models.CancellationPolicies, a.CancellationPolicy) {
+		return fmt.Errorf("cancellation_policy must be one of %s", strings.Join(models.CancellationPolicies, ", "))
+	}
+
+	if a.BookingMode == "" {
+		a.BookingMode = models.BookInstantly
+	}
+	if !slices.Contains(models.BookingModes, a.BookingMode) {
+		return fmt.Errorf("booking_mode must be one of %s", strings.Join(models.BookingModes, ", "))
+	}
+
+	return nil
+}
+
+// parseSearchParams reads the listing search filters shared by
+// GET /properties and the availability search: q, location, lat/lng with
+// radius_km, bbox as "west,south,east,north", price, guest and room
+// minimums, comma-separated types and the pets/smoking/events house rules.
+func parseSearchParams(r *http.Request) (models.SearchPropertyParams, error) {
+	q := r.URL.Query()
+
+	params := models.SearchPropertyParams{
+		Query:     strings.TrimSpace(q.Get("q")),
+		Location:  strings.TrimSpace(q.Get("location")),
+		StartDate: q.Get("startDate"),
+		EndDate:   q.Get("endDate"),
+	}
+
+	// Price filters and results are in the display currency.
+	currency, err := displayCurrency(r)
+	if err != nil {
+		return params, err
+	}
+	params.Currency = currency
+
+	floats := map[string]**float64{
+		"lat":       &params.Latitude,
+		"lng":       &params.Longitude,
+		"radius_km": &params.RadiusKm,
+		"min_price": &params.MinPrice,
+		"max_price": &params.MaxPrice,
+		"bathrooms": &params.MinBathrooms,
+	}
+
+	for name, dst := range floats {
+		value := q.Get(name)
+		if value == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return params, fmt.Errorf("invalid %s", name)
+		}
+		*dst = &f
+	}
+
+	for name, dst := range map[string]**int{"guests": &params.Guests, "bedrooms": &params.MinBedrooms, "beds": &params.MinBeds} {
+		value := q.Get(name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return params, fmt.Errorf("invalid %s", name)
+		}
+		*dst = &n
+	}
+
+	for name, dst := range map[string]**bool{"pets": &params.PetsAllowed, "smoking": &params.SmokingAllowed, "events": &params.EventsAllowed} {
+		value := q.Get(name)
+		if value == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return params, fmt.Errorf("invalid %s", name)
+		}
+		*dst = &b
+	}
+
+	if types := q.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(models.PropertyTypes, t) {
+				return params, fmt.Errorf("unknown property type %q", t)
+			}
+			params.PropertyTypes = append(params.PropertyTypes, t)
+		}
+	}
+
+	if params.Latitude != nil || params.Longitude != nil || params.RadiusKm != nil {
+		if err := geo.Validate(params.Latitude, params.Longitude); err != nil {
+			return params, err
+		}
+		if params.RadiusKm == nil {
+			radius := defaultSearchRadiusKm
+			params.RadiusKm = &radius
+		}
+		if *params.RadiusKm <= 0 || *params.RadiusKm > maxSearchRadiusKm {
+			return params, fmt.Errorf("radius_km must be between 0 and %v", maxSearchRadiusKm)
+		}
+	}
+
+	if bbox := q.Get("bbox"); bbox != "" {
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return params, errors.New("bbox must be west,south,east,north")
+		}
+
+		var edges [4]float64
+		for i, part := range parts {
+			f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return params, errors.New("bbox must be west,south,east,north")
+			}
+			edges[i] = f
+		}
+
+		box := geo.BoundingBox{West: edges[0], South: edges[1], East: edges[2], North: edges[3]}
+		if err := box.Validate(); err != nil {
+			return params, err
+		}
+		params.BBox = &box
+	}
+
+	return params, nil
+}
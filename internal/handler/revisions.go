@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) GetPropertyRevisions(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.repo.GetPropertyRevisions(propertyID)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get property revisions", "error", err)
+		http.Error(w, "failed to fetch revisions", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, revisions, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// DiffPropertyRevisions compares the revisions given by the from and to
+// query parameters.
+func (h *Handler) DiffPropertyRevisions(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	from, err1 := strconv.Atoi(r.URL.Query().Get("from"))
+	to, err2 := strconv.Atoi(r.URL.Query().Get("to"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "from and to must be revision versions", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.repo.DiffPropertyRevisions(propertyID, from, to)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		h.cfg.Logger.Error("Failed to diff property revisions", "error", err)
+		http.Error(w, "failed to diff revisions", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{"from": from, "to": to, "diff": diff}
+	if err := helper.WriteJSON(w, data, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) RollbackProperty(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	propertyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid property id", http.StatusBadRequest)
+		return
+	}
+
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		http.Error(w, "invalid revision version", http.StatusBadRequest)
+		return
+	}
+
+	ifMatch, _, err := parseIfMatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.repo.RollbackProperty(propertyID, version, ifMatch, actorFromRequest(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "property or revision not found", http.StatusNotFound)
+		case errors.Is(err, repository.ErrVersionMismatch):
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		default:
+			h.cfg.Logger.Error("Failed to roll back property", "error", err)
+			http.Error(w, "failed to roll back property", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", propertyETag(result.Version))
+
+	if err := helper.WriteJSON(w, result, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/helper"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/google/uuid"
+)
+
+const maxJurisdictionNameLength = 100
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// normalizeJurisdiction upper-cases the country and trims the names. A
+// region or city needs a country.
+func normalizeJurisdiction(j *models.Jurisdiction) error {
+	j.Country = strings.ToUpper(strings.TrimSpace(j.Country))
+	j.Region = strings.TrimSpace(j.Region)
+	j.City = strings.TrimSpace(j.City)
+
+	if j.Country == "" {
+		if j.Region != "" || j.City != "" {
+			return errors.New("jurisdiction: a region or city needs a country")
+		}
+		return nil
+	}
+	if !countryCode.MatchString(j.Country) {
+		return errors.New("jurisdiction: country must be a two-letter ISO 3166-1 code")
+	}
+	if len([]rune(j.Region)) > maxJurisdictionNameLength || len([]rune(j.City)) > maxJurisdictionNameLength {
+		return errors.New("jurisdiction: region and city must be at most 100 characters")
+	}
+	return nil
+}
+
+func (h *Handler) GetTaxRules(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	country := strings.ToUpper(r.URL.Query().Get("country"))
+
+	rules, err := h.repo.GetTaxRules(country)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to get tax rules", "error", err)
+		http.Error(w, "failed to fetch tax rules", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, rules, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) GetTaxRule(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid tax rule id", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.repo.GetTaxRule(id)
+	if err != nil {
+		h.taxRuleError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, rule, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+// PostTaxRule creates a tax rule; PUT .../{id} replaces one.
+func (h *Handler) PostTaxRule(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	rule, ok := decodeTaxRule(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.repo.CreateTaxRule(rule, actorFromRequest(r))
+	if err != nil {
+		h.taxRuleError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, created, http.StatusCreated); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) PutTaxRule(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid tax rule id", http.StatusBadRequest)
+		return
+	}
+
+	rule, ok := decodeTaxRule(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.UpdateTaxRule(id, rule, actorFromRequest(r))
+	if err != nil {
+		h.taxRuleError(w, err)
+		return
+	}
+
+	if err := helper.WriteJSON(w, updated, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) DeleteTaxRule(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid tax rule id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteTaxRule(id, actorFromRequest(r)); err != nil {
+		h.taxRuleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) taxRuleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "tax rule not found", http.StatusNotFound)
+		return
+	}
+	h.cfg.Logger.Error("Failed to save tax rule", "error", err)
+	http.Error(w, "failed to save tax rule", http.StatusInternalServerError)
+}
+
+func decodeTaxRule(w http.ResponseWriter, r *http.Request) (models.TaxRule, bool) {
+	var rule models.TaxRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return rule, false
+	}
+
+	if err := normalizeTaxRule(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return rule, false
+	}
+	return rule, true
+}
+
+func normalizeTaxRule(rule *models.TaxRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" || len([]rune(rule.Name)) > maxJurisdictionNameLength {
+		return errors.New("name is required and must be at most 100 characters")
+	}
+
+	if err := normalizeJurisdiction(&rule.Jurisdiction); err != nil {
+		return err
+	}
+	if rule.Country == "" {
+		return errors.New("country is required")
+	}
+
+	switch rule.Kind {
+	case models.TaxPercentage:
+		if rule.Percent == nil || *rule.Percent <= 0 || *rule.Percent > 100 {
+			return errors.New("percentage rules need a percent above 0 and at most 100")
+		}
+		rule.Amount = nil
+	case models.TaxPerNight:
+		if rule.Amount == nil || rule.Amount.Amount <= 0 {
+			return errors.New("per_night rules need a positive amount")
+		}
+		currency, err := money.NormalizeCurrency(rule.Amount.Currency)
+		if err != nil {
+			return err
+		}
+		rule.Amount.Currency = currency
+		rule.Percent = nil
+	default:
+		return fmt.Errorf("kind must be %q or %q", models.TaxPercentage, models.TaxPerNight)
+	}
+
+	from, err := time.Parse("2006-01-02", rule.EffectiveFrom)
+	if err != nil {
+		return errors.New("effective_from is required, use YYYY-MM-DD")
+	}
+	if rule.EffectiveTo != "" {
+		to, err := time.Parse("2006-01-02", rule.EffectiveTo)
+		if err != nil || !to.After(from) {
+			return errors.New("effective_to must be a date (YYYY-MM-DD) after effective_from")
+		}
+	}
+
+	return nil
+}
+
+// GetTaxReport splits the taxes collected from booking revenue, per rule
+// and currency, for bookings checking in from ?from up to ?to (YYYY-MM-DD,
+// default the current month).
+func (h *Handler) GetTaxReport(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	q := r.URL.Query()
+	for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := q.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "invalid "+param+", use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		*dst = t
+	}
+	if !to.After(from) {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.repo.TaxReport(from, to)
+	if err != nil {
+		h.cfg.Logger.Error("Failed to build tax report", "error", err)
+		http.Error(w, "failed to build tax report", http.StatusInternalServerError)
+		return
+	}
+
+	if err := helper.WriteJSON(w, report, http.StatusOK); err != nil {
+		h.cfg.Logger.Error("Failed to generate a response", "Error", err)
+		http.Error(w, "Failed to generate a response", http.StatusInternalServerError)
+	}
+}
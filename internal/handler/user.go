@@ -38,7 +38,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		PasswordHash: pwHash,
 	}
 
-	id, err := h.repo.RegisterUser(&user)
+	id, err := h.repo.RegisterUser(&user, actorFromRequest(r))
 	if err != nil {
 		h.cfg.Logger.Error("User registration failed", "Error", err)
 		http.Error(w, "User registration failed", http.StatusConflict)
@@ -0,0 +1,66 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/safehttp"
+)
+
+var ErrPrivateAddress = safehttp.ErrPrivateAddress
+
+// NewClient returns an HTTP client for fetching host-supplied calendar URLs.
+// Unless allowPrivate is set, connections to loopback, private and
+// link-local addresses are refused so imports cannot reach internal
+// services.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	return safehttp.NewClient(timeout, allowPrivate)
+}
+
+// NormalizeURL checks a feed URL and rewrites webcal:// to https://.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid calendar url: %w", err)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", errors.New("calendar url must use http, https or webcal")
+	}
+	if u.Host == "" {
+		return "", errors.New("calendar url has no host")
+	}
+	u.User = nil
+
+	return u.String(), nil
+}
+
+// Fetch downloads and parses the calendar at feedURL.
+func Fetch(ctx context.Context, client *http.Client, feedURL string) ([]Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar url returned %s", resp.Status)
+	}
+
+	return Parse(resp.Body)
+}
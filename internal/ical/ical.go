@@ -0,0 +1,270 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) used to
+// sync availability with other listing platforms: all-day VEVENTs marking
+// nights that are taken.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "20060102"
+
+// MaxFeedSize caps how much of a calendar is read.
+const MaxFeedSize = 2 << 20
+
+var ErrNotCalendar = errors.New("not an iCalendar document")
+
+// Event is a range of taken nights. End is exclusive, as in DTEND.
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Write encodes events as a VCALENDAR of all-day events.
+func Write(w io.Writer, name string, events []Event, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	line := func(s string) {
+		writeFolded(bw, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//BookBnb//Availability//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeText(name))
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + escapeText(e.UID))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + e.Start.Format(dateLayout))
+		line("DTEND;VALUE=DATE:" + e.End.Format(dateLayout))
+		line("SUMMARY:" + escapeText(e.Summary))
+		line("TRANSP:OPAQUE")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// writeFolded writes a content line, folding it at 75 octets without
+// splitting a UTF-8 sequence.
+func writeFolded(w *bufio.Writer, s string) {
+	const limit = 75
+
+	first := true
+	for len(s) > 0 {
+		max := limit
+		if !first {
+			max = limit - 1 // room for the leading space
+		}
+		if len(s) <= max {
+			max = len(s)
+		} else {
+			for max > 0 && s[max]&0xC0 == 0x80 {
+				max--
+			}
+		}
+
+		if !first {
+			w.WriteByte(' ')
+		}
+		w.WriteString(s[:max])
+		w.WriteString("\r\n")
+
+		s = s[max:]
+		first = false
+	}
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// Parse reads the VEVENTs of a calendar. Timed events take the nights from
+// their start date up to their end date; events shorter than a night take
+// one. Cancelled events are skipped.
+func Parse(r io.Reader) ([]Event, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFeedSize {
+		return nil, fmt.Errorf("calendar is larger than %d bytes", MaxFeedSize)
+	}
+
+	var (
+		events     []Event
+		inCalendar bool
+		event      map[string]contentLine
+	)
+
+	for _, l := range unfold(string(data)) {
+		cl, ok := parseLine(l)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case cl.name == "BEGIN" && strings.EqualFold(cl.value, "VCALENDAR"):
+			inCalendar = true
+		case cl.name == "BEGIN" && strings.EqualFold(cl.value, "VEVENT"):
+			event = map[string]contentLine{}
+		case cl.name == "END" && strings.EqualFold(cl.value, "VEVENT"):
+			if event == nil {
+				continue
+			}
+			e, ok, err := toEvent(event)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				events = append(events, e)
+			}
+			event = nil
+		case event != nil:
+			if _, seen := event[cl.name]; !seen {
+				event[cl.name] = cl
+			}
+		}
+	}
+
+	if !inCalendar {
+		return nil, ErrNotCalendar
+	}
+
+	return events, nil
+}
+
+type contentLine struct {
+	name  string
+	value string
+}
+
+// unfold joins folded continuation lines and splits on CRLF or LF.
+func unfold(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// parseLine splits "NAME;PARAM=x:value", ignoring colons in quoted params.
+// Parameters are not needed for the properties read here and are dropped.
+func parseLine(l string) (contentLine, bool) {
+	quoted := false
+	for i, c := range l {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ':' && !quoted:
+			name, _, _ := strings.Cut(l[:i], ";")
+			return contentLine{name: strings.ToUpper(name), value: l[i+1:]}, name != ""
+		}
+	}
+	return contentLine{}, false
+}
+
+func toEvent(props map[string]contentLine) (Event, bool, error) {
+	if status, ok := props["STATUS"]; ok && strings.EqualFold(status.value, "CANCELLED") {
+		return Event{}, false, nil
+	}
+
+	dtstart, ok := props["DTSTART"]
+	if !ok {
+		return Event{}, false, nil
+	}
+	start, err := parseDate(dtstart.value)
+	if err != nil {
+		return Event{}, false, fmt.Errorf("invalid DTSTART %q: %w", dtstart.value, err)
+	}
+
+	end := start.AddDate(0, 0, 1)
+	if dtend, ok := props["DTEND"]; ok {
+		// A timed end is a check-out: that day's night stays free.
+		end, err = parseDate(dtend.value)
+		if err != nil {
+			return Event{}, false, fmt.Errorf("invalid DTEND %q: %w", dtend.value, err)
+		}
+	} else if duration, ok := props["DURATION"]; ok {
+		days, err := parseDays(duration.value)
+		if err != nil {
+			return Event{}, false, fmt.Errorf("invalid DURATION %q: %w", duration.value, err)
+		}
+		end = start.AddDate(0, 0, days)
+	}
+
+	if !end.After(start) {
+		end = start.AddDate(0, 0, 1)
+	}
+
+	e := Event{Start: start, End: end}
+	if uid, ok := props["UID"]; ok {
+		e.UID = unescapeText(uid.value)
+	}
+	if summary, ok := props["SUMMARY"]; ok {
+		e.Summary = unescapeText(summary.value)
+	}
+	return e, true, nil
+}
+
+// parseDate reads the date part of a DATE or DATE-TIME value as written.
+func parseDate(v string) (time.Time, error) {
+	if len(v) < 8 {
+		return time.Time{}, errors.New("too short")
+	}
+	return time.Parse(dateLayout, v[:8])
+}
+
+// parseDays reads whole days from a duration such as P3D, P1W or P2DT12H;
+// hours round up to a day.
+func parseDays(v string) (int, error) {
+	v = strings.TrimPrefix(strings.ToUpper(v), "+")
+	if !strings.HasPrefix(v, "P") {
+		return 0, errors.New("missing P")
+	}
+	v = v[1:]
+
+	days := 0
+	datePart, timePart, hasTime := strings.Cut(v, "T")
+	for datePart != "" {
+		i := strings.IndexAny(datePart, "DW")
+		if i <= 0 {
+			return 0, errors.New("unsupported unit")
+		}
+		n, err := strconv.Atoi(datePart[:i])
+		if err != nil {
+			return 0, err
+		}
+		if datePart[i] == 'W' {
+			n *= 7
+		}
+		days += n
+		datePart = datePart[i+1:]
+	}
+	if hasTime && strings.Trim(timePart, "0HMS") != "" {
+		days++
+	}
+
+	return days, nil
+}
@@ -0,0 +1,173 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	calendar := func(lines ...string) string {
+		return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...), "END:VCALENDAR"), "\r\n")
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want []Event
+		err  bool
+	}{
+		{
+			name: "all-day event",
+			in: calendar("BEGIN:VEVENT", "UID:a@example.com", "SUMMARY:Reserved",
+				"DTSTART;VALUE=DATE:20260601", "DTEND;VALUE=DATE:20260604", "END:VEVENT"),
+			want: []Event{{UID: "a@example.com", Summary: "Reserved", Start: date(2026, 6, 1), End: date(2026, 6, 4)}},
+		},
+		{
+			name: "timed event keeps the check-out night free",
+			in:   calendar("BEGIN:VEVENT", "DTSTART:20260601T150000Z", "DTEND:20260603T110000Z", "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 1), End: date(2026, 6, 3)}},
+		},
+		{
+			name: "no end takes one night",
+			in:   calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260601", "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 1), End: date(2026, 6, 2)}},
+		},
+		{
+			name: "same-day event takes one night",
+			in:   calendar("BEGIN:VEVENT", "DTSTART:20260601T100000Z", "DTEND:20260601T120000Z", "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 1), End: date(2026, 6, 2)}},
+		},
+		{
+			name: "duration in weeks and days",
+			in:   calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260601", "DURATION:P1W2D", "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 1), End: date(2026, 6, 10)}},
+		},
+		{
+			name: "duration hours round up to a day",
+			in:   calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260601", "DURATION:P2DT12H", "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 1), End: date(2026, 6, 4)}},
+		},
+		{
+			name: "cancelled event skipped",
+			in: calendar("BEGIN:VEVENT", "STATUS:CANCELLED", "DTSTART;VALUE=DATE:20260601", "END:VEVENT",
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20260610", "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 10), End: date(2026, 6, 11)}},
+		},
+		{
+			name: "escaped and folded text",
+			in: calendar("BEGIN:VEVENT", `SUMMARY:Smith\, J.\; two`, "  guests", "DTSTART;VALUE=DATE:20260601",
+				"END:VEVENT"),
+			want: []Event{{Summary: "Smith, J.; two guests", Start: date(2026, 6, 1), End: date(2026, 6, 2)}},
+		},
+		{
+			name: "quoted parameter with a colon",
+			in:   calendar("BEGIN:VEVENT", `DTSTART;TZID="America/New_York:x":20260601T150000`, "END:VEVENT"),
+			want: []Event{{Start: date(2026, 6, 1), End: date(2026, 6, 2)}},
+		},
+		{
+			name: "event without a start skipped",
+			in:   calendar("BEGIN:VEVENT", "SUMMARY:No dates", "END:VEVENT"),
+		},
+		{
+			name: "invalid start",
+			in:   calendar("BEGIN:VEVENT", "DTSTART:2026-06-01", "END:VEVENT"),
+			err:  true,
+		},
+		{
+			name: "not a calendar",
+			in:   "<html></html>",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.in))
+			if (err != nil) != tt.err {
+				t.Fatalf("Parse() error = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNotCalendar(t *testing.T) {
+	if _, err := Parse(strings.NewReader("")); !errors.Is(err, ErrNotCalendar) {
+		t.Errorf("Parse(\"\") error = %v, want %v", err, ErrNotCalendar)
+	}
+}
+
+func TestParseTooLarge(t *testing.T) {
+	if _, err := Parse(strings.NewReader(strings.Repeat("x", MaxFeedSize+1))); err == nil {
+		t.Error("Parse() accepted a calendar over MaxFeedSize")
+	}
+}
+
+func TestUnfold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"A:1\r\nB:2", []string{"A:1", "B:2"}},
+		{"A:1\nB:2", []string{"A:1", "B:2"}},
+		{"A:fo\r\n ld\r\n\ted", []string{"A:folded"}},
+		{" A:1", []string{" A:1"}},
+	}
+
+	for _, tt := range tests {
+		if got := unfold(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unfold(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	events := []Event{
+		{UID: "1@bookbnb", Summary: "Booked", Start: date(2026, 6, 1), End: date(2026, 6, 4)},
+		{UID: "2@bookbnb", Summary: "Blocked; " + strings.Repeat("très long, ", 12), Start: date(2026, 7, 1), End: date(2026, 7, 2)},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Cabin, lake", events, time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"X-WR-CALNAME:Cabin\\, lake\r\n",
+		"DTSTAMP:20260501T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20260601\r\n",
+		"DTEND;VALUE=DATE:20260604\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Write() output is missing %q", want)
+		}
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line of %d octets is not folded: %q", len(l), l)
+		}
+		if !utf8.ValidString(l) {
+			t.Errorf("folding split a UTF-8 sequence: %q", l)
+		}
+	}
+
+	got, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("Parse(Write()) = %+v, want %+v", got, events)
+	}
+}
@@ -0,0 +1,52 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func solid(w, h int, r, g, b uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = r, g, b, 0xFF
+	}
+	return img
+}
+
+func TestBlurhash(t *testing.T) {
+	// Black on the left, white on the right.
+	split := solid(8, 4, 0xFF, 0xFF, 0xFF)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			copy(split.Pix[y*split.Stride+x*4:], []byte{0, 0, 0})
+		}
+	}
+
+	// Expected hashes come from a port of the reference encoder at
+	// https://github.com/woltapp/blurhash. Its basis has no half-pixel
+	// offset, so even flat images carry some AC.
+	tests := []struct {
+		name string
+		img  *image.RGBA
+		x, y int
+		want string
+	}{
+		{"white", solid(8, 6, 0xFF, 0xFF, 0xFF), 4, 3, "LsTSUA_3fQ_3~qt7fQt7fQfQfQfQ"},
+		{"black", solid(8, 6, 0, 0, 0), 4, 3, "L00000fQfQfQfQfQfQfQfQfQfQfQ"},
+		{"red", solid(8, 6, 0xFF, 0, 0), 4, 3, "LsTI:j]9fQ]9|csUfQsUfQfQfQfQ"},
+		{"DC only", solid(3, 3, 0xFF, 0xFF, 0xFF), 1, 1, "00TSUA"},
+		{"black and white halves", split, 4, 3, "L~Lqe900D%?b-;IURjxufQfQfQfQ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Blurhash(tt.img, tt.x, tt.y)
+			if got != tt.want {
+				t.Errorf("Blurhash() = %q, want %q", got, tt.want)
+			}
+			if want := 4 + 2*tt.x*tt.y; len(got) != want {
+				t.Errorf("len(Blurhash()) = %d, want %d", len(got), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package imaging
+
+import (
+	"encoding/binary"
+)
+
+const (
+	tagOrientation = 0x0112
+	tagGPSIFD      = 0x8825
+)
+
+// exifTIFF returns the TIFF block of a JPEG's EXIF APP1 segment, or nil if
+// the data is not a JPEG or carries no EXIF. The returned slice aliases data.
+func exifTIFF(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return segment[6:]
+		}
+
+		pos = end
+	}
+
+	return nil
+}
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func parseTIFF(data []byte) (*tiff, uint32, bool) {
+	if len(data) < 8 {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+
+	if order.Uint16(data[2:]) != 42 {
+		return nil, 0, false
+	}
+
+	return &tiff{data: data, order: order}, order.Uint32(data[4:]), true
+}
+
+// entries calls fn with the byte offset of each 12-byte entry in the IFD at
+// offset, stopping early if fn returns false.
+func (t *tiff) entries(offset uint32, fn func(entry int) bool) {
+	if int(offset)+2 > len(t.data) {
+		return
+	}
+
+	count := int(t.order.Uint16(t.data[offset:]))
+	for i := 0; i < count; i++ {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(t.data) {
+			return
+		}
+		if !fn(entry) {
+			return
+		}
+	}
+}
+
+// Orientation returns the EXIF orientation (1-8) of a JPEG, defaulting to 1.
+func Orientation(data []byte) int {
+	t, ifd0, ok := parseTIFF(exifTIFF(data))
+	if !ok {
+		return 1
+	}
+
+	orientation := 1
+	t.entries(ifd0, func(entry int) bool {
+		if t.order.Uint16(t.data[entry:]) == tagOrientation {
+			if v := int(t.order.Uint16(t.data[entry+8:])); v >= 1 && v <= 8 {
+				orientation = v
+			}
+			return false
+		}
+		return true
+	})
+
+	return orientation
+}
+
+// typeSizes is the byte size of each TIFF field type.
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// StripGPS blanks the EXIF GPS block of a JPEG in place, keeping every
+// other tag (orientation in particular) intact. Data without EXIF GPS is
+// returned unchanged.
+func StripGPS(data []byte) []byte {
+	t, ifd0, ok := parseTIFF(exifTIFF(data))
+	if !ok {
+		return data
+	}
+
+	var gpsIFD uint32
+	t.entries(ifd0, func(entry int) bool {
+		if t.order.Uint16(t.data[entry:]) == tagGPSIFD {
+			gpsIFD = t.order.Uint32(t.data[entry+8:])
+			return false
+		}
+		return true
+	})
+
+	if gpsIFD == 0 || int(gpsIFD)+2 > len(t.data) {
+		return data
+	}
+
+	t.entries(gpsIFD, func(entry int) bool {
+		typ := t.order.Uint16(t.data[entry+2:])
+		count := int(t.order.Uint32(t.data[entry+4:]))
+		size := typeSizes[typ] * count
+
+		// Values larger than four bytes live elsewhere in the block.
+		if size > 4 {
+			start := int(t.order.Uint32(t.data[entry+8:]))
+			if start >= 0 && size > 0 && start+size <= len(t.data) {
+				clear(t.data[start : start+size])
+			}
+		}
+
+		clear(t.data[entry : entry+12])
+		return true
+	})
+
+	// An empty IFD: zero entries, and the zeroed bytes that follow read as
+	// "no next IFD".
+	t.order.PutUint16(t.data[gpsIFD:], 0)
+
+	return data
+}
@@ -0,0 +1,231 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// latitude is the GPSLatitude value written by exifJPEG, distinctive enough
+// to search the output for.
+var latitude = []uint32{51, 1, 30, 1, 2668, 100}
+
+// tiffBlock builds a little-endian TIFF block whose IFD0 holds an
+// orientation tag and, if gps is set, a GPS IFD with a latitude reference
+// stored inline and a latitude stored out of line.
+func tiffBlock(orientation int, gps bool) []byte {
+	le := binary.LittleEndian
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, count)
+		return le.AppendUint32(b, value)
+	}
+
+	const ifd0 = 8
+	entries := 1
+	if gps {
+		entries = 2
+	}
+	gpsIFD := ifd0 + 2 + entries*12 + 4
+	latOffset := gpsIFD + 2 + 2*12 + 4
+
+	b := []byte("II*\x00")
+	b = le.AppendUint32(b, ifd0)
+	b = le.AppendUint16(b, uint16(entries))
+	b = entry(b, tagOrientation, 3, 1, uint32(orientation))
+	if gps {
+		b = entry(b, tagGPSIFD, 4, 1, uint32(gpsIFD))
+	}
+	b = le.AppendUint32(b, 0)
+
+	if gps {
+		b = le.AppendUint16(b, 2)
+		b = entry(b, 0x0001, 2, 2, uint32('N'))
+		b = entry(b, 0x0002, 5, 3, uint32(latOffset))
+		b = le.AppendUint32(b, 0)
+		for _, v := range latitude {
+			b = le.AppendUint32(b, v)
+		}
+	}
+
+	return b
+}
+
+// withSegment inserts an APP1 segment holding payload right after the SOI
+// marker of jpg.
+func withSegment(jpg, payload []byte) []byte {
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(payload)+2))
+	out = append(out, payload...)
+	return append(out, jpg[2:]...)
+}
+
+// exifJPEG encodes a w by h JPEG carrying tiff as its EXIF block.
+func exifJPEG(t *testing.T, w, h int, tiff []byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tiff == nil {
+		return buf.Bytes()
+	}
+	return withSegment(buf.Bytes(), append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func latitudeBytes() []byte {
+	var b []byte
+	for _, v := range latitude {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func TestStripGPS(t *testing.T) {
+	data := exifJPEG(t, 8, 8, tiffBlock(6, true))
+	if !bytes.Contains(data, latitudeBytes()) {
+		t.Fatal("test image is missing its GPS latitude")
+	}
+
+	stripped := StripGPS(bytes.Clone(data))
+
+	if len(stripped) != len(data) {
+		t.Fatalf("StripGPS changed the length from %d to %d", len(data), len(stripped))
+	}
+	if bytes.Contains(stripped, latitudeBytes()) {
+		t.Error("GPS latitude survived StripGPS")
+	}
+
+	tf, ifd0, ok := parseTIFF(exifTIFF(stripped))
+	if !ok {
+		t.Fatal("EXIF block no longer parses")
+	}
+	var gpsIFD uint32
+	tf.entries(ifd0, func(entry int) bool {
+		if tf.order.Uint16(tf.data[entry:]) == tagGPSIFD {
+			gpsIFD = tf.order.Uint32(tf.data[entry+8:])
+		}
+		return true
+	})
+	if gpsIFD == 0 {
+		t.Fatal("GPS IFD pointer is gone; only its contents should be")
+	}
+	if n := tf.order.Uint16(tf.data[gpsIFD:]); n != 0 {
+		t.Errorf("GPS IFD has %d entries, want 0", n)
+	}
+	tf.entries(gpsIFD, func(int) bool {
+		t.Error("GPS IFD still lists an entry")
+		return false
+	})
+
+	if got := Orientation(stripped); got != 6 {
+		t.Errorf("Orientation after StripGPS = %d, want 6", got)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("stripped image no longer decodes: %v", err)
+	}
+}
+
+func TestStripGPSLeavesOtherDataUnchanged(t *testing.T) {
+	jpg := exifJPEG(t, 8, 8, nil)
+	withGPS := exifJPEG(t, 8, 8, tiffBlock(1, true))
+	exif := func(tiff []byte) []byte {
+		return withSegment(jpg, append([]byte("Exif\x00\x00"), tiff...))
+	}
+
+	badSegmentLength := bytes.Clone(withGPS)
+	binary.BigEndian.PutUint16(badSegmentLength[4:], 0xFFFF)
+
+	badByteOrder := tiffBlock(1, true)
+	copy(badByteOrder, "XX")
+	badMagic := tiffBlock(1, true)
+	badMagic[2] = 43
+
+	gpsOutOfRange := tiffBlock(1, true)
+	binary.LittleEndian.PutUint32(gpsOutOfRange[8+2+12+8:], 0xFFFFFFF0)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n")},
+		{"JPEG without EXIF", jpg},
+		{"EXIF without GPS", exifJPEG(t, 8, 8, tiffBlock(3, false))},
+		{"segment longer than the file", badSegmentLength},
+		{"file cut inside the segment", withGPS[:10]},
+		{"unknown byte order", exif(badByteOrder)},
+		{"bad TIFF magic", exif(badMagic)},
+		{"GPS IFD past the end", exif(gpsOutOfRange)},
+		{"non-EXIF APP1", withSegment(jpg, []byte("http://ns.adobe.com/xap/1.0/\x00<x/>"))},
+	}
+
+	// Every cut of the TIFF block short of the GPS IFD's entry count, so
+	// each offset and count in turn points past the end of the data.
+	tiff := tiffBlock(1, true)
+	gpsIFD := 8 + 2 + 2*12 + 4
+	for n := 0; n < gpsIFD+2; n++ {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("TIFF cut at %d bytes", n), exif(tiff[:n])})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripGPS(bytes.Clone(tt.data))
+			if !bytes.Equal(got, tt.data) {
+				t.Error("StripGPS changed data it should have left alone")
+			}
+			if o := Orientation(tt.data); o < 1 || o > 8 {
+				t.Errorf("Orientation = %d, want 1-8", o)
+			}
+		})
+	}
+}
+
+func TestStripGPSTruncatedGPSIFD(t *testing.T) {
+	tiff := tiffBlock(1, true)
+	gpsIFD := 8 + 2 + 2*12 + 4
+
+	// GPSLatitudeRef's entry: tag 1, ASCII, 2 bytes, "N".
+	latitudeRef := []byte{1, 0, 2, 0, 2, 0, 0, 0, 'N', 0, 0, 0}
+
+	// Cuts inside the GPS IFD: whatever GPS data is left goes, without
+	// reading or writing past the end.
+	for n := gpsIFD + 2; n < len(tiff); n++ {
+		data := withSegment(exifJPEG(t, 8, 8, nil), append([]byte("Exif\x00\x00"), tiff[:n]...))
+		got := StripGPS(bytes.Clone(data))
+		if len(got) != len(data) {
+			t.Fatalf("cut at %d: StripGPS changed the length from %d to %d", n, len(data), len(got))
+		}
+		if bytes.Contains(got, latitudeRef) {
+			t.Errorf("cut at %d: GPS latitude reference survived", n)
+		}
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	for want := 1; want <= 8; want++ {
+		if got := Orientation(exifJPEG(t, 4, 4, tiffBlock(want, false))); got != want {
+			t.Errorf("Orientation = %d, want %d", got, want)
+		}
+	}
+
+	if got := Orientation(exifJPEG(t, 4, 4, tiffBlock(9, false))); got != 1 {
+		t.Errorf("Orientation of an invalid value = %d, want 1", got)
+	}
+	if got := Orientation(exifJPEG(t, 4, 4, nil)); got != 1 {
+		t.Errorf("Orientation without EXIF = %d, want 1", got)
+	}
+}
@@ -0,0 +1,203 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Variant is a named derivative size; images are scaled to fit Width and
+// are never upscaled.
+type Variant struct {
+	Name  string
+	Width int
+}
+
+// Variants are generated for every property image, smallest first.
+var Variants = []Variant{
+	{Name: "thumb", Width: 320},
+	{Name: "medium", Width: 768},
+	{Name: "large", Width: 1600},
+}
+
+const jpegQuality = 82
+
+// MaxPixels bounds the canvas an image may declare. Decoding allocates the
+// whole canvas, and small files can declare huge ones.
+const MaxPixels = 50_000_000
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+var ErrTooLarge = fmt.Errorf("image is larger than %d pixels", MaxPixels)
+
+type Derivative struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte // JPEG, without any EXIF metadata
+}
+
+type Result struct {
+	Width       int
+	Height      int
+	Blurhash    string
+	Derivatives []Derivative
+}
+
+// Process decodes an original image of at most MaxPixels, applies its EXIF orientation and
+// produces the blurhash and JPEG derivatives. Derivatives are re-encoded
+// from pixels, so they carry no EXIF (and no GPS) data.
+func Process(data []byte) (*Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	img := orient(flatten(src), Orientation(data))
+	bounds := img.Bounds()
+
+	result := &Result{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+
+	result.Blurhash = Blurhash(Resize(img, 64), 4, 3)
+
+	for _, v := range Variants {
+		scaled := Resize(img, v.Width)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", v.Name, err)
+		}
+
+		result.Derivatives = append(result.Derivatives, Derivative{
+			Name:   v.Name,
+			Width:  scaled.Bounds().Dx(),
+			Height: scaled.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+
+	return result, nil
+}
+
+// flatten draws src onto an opaque white canvas, since JPEG has no alpha.
+func flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// orient rotates/flips img so it displays upright for the given EXIF
+// orientation value.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+
+			si := y*img.Stride + x*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
+// Resize scales img down to maxWidth using area averaging, preserving the
+// aspect ratio. Images already narrower than maxWidth are returned as is.
+func Resize(img *image.RGBA, maxWidth int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	if sw <= maxWidth || sw == 0 {
+		return img
+	}
+
+	dw := maxWidth
+	dh := max(1, int(float64(sh)*float64(dw)/float64(sw)+0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	xScale := float64(sw) / float64(dw)
+	yScale := float64(sh) / float64(dh)
+
+	for dy := 0; dy < dh; dy++ {
+		y0 := int(float64(dy) * yScale)
+		y1 := max(y0+1, min(sh, int(float64(dy+1)*yScale)))
+
+		for dx := 0; dx < dw; dx++ {
+			x0 := int(float64(dx) * xScale)
+			x1 := max(x0+1, min(sw, int(float64(dx+1)*xScale)))
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				row := y * img.Stride
+				for x := x0; x < x1; x++ {
+					i := row + x*4
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					b += int(img.Pix[i+2])
+					a += int(img.Pix[i+3])
+					n++
+				}
+			}
+
+			i := dy*dst.Stride + dx*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
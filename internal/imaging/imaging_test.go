@@ -0,0 +1,168 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// grid builds an image from rows of letters, one pixel per letter, storing
+// the letter in the red channel.
+func grid(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := range row {
+			i := y*img.Stride + x*4
+			img.Pix[i], img.Pix[i+3] = row[x], 0xFF
+		}
+	}
+	return img
+}
+
+// letters reads back an image built by grid.
+func letters(img *image.RGBA) []string {
+	var rows []string
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		var row strings.Builder
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			row.WriteByte(img.Pix[y*img.Stride+x*4])
+		}
+		rows = append(rows, row.String())
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	// How a camera stores "abc over def" for each EXIF orientation, and
+	// what orient must turn it back into.
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{1, []string{"abc", "def"}},
+		{2, []string{"cba", "fed"}}, // mirrored
+		{3, []string{"fed", "cba"}}, // rotated 180°
+		{4, []string{"def", "abc"}}, // flipped
+		{5, []string{"ad", "be", "cf"}},
+		{6, []string{"da", "eb", "fc"}}, // rotated 90° clockwise
+		{7, []string{"fc", "eb", "da"}},
+		{8, []string{"cf", "be", "ad"}}, // rotated 90° counter-clockwise
+		{0, []string{"abc", "def"}},
+		{9, []string{"abc", "def"}},
+	}
+
+	for _, tt := range tests {
+		got := letters(orient(grid("abc", "def"), tt.orientation))
+		if strings.Join(got, "/") != strings.Join(tt.want, "/") {
+			t.Errorf("orient(%d) = %v, want %v", tt.orientation, got, tt.want)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		maxWidth      int
+		wantW, wantH  int
+	}{
+		{"scales down keeping the ratio", 400, 300, 320, 320, 240},
+		{"rounds the height", 1000, 333, 320, 320, 107},
+		{"keeps at least one row", 1000, 1, 320, 320, 1},
+		{"never upscales", 100, 50, 320, 100, 50},
+		{"leaves an exact fit alone", 320, 100, 320, 320, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+			got := Resize(img, tt.maxWidth)
+			if got.Bounds().Dx() != tt.wantW || got.Bounds().Dy() != tt.wantH {
+				t.Errorf("Resize(%dx%d, %d) = %dx%d, want %dx%d", tt.width, tt.height, tt.maxWidth,
+					got.Bounds().Dx(), got.Bounds().Dy(), tt.wantW, tt.wantH)
+			}
+			if tt.width <= tt.maxWidth && got != img {
+				t.Error("Resize copied an image it did not need to scale")
+			}
+		})
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	img := grid("\x00\xFE", "\x00\xFE")
+	got := Resize(img, 1)
+	if r := got.Pix[0]; r != 0x7F {
+		t.Errorf("Resize averaged red to %#x, want 0x7f", r)
+	}
+}
+
+// gifHeader is the start of a GIF declaring a w by h canvas; DecodeConfig
+// needs no more.
+func gifHeader(w, h uint16) []byte {
+	b := []byte("GIF89a")
+	b = binary.LittleEndian.AppendUint16(b, w)
+	b = binary.LittleEndian.AppendUint16(b, h)
+	return append(b, 0, 0, 0)
+}
+
+func TestProcessRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"canvas over MaxPixels", gifHeader(10000, 10000), ErrTooLarge},
+		{"canvas at the largest GIF size", gifHeader(0xFFFF, 0xFFFF), ErrTooLarge},
+		{"unknown format", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), ErrUnsupportedFormat},
+		{"empty", nil, ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("Process() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	// Stored landscape, shown portrait once rotated.
+	data := exifJPEG(t, 2000, 1000, tiffBlock(6, true))
+
+	res, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Width != 1000 || res.Height != 2000 {
+		t.Errorf("Process() = %dx%d, want 1000x2000", res.Width, res.Height)
+	}
+	if res.Blurhash == "" {
+		t.Error("Process() returned no blurhash")
+	}
+	if len(res.Derivatives) != len(Variants) {
+		t.Fatalf("Process() made %d derivatives, want %d", len(res.Derivatives), len(Variants))
+	}
+
+	for i, d := range res.Derivatives {
+		v := Variants[i]
+		wantW := min(v.Width, 1000)
+		if d.Name != v.Name || d.Width != wantW || d.Height != wantW*2 {
+			t.Errorf("derivative %d = %s %dx%d, want %s %dx%d", i, d.Name, d.Width, d.Height, v.Name, wantW, wantW*2)
+		}
+		if exifTIFF(d.Data) != nil {
+			t.Errorf("%s derivative carries EXIF", d.Name)
+		}
+		if bytes.Contains(d.Data, latitudeBytes()) {
+			t.Errorf("%s derivative carries the GPS latitude", d.Name)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(d.Data))
+		if err != nil || cfg.Width != d.Width || cfg.Height != d.Height {
+			t.Errorf("%s derivative decodes as %dx%d (%v), want %dx%d", d.Name, cfg.Width, cfg.Height, err, d.Width, d.Height)
+		}
+	}
+}
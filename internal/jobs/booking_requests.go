@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+)
+
+const requestExpiryBatchSize = 50
+
+var requestExpirer = models.Actor{RequestID: "job:request-expiry"}
+
+// ExpireBookingRequests gives back the dates of booking requests the host
+// did not answer in time.
+func (r *Runner) ExpireBookingRequests(ctx context.Context) error {
+	ids, err := r.repo.DueRequestExpiries(requestExpiryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := r.repo.ExpireBookingRequest(id, requestExpirer)
+		// answered or withdrawn since it was listed
+		if errors.Is(err, repository.ErrNotRequested) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(ids) > 0 {
+		r.cfg.Logger.Info("Expired unanswered booking requests", "count", len(ids))
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/ical"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+)
+
+const calendarBatchSize = 20
+
+var calendarRefresher = models.Actor{RequestID: "job:calendar-import"}
+
+// RefreshCalendars re-fetches imported calendars that are due and replaces
+// their blocked ranges. A failed fetch keeps the previous ranges.
+func (r *Runner) RefreshCalendars(ctx context.Context) error {
+	imports, err := r.repo.DueCalendarImports(calendarBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, imp := range imports {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		events, err := ical.Fetch(ctx, r.icalClient, *imp.URL)
+		if err != nil {
+			r.cfg.Logger.Error("Calendar import failed", "import", imp.ID, "error", err)
+			if err := r.repo.FailCalendarImport(imp.ID, err, r.cfg.ICal.RefreshInterval); err != nil {
+				return err
+			}
+			continue
+		}
+
+		synced, err := r.repo.SyncCalendarImport(imp.ID, events, r.cfg.ICal.RefreshInterval, calendarRefresher)
+		if err != nil {
+			return err
+		}
+		if synced.ConflictCount > 0 {
+			r.cfg.Logger.Error("Imported calendar overlaps bookings", "import", imp.ID, "property", imp.PropertyID, "conflicts", synced.ConflictCount)
+		}
+	}
+
+	return nil
+}
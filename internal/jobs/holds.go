@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+)
+
+const holdExpiryBatchSize = 50
+
+var holdExpirer = models.Actor{RequestID: "job:hold-expiry"}
+
+// ExpireHolds gives back the dates of holds that were not confirmed in
+// time.
+func (r *Runner) ExpireHolds(ctx context.Context) error {
+	ids, err := r.repo.DueHoldExpiries(holdExpiryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		err := r.repo.ExpireHold(id, holdExpirer)
+		// confirmed or released since it was listed
+		if errors.Is(err, repository.ErrNotHeld) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(ids) > 0 {
+		r.cfg.Logger.Info("Expired booking holds", "count", len(ids))
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package jobs
+
+import "context"
+
+// PurgeIdempotencyKeys drops stored responses whose idempotency keys have
+// expired.
+func (r *Runner) PurgeIdempotencyKeys(ctx context.Context) error {
+	n, err := r.repo.PurgeIdempotencyKeys()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		r.cfg.Logger.Info("Purged expired idempotency keys", "count", n)
+	}
+
+	return nil
+}
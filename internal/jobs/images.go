@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/imaging"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+)
+
+var imageWorker = models.Actor{RequestID: "job:image-derivatives"}
+
+// ProcessImages generates derivatives for a batch of newly added images.
+func (r *Runner) ProcessImages(ctx context.Context) error {
+	images, err := r.repo.ClaimPendingImages(4, 10*time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to claim pending images: %w", err)
+	}
+
+	for _, img := range images {
+		if err := r.processImage(ctx, img); err != nil {
+			r.cfg.Logger.Error("Failed to process image", "image_id", img.ImageID, "error", err)
+
+			if err := r.repo.MarkImageFailed(img.ImageID, err.Error(), imageWorker); err != nil {
+				r.cfg.Logger.Error("Failed to mark image as failed", "image_id", img.ImageID, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) processImage(ctx context.Context, img models.PendingImage) error {
+	data, err := r.loadOriginal(ctx, img)
+	if err != nil {
+		return err
+	}
+
+	result, err := imaging.Process(data)
+	if err != nil {
+		return err
+	}
+
+	var variants []models.ImageVariant
+	var keys []string
+
+	for _, d := range result.Derivatives {
+		key := fmt.Sprintf("properties/%s/%s_%s.jpg", img.PropertyID, img.ImageID, d.Name)
+
+		url, err := r.store.Put(ctx, key, bytes.NewReader(d.Data), int64(len(d.Data)), "image/jpeg")
+		if err != nil {
+			r.deleteBlobs(ctx, keys)
+			return fmt.Errorf("failed to store %s: %w", d.Name, err)
+		}
+		keys = append(keys, key)
+
+		variants = append(variants, models.ImageVariant{
+			Name:       d.Name,
+			URL:        url,
+			Width:      d.Width,
+			Height:     d.Height,
+			StorageKey: key,
+		})
+	}
+
+	err = r.repo.SaveImageDerivatives(img.ImageID, result.Width, result.Height, result.Blurhash, variants, imageWorker)
+	if err != nil {
+		r.deleteBlobs(ctx, keys)
+
+		// The image was deleted while we worked on it.
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// loadOriginal reads the uploaded blob, or downloads images that were added
+// by URL.
+func (r *Runner) loadOriginal(ctx context.Context, img models.PendingImage) ([]byte, error) {
+	limit := r.cfg.Storage.MaxUploadSize
+
+	var body io.ReadCloser
+	if img.StorageKey != "" {
+		rc, err := r.store.Get(ctx, img.StorageKey)
+		if err != nil {
+			return nil, err
+		}
+		body = rc
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, img.ImageURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image url: %w", err)
+		}
+
+		res, err := r.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image: %w", err)
+		}
+
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("failed to download image: %s", res.Status)
+		}
+		body = res.Body
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("image exceeds %d bytes", limit)
+	}
+
+	return data, nil
+}
+
+func (r *Runner) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := r.store.Delete(ctx, key); err != nil {
+			r.cfg.Logger.Error("Failed to delete blob", "key", key, "error", err)
+		}
+	}
+}
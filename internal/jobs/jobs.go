@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/config"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/ical"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/payments"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/safehttp"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/storage"
+)
+
+// Runner owns the background jobs that run alongside the API server.
+type Runner struct {
+	cfg   *config.Config
+	repo  *repository.Repository
+	store storage.Storage
+	gate  payments.Provider
+	// client downloads host-supplied image URLs.
+	client *http.Client
+	// icalClient fetches host-supplied calendar URLs.
+	icalClient *http.Client
+}
+
+func NewRunner(cfg *config.Config, repo *repository.Repository, store storage.Storage, gate payments.Provider) *Runner {
+	return &Runner{
+		cfg:        cfg,
+		repo:       repo,
+		store:      store,
+		gate:       gate,
+		client:     safehttp.NewClient(30*time.Second, false),
+		icalClient: ical.NewClient(30*time.Second, cfg.ICal.AllowPrivateHosts),
+	}
+}
+
+// Start launches every job in its own goroutine; they stop when ctx is done.
+func (r *Runner) Start(ctx context.Context) {
+	go r.every(ctx, "image-derivatives", 5*time.Second, r.ProcessImages)
+	go r.every(ctx, "property-purge", time.Hour, r.PurgeProperties)
+	go r.every(ctx, "calendar-import", time.Minute, r.RefreshCalendars)
+	go r.every(ctx, "payment-expiry", time.Minute, r.ExpirePayments)
+	go r.every(ctx, "refund-retry", time.Minute, r.RetryRefunds)
+	go r.every(ctx, "hold-expiry", time.Minute, r.ExpireHolds)
+	go r.every(ctx, "request-expiry", time.Minute, r.ExpireBookingRequests)
+	go r.every(ctx, "idempotency-purge", time.Hour, r.PurgeIdempotencyKeys)
+}
+
+func (r *Runner) every(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil {
+			r.cfg.Logger.Error("Background job failed", "job", name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/repository"
+)
+
+const paymentExpiryBatchSize = 50
+
+var paymentExpirer = models.Actor{RequestID: "job:payment-expiry"}
+
+// ExpirePayments gives up on payments that were not captured in time,
+// releasing their bookings' dates, and voids the authorizations of those
+// the gateway had authorized.
+func (r *Runner) ExpirePayments(ctx context.Context) error {
+	due, err := r.repo.DuePaymentExpiries(paymentExpiryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range due {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		_, err := r.repo.UpdatePaymentStatus(p.ID, models.PaymentExpired, "", "payment timed out", paymentExpirer)
+		// settled by a webhook since it was listed
+		if errors.Is(err, repository.ErrPaymentState) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// a failed void only leaves the funds held until the
+		// authorization lapses on the gateway's side
+		if p.Status == models.PaymentAuthorized && p.ProviderRef != nil {
+			if err := r.gate.Void(ctx, *p.ProviderRef); err != nil {
+				r.cfg.Logger.Error("Failed to void payment", "payment", p.ID, "error", err)
+			}
+		}
+	}
+
+	if len(due) > 0 {
+		r.cfg.Logger.Info("Expired unpaid bookings", "count", len(due))
+	}
+
+	return nil
+}
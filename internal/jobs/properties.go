@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+)
+
+const purgeBatchSize = 50
+
+var propertyPurger = models.Actor{RequestID: "job:property-purge"}
+
+// PurgeProperties permanently deletes properties whose soft-delete is older
+// than the configured retention, along with their image blobs.
+func (r *Runner) PurgeProperties(ctx context.Context) error {
+	for {
+		n, keys, err := r.repo.PurgeDeletedProperties(r.cfg.PropertyRetention, purgeBatchSize, propertyPurger)
+		if err != nil {
+			return err
+		}
+
+		r.deleteBlobs(ctx, keys)
+
+		if n > 0 {
+			r.cfg.Logger.Info("Purged deleted properties", "count", n)
+		}
+
+		if n < purgeBatchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
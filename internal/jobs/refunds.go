@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+const refundRetryBatchSize = 20
+
+var refundRetrier = models.Actor{RequestID: "job:refund-retry"}
+
+// RetryRefunds pays back refunds still due on cancelled bookings' payments,
+// because the gateway turned them down or the cancelling request never got
+// to them. Another failure is recorded on the payment, which is tried
+// again later.
+func (r *Runner) RetryRefunds(ctx context.Context) error {
+	due, err := r.repo.DueRefunds(refundRetryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	refunded := 0
+	for _, p := range due {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if p.ProviderRef == nil {
+			continue
+		}
+
+		refund, err := r.gate.Refund(ctx, *p.ProviderRef, money.Money{Amount: p.RefundDue, Currency: p.Currency})
+		if err != nil {
+			r.cfg.Logger.Error("Failed to refund payment", "payment", p.ID, "error", err)
+			if _, err := r.repo.RecordRefundFailure(p.ID, err.Error(), refundRetrier); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := r.repo.RecordRefund(p.ID, p.RefundDue, refund.Ref, p.RefundReason, refundRetrier); err != nil {
+			return err
+		}
+		refunded++
+	}
+
+	if refunded > 0 {
+		r.cfg.Logger.Info("Retried refunds", "count", refunded)
+	}
+
+	return nil
+}
@@ -2,28 +2,31 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/geo"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
 	"github.com/google/uuid"
 )
 
 type User struct {
 	ID           uuid.UUID `json:"id"`
 	FirstName    string    `json:"first_name"`
-	LastName   string      `json:"last_name"`
+	LastName     string    `json:"last_name"`
 	Email        string    `json:"email"`
 	CreatedAt    string    `json:"created_at"`
 	UpdatedAt    string    `json:"updated_at"`
 	PasswordHash string    `json:"password_hash"`
 }
 
-
 type UserDetails struct {
-	ID uuid.UUID `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName string `json:"last_name"`
-	Email string `json:"email"`
-	Role string `json:"role"`
+	ID        uuid.UUID `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
 }
 
 type RegisterUser struct {
@@ -41,44 +44,459 @@ type LoginUser struct {
 }
 
 type Property struct {
-	ID            uuid.UUID `json:"id"`
-	Title         string    `json:"title"`
-	Location      string    `json:"location"`
-	Description   string    `json:"description"`
-	PricePerNight float32   `json:"price_per_night"`
-	MaxGuests     int       `json:"max_guests"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	Images []struct {
-		ImageID 	 uuid.UUID `json:"image_id"`
-		ImageURL     string    `json:"image_url"`
-		Caption      string    `json:"caption"`
-		DisplayOrder int       `json:"display_order"`
-	} `json:"images"`
-	Amenities []struct {
-		AmenityID uuid.UUID `json:"amenity_id"`
-		Name 	  string    `json:"name"`
-	} `json:"amenities"`
+	ID            uuid.UUID    `json:"id"`
+	Title         string       `json:"title"`
+	Location      string       `json:"location"`
+	Description   string       `json:"description"`
+	PricePerNight money.Amount `json:"price_per_night"`
+	Currency      string       `json:"currency"`
+	MaxGuests     int          `json:"max_guests"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	Jurisdiction  Jurisdiction `json:"jurisdiction"`
+	PropertyAttributes
+	Images    []PropertyImage `json:"images"`
+	Amenities []Amenity       `json:"amenities"`
+	// Latitude and Longitude are the real position on writes. On reads they
+	// are replaced by the fuzzed public position unless LocationExact is set.
+	Latitude        *float64      `json:"latitude"`
+	Longitude       *float64      `json:"longitude"`
+	LocationExact   bool          `json:"location_exact"`
+	PublicLatitude  *float64      `json:"-"`
+	PublicLongitude *float64      `json:"-"`
+	HostID          uuid.NullUUID `json:"-"`
+	Status          string        `json:"status"`
+	Version         int           `json:"version"`
+	// ReviewNote is the moderator's rejection or suspension reason.
+	ReviewNote *string `json:"review_note,omitempty"`
+	// DisplayPrice is the nightly price in the currency the guest asked
+	// for; PricePerNight stays in the listing's own currency.
+	DisplayPrice *DisplayPrice `json:"display_price,omitempty"`
+}
+
+type DisplayPrice struct {
+	PricePerNight money.Money `json:"price_per_night"`
+	ExchangeRate  fx.Rate     `json:"exchange_rate"`
+}
+
+// EditableProperty holds the listing fields a host can change with PUT or
+// PATCH. It is also the document JSON Merge Patches are applied to.
+type EditableProperty struct {
+	Title         string       `json:"title"`
+	Location      string       `json:"location"`
+	Description   string       `json:"description"`
+	PricePerNight money.Amount `json:"price_per_night"`
+	// Currency of the price and of all pricing rules. Empty in revisions
+	// saved before listings had a currency, meaning unchanged.
+	Currency string `json:"currency"`
+	// Jurisdiction decides which taxes apply. Nil in revisions saved before
+	// listings had one, meaning unchanged.
+	Jurisdiction *Jurisdiction `json:"jurisdiction"`
+	MaxGuests    int           `json:"max_guests"`
+	Latitude     *float64      `json:"latitude"`
+	Longitude    *float64      `json:"longitude"`
+	PropertyAttributes
+}
+
+// RevisionSnapshot is a listing as it was at one version.
+type RevisionSnapshot struct {
+	EditableProperty
+	Images    []RevisionImage   `json:"images"`
+	Amenities []RevisionAmenity `json:"amenities"`
+}
+
+type RevisionImage struct {
+	ImageID  uuid.UUID `json:"image_id"`
+	ImageURL string    `json:"image_url"`
+	Caption  string    `json:"caption"`
+}
+
+type RevisionAmenity struct {
+	AmenityID uuid.UUID `json:"amenity_id"`
+	Name      string    `json:"name"`
+}
+
+// PropertyRevision is one saved version of a listing. Diff lists the fields
+// that changed since the previous revision, keyed by dotted path.
+type PropertyRevision struct {
+	Version       int                    `json:"version"`
+	Action        string                 `json:"action"`
+	SourceVersion *int                   `json:"source_version,omitempty"`
+	CreatedBy     uuid.NullUUID          `json:"created_by"`
+	CreatedAt     time.Time              `json:"created_at"`
+	Snapshot      RevisionSnapshot       `json:"snapshot"`
+	Diff          map[string]FieldChange `json:"diff"`
+}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// RollbackResult reports a rollback; images deleted since the target
+// revision cannot be brought back and are listed in MissingImages.
+type RollbackResult struct {
+	Version       int         `json:"version"`
+	MissingImages []uuid.UUID `json:"missing_images"`
+}
+
+// StayRule limits stays by their check-in date. CheckInDays holds weekday
+// numbers (0 = Sunday); empty allows any day. On an override, nil fields
+// inherit the property default.
+type StayRule struct {
+	StartDate   string `json:"start_date,omitempty"` // first check-in date covered
+	EndDate     string `json:"end_date,omitempty"`   // exclusive
+	MinNights   *int   `json:"min_nights"`
+	MaxNights   *int   `json:"max_nights"`
+	CheckInDays []int  `json:"check_in_days"`
+}
+
+// StayRules are a property's default stay rule and its dated overrides.
+type StayRules struct {
+	Default   StayRule   `json:"default"`
+	Overrides []StayRule `json:"overrides"`
+}
+
+// CalendarBlock marks nights a host has taken off the market.
+type CalendarBlock struct {
+	ID        uuid.UUID `json:"id"`
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"` // exclusive
+	Reason    string    `json:"reason"`
+	// ImportID is set on ranges created from an imported calendar.
+	ImportID *uuid.UUID `json:"import_id,omitempty"`
+}
+
+// CalendarImport is an external calendar synced into blocked ranges. URL is
+// nil for uploaded files, which are not refreshed.
+type CalendarImport struct {
+	ID            uuid.UUID  `json:"id"`
+	PropertyID    uuid.UUID  `json:"property_id"`
+	Name          string     `json:"name"`
+	URL           *string    `json:"url"`
+	EventCount    int        `json:"event_count"`
+	ConflictCount int        `json:"conflict_count"` // events overlapping local bookings
+	LastSyncedAt  *time.Time `json:"last_synced_at"`
+	LastError     *string    `json:"last_error"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type CalendarImportRequest struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type CalendarDay struct {
+	Date           string       `json:"date"`
+	Status         string       `json:"status"` // available, booked, blocked or past
+	Available      bool         `json:"available"`
+	Price          money.Amount `json:"price"`
+	MinNights      int          `json:"min_nights"`
+	MaxNights      *int         `json:"max_nights"`
+	CheckInAllowed bool         `json:"check_in_allowed"`
+}
+
+type PropertyCalendar struct {
+	PropertyID uuid.UUID       `json:"property_id"`
+	Month      string          `json:"month"`
+	Currency   string          `json:"currency"`
+	Days       []CalendarDay   `json:"days"`
+	Blocks     []CalendarBlock `json:"blocks,omitempty"` // host and admins only
+}
+
+// RateRule sets the nightly price for nights in a date range (seasonal),
+// on given weekdays (0 = Sunday), or both. The most specific matching rule
+// wins; among equally specific rules the later one in the list wins.
+type RateRule struct {
+	Name          string       `json:"name"`
+	StartDate     string       `json:"start_date,omitempty"`
+	EndDate       string       `json:"end_date,omitempty"` // exclusive
+	Weekdays      []int        `json:"weekdays,omitempty"`
+	PricePerNight money.Amount `json:"price_per_night"`
+}
+
+// PricingRules are everything that goes into a property's price quote.
+type PricingRules struct {
+	Currency               string       `json:"currency"`   // the property's; edited with the property
+	BasePrice              money.Amount `json:"base_price"` // price_per_night; edited with the property
+	CleaningFee            money.Amount `json:"cleaning_fee"`
+	ExtraGuestFee          money.Amount `json:"extra_guest_fee"` // per night for each guest above guests_included
+	GuestsIncluded         int          `json:"guests_included"`
+	WeeklyDiscountPercent  float64      `json:"weekly_discount_percent"`  // stays of 7 nights or more
+	MonthlyDiscountPercent float64      `json:"monthly_discount_percent"` // stays of 28 nights or more; replaces the weekly discount
+	Rates                  []RateRule   `json:"rates"`
+	MaxGuests              int          `json:"-"`
+}
+
+type NightlyRate struct {
+	Date  string       `json:"date"`
+	Price money.Amount `json:"price"`
+	Rule  string       `json:"rule,omitempty"` // name of the rate rule applied, if any
+}
+
+// PriceQuote is the itemised price of a stay. Discounts apply to the nightly
+// subtotal only. ExchangeRate is set when the amounts were converted from
+// the property's currency.
+type PriceQuote struct {
+	Currency      string         `json:"currency"`
+	CheckIn       string         `json:"check_in"`
+	CheckOut      string         `json:"check_out"`
+	Nights        int            `json:"nights"`
+	Guests        int            `json:"guests"`
+	NightlyRates  []NightlyRate  `json:"nightly_rates"`
+	Subtotal      money.Amount   `json:"subtotal"`
+	DiscountType  string         `json:"discount_type,omitempty"` // weekly or monthly
+	Discount      money.Amount   `json:"discount"`
+	ExtraGuestFee money.Amount   `json:"extra_guest_fee"`
+	CleaningFee   money.Amount   `json:"cleaning_fee"`
+	Coupon        *AppliedCoupon `json:"coupon,omitempty"`
+	Taxes         []TaxLine      `json:"taxes"`
+	Tax           money.Amount   `json:"tax"`
+	Total         money.Amount   `json:"total"` // including tax
+	ExchangeRate  *fx.Rate       `json:"exchange_rate,omitempty"`
+}
+
+// GuestCount is who is coming on a stay. Adults and children count
+// towards the listing's max_guests and extra-guest fee; infants do not.
+// Pets need a listing that allows them.
+type GuestCount struct {
+	Adults   int `json:"adults"`
+	Children int `json:"children"`
+	Infants  int `json:"infants"`
+	Pets     int `json:"pets"`
+}
+
+// Guests is the number of guests that count towards the listing's limits.
+func (g GuestCount) Guests() int {
+	return g.Adults + g.Children
+}
+
+// Co-guest age groups.
+const (
+	AgeAdult  = "adult"
+	AgeChild  = "child"
+	AgeInfant = "infant"
+)
+
+// CoGuest names someone else in a booking's party.
+type CoGuest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	AgeGroup  string `json:"age_group"` // adult, child or infant
+}
+
+// StayRequest is a stay to quote or book. CouponCode is optional; UserID
+// is the guest, used for per-guest coupon limits, and may be nil for
+// anonymous quotes. CoGuests are only stored by bookings.
+type StayRequest struct {
+	PropertyID uuid.UUID
+	StartDate  time.Time
+	EndDate    time.Time // check-out
+	Party      GuestCount
+	CoGuests   []CoGuest
+	CouponCode string
+	UserID     uuid.UUID
+	// BookingID is set when repricing an existing booking: its own nights
+	// do not count against the stay, and it keeps the coupon it redeemed.
+	BookingID uuid.UUID
+}
+
+// Coupon kinds.
+const (
+	CouponPercentage = "percentage" // percent off the nightly subtotal
+	CouponFixed      = "fixed"      // amount off the nightly subtotal
+)
+
+// Coupon is a promo code. PropertyID or HostID, when set, limit it to one
+// listing or one host's listings.
+type Coupon struct {
+	ID                    uuid.UUID    `json:"id"`
+	Code                  string       `json:"code"`
+	Description           string       `json:"description"`
+	Kind                  string       `json:"kind"`
+	Percent               *float64     `json:"percent,omitempty"`
+	Amount                *money.Money `json:"amount,omitempty"`
+	ValidFrom             *time.Time   `json:"valid_from"` // defaults to now
+	ValidUntil            *time.Time   `json:"valid_until"`
+	MaxRedemptions        *int         `json:"max_redemptions"`
+	MaxRedemptionsPerUser *int         `json:"max_redemptions_per_user"`
+	MinNights             int          `json:"min_nights"`
+	PropertyID            *uuid.UUID   `json:"property_id"`
+	HostID                *uuid.UUID   `json:"host_id"`
+	Active                *bool        `json:"active"` // defaults to true
+	RedemptionCount       int          `json:"redemption_count"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at"`
+}
+
+// AppliedCoupon is the coupon discount in a quote.
+type AppliedCoupon struct {
+	ID       uuid.UUID    `json:"-"`
+	Code     string       `json:"code"`
+	Discount money.Amount `json:"discount"`
+}
+
+// Jurisdiction is where a listing is for tax purposes. Country is an
+// ISO 3166-1 alpha-2 code; listings without one are not taxed.
+type Jurisdiction struct {
+	Country string `json:"country"`
+	Region  string `json:"region"`
+	City    string `json:"city"`
+}
+
+// Tax rule kinds.
+const (
+	TaxPercentage = "percentage" // percent of the stay's price
+	TaxPerNight   = "per_night"  // flat amount per night
+)
+
+// TaxRule is an admin-defined tax. It applies to listings in its country
+// and, when set, its region and city, for nights from EffectiveFrom up to
+// EffectiveTo.
+type TaxRule struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Jurisdiction
+	Kind          string       `json:"kind"`
+	Percent       *float64     `json:"percent,omitempty"` // percentage rules
+	Amount        *money.Money `json:"amount,omitempty"`  // per_night rules
+	EffectiveFrom string       `json:"effective_from"`
+	EffectiveTo   string       `json:"effective_to,omitempty"` // exclusive
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// TaxLine is one tax charged in a quote, for the Nights it was in effect.
+type TaxLine struct {
+	RuleID uuid.UUID `json:"rule_id"`
+	Name   string    `json:"name"`
+	Jurisdiction
+	Kind    string       `json:"kind"`
+	Percent *float64     `json:"percent,omitempty"`
+	Nights  int          `json:"nights"`
+	Amount  money.Amount `json:"amount"`
+}
+
+// TaxReportRow totals the tax collected under one rule in one currency,
+// next to the revenue of the bookings it was charged on.
+type TaxReportRow struct {
+	RuleID *uuid.UUID `json:"rule_id"` // nil once the rule is deleted
+	Name   string     `json:"name"`
+	Jurisdiction
+	Currency string       `json:"currency"`
+	Bookings int          `json:"bookings"`
+	Tax      money.Amount `json:"tax"`
+	Revenue  money.Amount `json:"revenue"` // booking totals without tax
+}
+
+// Listing states. Only published listings are visible to the public.
+const (
+	ListingDraft         = "draft"
+	ListingPendingReview = "pending_review"
+	ListingPublished     = "published"
+	ListingSuspended     = "suspended"
+	ListingArchived      = "archived"
+)
+
+// ModerationItem is a listing waiting in the review queue.
+type ModerationItem struct {
+	ID          uuid.UUID     `json:"id"`
+	Title       string        `json:"title"`
+	Location    string        `json:"location"`
+	Description string        `json:"description"`
+	HostID      uuid.NullUUID `json:"host_id"`
+	ImageCount  int           `json:"image_count"`
+	SubmittedAt time.Time     `json:"submitted_at"`
+}
+
+type ListingActionRequest struct {
+	Reason string `json:"reason"`
 }
 
 type GetProperty struct {
-	ID            uuid.UUID `json:"id"`
-	Title         string    `json:"title"`
-	Location      string    `json:"location"`
-	PricePerNight float32   `json:"price_per_night"`
-	MaxGuests     int       `json:"max_guests"`
-	CreatedAt     time.Time `json:"created_at"`
-	ThumbnailURL  sql.NullString    `json:"thumbnail_url"`
+	ID                uuid.UUID      `json:"id"`
+	Title             string         `json:"title"`
+	Location          string         `json:"location"`
+	PricePerNight     money.Amount   `json:"price_per_night"`
+	Currency          string         `json:"currency"`
+	MaxGuests         int            `json:"max_guests"`
+	CreatedAt         time.Time      `json:"created_at"`
+	ThumbnailURL      sql.NullString `json:"thumbnail_url"`
+	ThumbnailSrcset   *string        `json:"thumbnail_srcset"`
+	ThumbnailBlurhash *string        `json:"thumbnail_blurhash"`
+	PropertyAttributes
+	// Set only when searching with a text query.
+	Rank *float64 `json:"rank,omitempty"`
+	// HTML-escaped snippet whose only markup is <mark> around matches.
+	Highlight *string `json:"highlight,omitempty"`
+	// Public (fuzzed) position, and the distance from it when searching by radius.
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+	// Set by the availability search: the price of the searched stay.
+	Quote *PriceQuote `json:"quote,omitempty"`
 }
 
 type PostProperty struct {
-	Title         string    `json:"title"`
-	Location      string    `json:"location"`
-	Description   string    `json:"description"`
-	PricePerNight float32   `json:"price_per_night"`
-	MaxGuests     int       `json:"max_guests"`
-	ImageURL      string    `json:"image_url"`
-	UserID        uuid.UUID `json:"user_id"`
+	Title         string       `json:"title"`
+	Location      string       `json:"location"`
+	Description   string       `json:"description"`
+	PricePerNight money.Amount `json:"price_per_night"`
+	Currency      string       `json:"currency"` // defaults to USD
+	Jurisdiction  Jurisdiction `json:"jurisdiction"`
+	MaxGuests     int          `json:"max_guests"`
+	ImageURL      string       `json:"image_url"`
+	UserID        uuid.UUID    `json:"user_id"`
+	Latitude      *float64     `json:"latitude"`
+	Longitude     *float64     `json:"longitude"`
+	PropertyAttributes
+}
+
+// PropertyTypes lists the accepted values of PropertyAttributes.PropertyType.
+var PropertyTypes = []string{"apartment", "house", "villa", "cabin", "cottage", "condo", "guesthouse", "hotel_room", "other"}
+
+// PropertyAttributes are the structured facts guests filter on.
+type PropertyAttributes struct {
+	PropertyType string     `json:"property_type"`
+	Bedrooms     int        `json:"bedrooms"`
+	Beds         int        `json:"beds"`
+	Bathrooms    float32    `json:"bathrooms"`
+	CheckInTime  string     `json:"check_in_time"`  // HH:MM
+	CheckOutTime string     `json:"check_out_time"` // HH:MM
+	HouseRules   HouseRules `json:"house_rules"`
+	// CancellationPolicy decides how much guests get back when they
+	// cancel; bookings keep the policy they were made under.
+	CancellationPolicy string `json:"cancellation_policy"`
+	// BookingMode is whether bookings are confirmed instantly or sent to
+	// the host as requests.
+	BookingMode string `json:"booking_mode"`
+}
+
+// Booking modes of a listing.
+const (
+	BookInstantly = "instant"
+	BookOnRequest = "request"
+)
+
+// BookingModes lists the accepted values of PropertyAttributes.BookingMode.
+var BookingModes = []string{BookInstantly, BookOnRequest}
+
+// Cancellation policies, from most to least generous to guests.
+const (
+	PolicyFlexible      = "flexible"
+	PolicyModerate      = "moderate"
+	PolicyStrict        = "strict"
+	PolicyNonRefundable = "non_refundable"
+)
+
+// CancellationPolicies lists the accepted values of
+// PropertyAttributes.CancellationPolicy.
+var CancellationPolicies = []string{PolicyFlexible, PolicyModerate, PolicyStrict, PolicyNonRefundable}
+
+type HouseRules struct {
+	PetsAllowed    bool `json:"pets_allowed"`
+	SmokingAllowed bool `json:"smoking_allowed"`
+	EventsAllowed  bool `json:"events_allowed"`
 }
 
 type Booking struct {
@@ -87,10 +505,23 @@ type Booking struct {
 		Title    string `json:"title"`
 		Location string `json:"location"`
 	}
-	StartDate  time.Time `json:"start_date"`
-	EndDate    time.Time `json:"end_date"`
-	TotalPrice float32       `json:"total_price"`
-	Status     string    `json:"status"`
+	StartDate  time.Time    `json:"start_date"`
+	EndDate    time.Time    `json:"end_date"`
+	TotalPrice money.Amount `json:"total_price"`
+	Tax        money.Amount `json:"tax"` // included in TotalPrice
+	Currency   string       `json:"currency"`
+	Status     string       `json:"status"`
+	BookingDisplay
+	// RespondBy is when the host has to answer a booking request.
+	RespondBy *time.Time `json:"respond_by,omitempty"`
+}
+
+// BookingDisplay is the total in the currency the guest booked in, at the
+// exchange rate locked when the booking was made. Both are nil when the
+// guest paid in the property's currency.
+type BookingDisplay struct {
+	DisplayTotal *money.Money `json:"display_total,omitempty"`
+	ExchangeRate *fx.Rate     `json:"exchange_rate,omitempty"`
 }
 
 type GetBooking struct {
@@ -99,27 +530,253 @@ type GetBooking struct {
 		Title    string `json:"title"`
 		Location string `json:"location"`
 	}
-	FirstName  string    `json:"first_name"`
-	LastName   string    `json:"last_name"`
-	StartDate  time.Time `json:"start_date"`
-	EndDate    time.Time `json:"end_date"`
-	TotalPrice float32       `json:"total_price"`
-	Status     string    `json:"status"`
+	FirstName  string       `json:"first_name"`
+	LastName   string       `json:"last_name"`
+	StartDate  time.Time    `json:"start_date"`
+	EndDate    time.Time    `json:"end_date"`
+	TotalPrice money.Amount `json:"total_price"`
+	Tax        money.Amount `json:"tax"` // included in TotalPrice
+	Currency   string       `json:"currency"`
+	Status     string       `json:"status"`
+	BookingDisplay
+	Guests   int        `json:"guests"` // adults and children
+	Party    GuestCount `json:"party"`
+	CoGuests []CoGuest  `json:"co_guests"`
+	// PriceBreakdown is the quote the booking was priced with; nil for
+	// bookings made before pricing rules.
+	PriceBreakdown     *PriceQuote `json:"price_breakdown"`
+	Payments           []Payment   `json:"payments"`
+	CancellationPolicy string      `json:"cancellation_policy"`
+	// Refund is set once the booking is cancelled.
+	Refund *RefundBreakdown `json:"refund,omitempty"`
+	// HoldExpiresAt is set on bookings that started as a hold.
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+	// RespondBy is set on bookings that started as a request: the host
+	// accepts or declines before then.
+	RespondBy     *time.Time `json:"respond_by,omitempty"`
+	DeclineReason string     `json:"decline_reason,omitempty"`
+}
+
+// HoldReport counts the holds placed in a period by what became of them.
+// ConversionRate is Converted over the holds that are no longer held.
+type HoldReport struct {
+	From                time.Time `json:"from"`
+	To                  time.Time `json:"to"`
+	Created             int       `json:"created"`
+	Converted           int       `json:"converted"`
+	Expired             int       `json:"expired"`
+	Released            int       `json:"released"`
+	Active              int       `json:"active"`
+	ConversionRate      float64   `json:"conversion_rate"`
+	AvgSecondsToConvert float64   `json:"avg_seconds_to_convert"`
+}
+
+// Who cancelled a booking.
+const (
+	CancelledByGuest = "guest"
+	CancelledByHost  = "host"
+)
+
+// RefundBreakdown is what a cancelled booking gives back, in the booking's
+// currency. Nights is the booking total less cleaning fee and tax.
+type RefundBreakdown struct {
+	Policy             string       `json:"policy"`
+	CancelledBy        string       `json:"cancelled_by"`
+	HoursBeforeCheckIn float64      `json:"hours_before_check_in"` // negative once the stay has begun
+	RefundPercent      float64      `json:"refund_percent"`        // of the nights
+	BookingTotal       money.Amount `json:"booking_total"`
+	Nights             money.Amount `json:"nights"`
+	CleaningFee        money.Amount `json:"cleaning_fee"`
+	Tax                money.Amount `json:"tax"`
+	Total              money.Amount `json:"total"`
+	Currency           string       `json:"currency"`
+}
+
+// HostPenalty is charged to a host for cancelling a guest's booking.
+type HostPenalty struct {
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
+	Reason   string       `json:"reason"`
+}
+
+// Cancellation is the outcome of cancelling a booking. Payments are the
+// booking's payments after their refunds.
+type Cancellation struct {
+	BookingID uuid.UUID       `json:"booking_id"`
+	Status    string          `json:"status"`
+	Refund    RefundBreakdown `json:"refund"`
+	Penalty   *HostPenalty    `json:"penalty,omitempty"`
+	Payments  []Payment       `json:"payments"`
+}
+
+// Booking states. A booking holds its nights while it is held, while the
+// host considers a request, while it waits for payment and once it is
+// confirmed; an expired or released hold, a declined or unanswered request
+// and a failed or timed-out payment give them back.
+const (
+	BookingHeld           = "held"
+	BookingHoldExpired    = "hold_expired"
+	BookingHoldReleased   = "hold_released" // let go by the guest
+	BookingRequested      = "requested"     // waiting for the host to accept
+	BookingDeclined       = "declined"
+	BookingRequestExpired = "request_expired" // the host did not answer in time
+	BookingPendingPayment = "pending_payment"
+	BookingConfirmed      = "confirmed"
+	BookingPaymentFailed  = "payment_failed"
+	BookingCancelled      = "cancelled"
+)
+
+// Payment states.
+const (
+	PaymentPending           = "pending"    // waiting for the gateway to authorize
+	PaymentAuthorized        = "authorized" // funds held, not yet captured
+	PaymentCaptured          = "captured"
+	PaymentFailed            = "failed"
+	PaymentExpired           = "expired" // not captured before ExpiresAt
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// States of a refund still due on a payment.
+const (
+	RefundPending = "pending"
+	RefundFailed  = "failed" // the gateway turned the last attempt down
+)
+
+// Payment is a charge for a booking through a payment provider. Amount is
+// what the guest was shown, in Currency.
+type Payment struct {
+	ID             uuid.UUID    `json:"id"`
+	BookingID      uuid.UUID    `json:"booking_id"`
+	Provider       string       `json:"provider"`
+	ProviderRef    *string      `json:"provider_ref"`
+	Status         string       `json:"status"`
+	Amount         money.Amount `json:"amount"`
+	Currency       string       `json:"currency"`
+	RefundedAmount money.Amount `json:"refunded_amount"`
+	FailureReason  *string      `json:"failure_reason,omitempty"`
+	// ModificationID is set on payments collecting a modification's price
+	// increase.
+	ModificationID *uuid.UUID `json:"modification_id,omitempty"`
+	// RefundDue is owed back to the guest after a cancellation but not yet
+	// refunded through the gateway. RefundStatus is RefundPending until
+	// then, or RefundFailed with RefundError once the gateway has turned it
+	// down; either is retried in the background.
+	RefundDue    money.Amount `json:"refund_due"`
+	RefundStatus string       `json:"refund_status,omitempty"`
+	RefundError  *string      `json:"refund_error,omitempty"`
+	RefundReason string       `json:"-"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
 }
 
+// Modification states. A pending modification waits for the host; the
+// others are final.
+const (
+	ModificationPending       = "pending"
+	ModificationApplied       = "applied"
+	ModificationDeclined      = "declined"
+	ModificationPaymentFailed = "payment_failed" // the price increase could not be collected
+)
+
+// BookingModification is a change to a booking's dates or guest count.
+// PriceDelta is NewTotal less OldTotal in the booking's currency;
+// PaymentDelta is the same difference in the currency the guest pays in,
+// charged or refunded when the change is applied. Quote is the booking's
+// new price.
+type BookingModification struct {
+	ID               uuid.UUID    `json:"id"`
+	BookingID        uuid.UUID    `json:"booking_id"`
+	RequestedBy      *uuid.UUID   `json:"requested_by"`
+	Status           string       `json:"status"`
+	RequiresApproval bool         `json:"requires_approval"`
+	OldStartDate     time.Time    `json:"old_start_date"`
+	OldEndDate       time.Time    `json:"old_end_date"`
+	OldGuests        int          `json:"old_guests"`
+	OldParty         GuestCount   `json:"old_party"`
+	NewStartDate     time.Time    `json:"new_start_date"`
+	NewEndDate       time.Time    `json:"new_end_date"`
+	NewGuests        int          `json:"new_guests"`
+	NewParty         GuestCount   `json:"new_party"`
+	OldTotal         money.Amount `json:"old_total"`
+	NewTotal         money.Amount `json:"new_total"`
+	PriceDelta       money.Amount `json:"price_delta"`
+	Currency         string       `json:"currency"`
+	PaymentDelta     money.Amount `json:"payment_delta"`
+	PaymentCurrency  string       `json:"payment_currency"`
+	Quote            PriceQuote   `json:"quote"`
+	PaymentMethod    string       `json:"-"`
+	DecidedBy        *uuid.UUID   `json:"decided_by,omitempty"`
+	DecisionReason   *string      `json:"decision_reason,omitempty"`
+	DecidedAt        *time.Time   `json:"decided_at,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+	// Payments are the booking's payments after the change was settled;
+	// only set in responses that settle it.
+	Payments []Payment `json:"payments,omitempty"`
+}
+
+type AddImage struct {
+	ImageURL     string `json:"image_url"`
+	Caption      string `json:"caption"`
+	DisplayOrder *int   `json:"display_order"` // appended after existing images when omitted
+	StorageKey   string `json:"-"`             // set for files uploaded through Storage
+}
 
 type AddImagesRequest struct {
-	PropertyID string `json:"-"` // filled from path param
-	Images     []struct {
-		ImageURL     string `json:"image_url"`
-		Caption      string `json:"caption"`
-		DisplayOrder int    `json:"display_order"`
-	} `json:"images"`
+	PropertyID string     `json:"-"` // filled from path param
+	Images     []AddImage `json:"images"`
+}
+
+type PropertyImage struct {
+	ImageID          uuid.UUID      `json:"image_id"`
+	ImageURL         string         `json:"image_url"`
+	Caption          string         `json:"caption"`
+	DisplayOrder     int            `json:"display_order"`
+	Width            *int           `json:"width"`
+	Height           *int           `json:"height"`
+	Blurhash         *string        `json:"blurhash"`
+	ProcessingStatus string         `json:"processing_status"`
+	Variants         []ImageVariant `json:"variants"`
+	Srcset           string         `json:"srcset"` // "<url> <width>w, ..." for the img srcset attribute
+}
+
+type ImageVariant struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	StorageKey string `json:"-"`
+}
+
+// PendingImage is an image claimed by the derivative worker.
+type PendingImage struct {
+	ImageID    uuid.UUID
+	PropertyID uuid.UUID
+	ImageURL   string
+	StorageKey string
+}
+
+type UpdateImageRequest struct {
+	Caption *string `json:"caption"`
+	Cover   bool    `json:"cover"` // make this image the listing's cover photo
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []uuid.UUID `json:"image_ids"`
+}
+
+type UploadedImage struct {
+	ImageID  uuid.UUID `json:"image_id"`
+	ImageURL string    `json:"image_url"`
+	Caption  string    `json:"caption"`
 }
 
 type Amenity struct {
 	AmenityID uuid.UUID `json:"amenity_id"`
 	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Icon      *string   `json:"icon"`
 }
 
 type PostAmenity struct {
@@ -128,19 +785,82 @@ type PostAmenity struct {
 }
 
 type AddAmenityRequest struct {
-	Name string `json:"name"`
+	Name     string  `json:"name"`
+	Category string  `json:"category"` // defaults to "general"
+	Icon     *string `json:"icon"`
+}
+
+type UpdateAmenityRequest struct {
+	Name     *string `json:"name"`
+	Category *string `json:"category"`
+	Icon     *string `json:"icon"`
 }
 
 type AddAmenitiesRequest struct {
 	AmenityID []uuid.UUID `json:"amenity_id"`
 }
 
-
 type SearchPropertyParams struct {
-	Location  string   `json:"location"`
-	StartDate string   `json:"start_date"`
-	EndDate   string   `json:"end_date"`
-	MinPrice  *float64 `json:"min_price,omitempty"`
-	MaxPrice  *float64 `json:"max_price,omitempty"`
-	Guests    *int     `json:"guests,omitempty"`
-}
\ No newline at end of file
+	Query     string `json:"q"`
+	Location  string `json:"location"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	// Price filters are in Currency; PriceRates converts each property
+	// currency into it.
+	MinPrice   *float64           `json:"min_price,omitempty"`
+	MaxPrice   *float64           `json:"max_price,omitempty"`
+	Currency   string             `json:"currency,omitempty"`
+	PriceRates map[string]float64 `json:"-"`
+	Guests     *int               `json:"guests,omitempty"`
+	// Radius search around Latitude/Longitude, or a map viewport.
+	Latitude  *float64         `json:"lat,omitempty"`
+	Longitude *float64         `json:"lng,omitempty"`
+	RadiusKm  *float64         `json:"radius_km,omitempty"`
+	BBox      *geo.BoundingBox `json:"-"`
+	// Attribute filters; counts are minimums.
+	PropertyTypes  []string `json:"property_types,omitempty"`
+	MinBedrooms    *int     `json:"min_bedrooms,omitempty"`
+	MinBeds        *int     `json:"min_beds,omitempty"`
+	MinBathrooms   *float64 `json:"min_bathrooms,omitempty"`
+	PetsAllowed    *bool    `json:"pets_allowed,omitempty"`
+	SmokingAllowed *bool    `json:"smoking_allowed,omitempty"`
+	EventsAllowed  *bool    `json:"events_allowed,omitempty"`
+}
+
+// Actor identifies who performed a write, for the audit log.
+type Actor struct {
+	UserID    uuid.UUID
+	RequestID string
+	IP        string
+}
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    uuid.NullUUID   `json:"actor_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	Diff       json.RawMessage `json:"diff"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	ActorID    uuid.UUID
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
+
+// StoredResponse is a response kept under an idempotency key, replayed to
+// retries of the request.
+type StoredResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
@@ -0,0 +1,171 @@
+// Package money represents amounts exactly, in hundredths of a currency
+// unit, so prices and totals never pass through binary floating point.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for listings created without a currency.
+const DefaultCurrency = "USD"
+
+var (
+	ErrInvalidAmount   = errors.New("amount must be a decimal number with at most two decimal places")
+	ErrUnknownCurrency = errors.New("unknown currency code")
+)
+
+// exponents lists the supported ISO 4217 codes with the number of decimals
+// their prices are rounded to.
+var exponents = map[string]int{
+	"AED": 2, "AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "IDR": 0, "ILS": 2, "INR": 2,
+	"JPY": 0, "KRW": 0, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2,
+	"SGD": 2, "THB": 2, "TRY": 2, "USD": 2, "ZAR": 2,
+}
+
+// Currencies returns the supported currency codes.
+func Currencies() []string {
+	codes := make([]string, 0, len(exponents))
+	for code := range exponents {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// NormalizeCurrency upper-cases code and checks that it is supported.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := exponents[code]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return code, nil
+}
+
+// Amount is a sum of money in hundredths of the currency unit. It is written
+// to JSON as a number with two decimals and read from a JSON number or
+// string; it scans from and binds to Postgres NUMERIC without rounding.
+type Amount int64
+
+// ParseAmount reads a decimal such as "12", "12.5" or "-0.05".
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	frac = strings.TrimRight(frac, "0")
+	if whole == "" || len(frac) > 2 || strings.ContainsAny(whole+frac, "+-eE") {
+		return 0, ErrInvalidAmount
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || units > (math.MaxInt64-cents)/100 {
+		return 0, ErrInvalidAmount
+	}
+
+	a := Amount(units*100 + cents)
+	if neg {
+		a = -a
+	}
+	return a, nil
+}
+
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, a/100, a%100)
+}
+
+// Float returns the amount in currency units, for ratios and display only.
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// Mul multiplies the amount by f, rounding half away from zero.
+func (a Amount) Mul(f float64) Amount {
+	return Amount(math.Round(float64(a) * f))
+}
+
+// Percent returns pct percent of the amount, rounded to the cent.
+func (a Amount) Percent(pct float64) Amount {
+	return a.Mul(pct / 100)
+}
+
+// Round rounds the amount to the decimals used by currency, e.g. whole yen.
+func (a Amount) Round(currency string) Amount {
+	exp, ok := exponents[currency]
+	if !ok || exp >= 2 {
+		return a
+	}
+	step := math.Pow10(2 - exp)
+	return Amount(math.Round(float64(a)/step) * step)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseAmount(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return a.parse(string(v))
+	case string:
+		return a.parse(v)
+	case int64:
+		*a = Amount(v * 100)
+		return nil
+	case float64:
+		*a = Amount(math.Round(v * 100))
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+}
+
+func (a *Amount) parse(s string) error {
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Money is an amount in a given currency.
+type Money struct {
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
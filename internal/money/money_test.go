@@ -0,0 +1,101 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{in: "12", want: 1200},
+		{in: "12.5", want: 1250},
+		{in: "12.50", want: 1250},
+		{in: "12.500", want: 1250},
+		{in: " 0.05 ", want: 5},
+		{in: "-0.05", want: -5},
+		{in: "0", want: 0},
+		{in: "12.345", err: ErrInvalidAmount},
+		{in: "", err: ErrInvalidAmount},
+		{in: ".5", err: ErrInvalidAmount},
+		{in: "1e3", err: ErrInvalidAmount},
+		{in: "+1", err: ErrInvalidAmount},
+		{in: "--1", err: ErrInvalidAmount},
+		{in: "abc", err: ErrInvalidAmount},
+		{in: "92233720368547758.08", err: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseAmount(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250, "12.50"},
+		{-5, "-0.05"},
+		{-1250, "-12.50"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestAmountRound(t *testing.T) {
+	tests := []struct {
+		in       Amount
+		currency string
+		want     Amount
+	}{
+		{1234, "USD", 1234},
+		{1249, "JPY", 1200},
+		{1250, "JPY", 1300},
+		{-1250, "JPY", -1300},
+		{99, "KRW", 100},
+		{1234, "IDR", 1200},
+		{1234, "XXX", 1234},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.Round(tt.currency); got != tt.want {
+			t.Errorf("Amount(%d).Round(%q) = %d, want %d", int64(tt.in), tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestAmountPercent(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		pct  float64
+		want Amount
+	}{
+		{10000, 10, 1000},
+		{999, 15, 150},
+		{1, 50, 1},
+		{-999, 15, -150},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.Percent(tt.pct); got != tt.want {
+			t.Errorf("Amount(%d).Percent(%v) = %d, want %d", int64(tt.in), tt.pct, got, tt.want)
+		}
+	}
+}
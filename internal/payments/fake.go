@@ -0,0 +1,181 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+// Test payment method tokens understood by Fake. An empty method is treated
+// as MethodOK.
+const (
+	MethodOK                = "pm_card_ok"
+	MethodDeclined          = "pm_card_declined"
+	MethodInsufficientFunds = "pm_card_insufficient_funds"
+	MethodCaptureFails      = "pm_card_capture_fails"
+	// MethodAsync leaves the authorization pending; it is settled by a
+	// webhook or, failing that, times out.
+	MethodAsync = "pm_card_async"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed
+// with the webhook secret.
+const FakeSignatureHeader = "Fake-Signature"
+
+// Fake is an in-memory gateway whose outcomes depend only on the payment
+// method token, and whose references derive from the charge reference, so
+// the same inputs always give the same results.
+type Fake struct {
+	secret []byte
+
+	mu    sync.Mutex
+	auths map[string]*fakeAuth
+}
+
+type fakeAuth struct {
+	method   string
+	amount   money.Money
+	captured money.Amount
+	refunded money.Amount
+	refunds  int
+	voided   bool
+}
+
+// NewFake returns a fake gateway that accepts webhooks signed with secret.
+// With an empty secret every webhook is rejected.
+func NewFake(secret string) *Fake {
+	return &Fake{secret: []byte(secret), auths: map[string]*fakeAuth{}}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Authorize(_ context.Context, charge Charge) (Result, error) {
+	if charge.Reference == "" {
+		return Result{}, fmt.Errorf("fake gateway: charge reference is required")
+	}
+
+	method := charge.Method
+	if method == "" {
+		method = MethodOK
+	}
+	ref := "fake_auth_" + charge.Reference
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.auths[ref]; !ok {
+		f.auths[ref] = &fakeAuth{method: method, amount: charge.Amount}
+	}
+
+	switch method {
+	case MethodOK, MethodCaptureFails:
+		return Result{Ref: ref, Status: StatusAuthorized}, nil
+	case MethodAsync:
+		return Result{Ref: ref, Status: StatusPending}, nil
+	case MethodDeclined:
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "card_declined"}, nil
+	case MethodInsufficientFunds:
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "insufficient_funds"}, nil
+	default:
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "invalid_payment_method"}, nil
+	}
+}
+
+func (f *Fake) Capture(_ context.Context, ref string, amount money.Money) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.auths[ref]
+	if !ok {
+		return Result{}, ErrUnknownPayment
+	}
+
+	switch {
+	case auth.voided:
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "authorization_voided"}, nil
+	case auth.method == MethodCaptureFails:
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "capture_declined"}, nil
+	case amount.Currency != auth.amount.Currency || amount.Amount > auth.amount.Amount:
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "amount_exceeds_authorization"}, nil
+	}
+
+	auth.captured = amount.Amount
+	return Result{Ref: ref, Status: StatusCaptured}, nil
+}
+
+func (f *Fake) Refund(_ context.Context, ref string, amount money.Money) (Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.auths[ref]
+	if !ok {
+		return Refund{}, ErrUnknownPayment
+	}
+	if amount.Amount <= 0 || amount.Amount > auth.captured-auth.refunded {
+		return Refund{}, fmt.Errorf("fake gateway: refund of %s exceeds the captured amount", amount.Amount)
+	}
+
+	auth.refunds++
+	auth.refunded += amount.Amount
+	return Refund{Ref: fmt.Sprintf("fake_refund_%s_%d", ref, auth.refunds), Amount: amount}, nil
+}
+
+func (f *Fake) Void(_ context.Context, ref string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth, ok := f.auths[ref]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if auth.captured > 0 {
+		return fmt.Errorf("fake gateway: cannot void a captured payment")
+	}
+
+	auth.voided = true
+	return nil
+}
+
+// fakeEvent is the JSON body of a Fake webhook.
+type fakeEvent struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	Ref           string `json:"payment_ref"`
+	FailureReason string `json:"failure_reason"`
+}
+
+func (f *Fake) ParseWebhook(body []byte, header http.Header) (Event, error) {
+	if len(f.secret) == 0 {
+		return Event{}, ErrInvalidSignature
+	}
+
+	got, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(got, f.sign(body)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var e fakeEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return Event{}, fmt.Errorf("invalid webhook body: %w", err)
+	}
+	if e.ID == "" || e.Ref == "" {
+		return Event{}, fmt.Errorf("invalid webhook body: id and payment_ref are required")
+	}
+
+	return Event{ID: e.ID, Type: e.Type, Ref: e.Ref, FailureReason: e.FailureReason}, nil
+}
+
+func (f *Fake) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
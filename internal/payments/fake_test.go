@@ -0,0 +1,234 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+func TestFakeAuthorize(t *testing.T) {
+	tests := []struct {
+		method string
+		status string
+		reason string
+	}{
+		{"", StatusAuthorized, ""},
+		{MethodOK, StatusAuthorized, ""},
+		{MethodCaptureFails, StatusAuthorized, ""},
+		{MethodAsync, StatusPending, ""},
+		{MethodDeclined, StatusFailed, "card_declined"},
+		{MethodInsufficientFunds, StatusFailed, "insufficient_funds"},
+		{"pm_unknown", StatusFailed, "invalid_payment_method"},
+	}
+
+	for _, tt := range tests {
+		f := NewFake("secret")
+		res, err := f.Authorize(context.Background(), Charge{
+			Reference: "pay_1",
+			Amount:    money.Money{Amount: 10000, Currency: "USD"},
+			Method:    tt.method,
+		})
+		if err != nil {
+			t.Fatalf("Authorize(%q) error = %v", tt.method, err)
+		}
+		if res.Ref != "fake_auth_pay_1" || res.Status != tt.status || res.FailureReason != tt.reason {
+			t.Errorf("Authorize(%q) = %+v, want status %q, reason %q", tt.method, res, tt.status, tt.reason)
+		}
+	}
+}
+
+func TestFakeAuthorizeRequiresReference(t *testing.T) {
+	if _, err := NewFake("secret").Authorize(context.Background(), Charge{}); err == nil {
+		t.Error("Authorize() accepted a charge without a reference")
+	}
+}
+
+func TestFakeCaptureAndRefund(t *testing.T) {
+	ctx := context.Background()
+	usd := func(a money.Amount) money.Money { return money.Money{Amount: a, Currency: "USD"} }
+
+	authorize := func(t *testing.T, f *Fake, method string) string {
+		t.Helper()
+		res, err := f.Authorize(ctx, Charge{Reference: "pay_1", Amount: usd(10000), Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Ref
+	}
+
+	t.Run("capture", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			method string
+			amount money.Money
+			status string
+			reason string
+		}{
+			{"in full", MethodOK, usd(10000), StatusCaptured, ""},
+			{"partially", MethodOK, usd(4000), StatusCaptured, ""},
+			{"over the authorization", MethodOK, usd(10001), StatusFailed, "amount_exceeds_authorization"},
+			{"in another currency", MethodOK, money.Money{Amount: 10000, Currency: "EUR"}, StatusFailed, "amount_exceeds_authorization"},
+			{"declined", MethodCaptureFails, usd(10000), StatusFailed, "capture_declined"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				f := NewFake("secret")
+				ref := authorize(t, f, tt.method)
+				res, err := f.Capture(ctx, ref, tt.amount)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Status != tt.status || res.FailureReason != tt.reason {
+					t.Errorf("Capture() = %+v, want status %q, reason %q", res, tt.status, tt.reason)
+				}
+			})
+		}
+	})
+
+	t.Run("unknown payment", func(t *testing.T) {
+		f := NewFake("secret")
+		if _, err := f.Capture(ctx, "fake_auth_missing", usd(100)); !errors.Is(err, ErrUnknownPayment) {
+			t.Errorf("Capture() error = %v, want %v", err, ErrUnknownPayment)
+		}
+		if _, err := f.Refund(ctx, "fake_auth_missing", usd(100)); !errors.Is(err, ErrUnknownPayment) {
+			t.Errorf("Refund() error = %v, want %v", err, ErrUnknownPayment)
+		}
+	})
+
+	t.Run("refunds up to the captured amount", func(t *testing.T) {
+		f := NewFake("secret")
+		ref := authorize(t, f, MethodOK)
+		if _, err := f.Capture(ctx, ref, usd(8000)); err != nil {
+			t.Fatal(err)
+		}
+
+		steps := []struct {
+			amount money.Amount
+			ref    string
+			err    bool
+		}{
+			{amount: 3000, ref: "fake_refund_fake_auth_pay_1_1"},
+			{amount: 0, err: true},
+			{amount: 5001, err: true},
+			{amount: 5000, ref: "fake_refund_fake_auth_pay_1_2"},
+			{amount: 1, err: true},
+		}
+
+		for _, s := range steps {
+			refund, err := f.Refund(ctx, ref, usd(s.amount))
+			if (err != nil) != s.err {
+				t.Fatalf("Refund(%s) error = %v, want error %v", s.amount, err, s.err)
+			}
+			if err == nil && refund.Ref != s.ref {
+				t.Errorf("Refund(%s) ref = %q, want %q", s.amount, refund.Ref, s.ref)
+			}
+		}
+	})
+}
+
+func TestFakeVoid(t *testing.T) {
+	ctx := context.Background()
+	usd := money.Money{Amount: 10000, Currency: "USD"}
+
+	f := NewFake("secret")
+	if err := f.Void(ctx, "fake_auth_missing"); !errors.Is(err, ErrUnknownPayment) {
+		t.Errorf("Void() of an unknown payment error = %v, want %v", err, ErrUnknownPayment)
+	}
+
+	for _, ref := range []string{"pay_1", "pay_2"} {
+		if _, err := f.Authorize(ctx, Charge{Reference: ref, Amount: usd}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := f.Void(ctx, "fake_auth_pay_1"); err != nil {
+			t.Fatalf("Void() #%d error = %v", i+1, err)
+		}
+	}
+	res, err := f.Capture(ctx, "fake_auth_pay_1", usd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != StatusFailed || res.FailureReason != "authorization_voided" {
+		t.Errorf("Capture() after Void() = %+v, want failed with authorization_voided", res)
+	}
+
+	if _, err := f.Capture(ctx, "fake_auth_pay_2", usd); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Void(ctx, "fake_auth_pay_2"); err == nil {
+		t.Error("Void() of a captured payment succeeded")
+	}
+}
+
+func TestFakeParseWebhook(t *testing.T) {
+	sign := func(secret, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	valid := `{"id":"evt_1","type":"payment.captured","payment_ref":"fake_auth_pay_1"}`
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		want      Event
+		err       error
+	}{
+		{
+			name:      "signed event",
+			secret:    "secret",
+			body:      valid,
+			signature: sign("secret", valid),
+			want:      Event{ID: "evt_1", Type: EventCaptured, Ref: "fake_auth_pay_1"},
+		},
+		{
+			name:      "failure reason",
+			secret:    "secret",
+			body:      `{"id":"evt_2","type":"payment.failed","payment_ref":"fake_auth_pay_1","failure_reason":"card_declined"}`,
+			signature: sign("secret", `{"id":"evt_2","type":"payment.failed","payment_ref":"fake_auth_pay_1","failure_reason":"card_declined"}`),
+			want:      Event{ID: "evt_2", Type: EventFailed, Ref: "fake_auth_pay_1", FailureReason: "card_declined"},
+		},
+		{name: "wrong secret", secret: "secret", body: valid, signature: sign("other", valid), err: ErrInvalidSignature},
+		{name: "missing signature", secret: "secret", body: valid, err: ErrInvalidSignature},
+		{name: "signature not hex", secret: "secret", body: valid, signature: "zz", err: ErrInvalidSignature},
+		{name: "no secret configured", body: valid, signature: sign("", valid), err: ErrInvalidSignature},
+		{name: "invalid JSON", secret: "secret", body: "{", signature: sign("secret", "{")},
+		{name: "missing payment_ref", secret: "secret", body: `{"id":"evt_3"}`, signature: sign("secret", `{"id":"evt_3"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.signature != "" {
+				header.Set(FakeSignatureHeader, tt.signature)
+			}
+
+			got, err := NewFake(tt.secret).ParseWebhook([]byte(tt.body), header)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("ParseWebhook() error = %v, want %v", err, tt.err)
+				}
+			case tt.want == Event{}:
+				if err == nil {
+					t.Errorf("ParseWebhook() = %+v, want an error", got)
+				}
+			case err != nil:
+				t.Errorf("ParseWebhook() error = %v", err)
+			case got != tt.want:
+				t.Errorf("ParseWebhook() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Package payments charges guests through a payment gateway. Providers
+// authorize a charge, capture it, refund it and report changes made on the
+// gateway's side through webhooks; the built-in Fake stands in for a real
+// gateway in development and tests.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+var (
+	// ErrInvalidSignature is returned for webhooks that were not sent by the
+	// gateway.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrUnknownPayment is returned when the gateway has no authorization
+	// with the given reference.
+	ErrUnknownPayment = errors.New("unknown payment")
+)
+
+// Outcomes of a gateway call.
+const (
+	// StatusPending means the gateway has not decided yet and will send a
+	// webhook when it does.
+	StatusPending    = "pending"
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusFailed     = "failed"
+)
+
+// Webhook event types.
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventFailed     = "payment.failed"
+	EventRefunded   = "payment.refunded"
+)
+
+// Charge asks the gateway to hold Amount on the guest's payment method.
+type Charge struct {
+	// Reference is our id for the payment. Gateways use it as an
+	// idempotency key, so retrying an authorization never charges twice.
+	Reference string
+	Amount    money.Money
+	// Method is the payment method token the client got from the gateway.
+	Method string
+}
+
+// Result is the gateway's answer to an authorization or capture. Declines
+// are results with StatusFailed, not errors; errors mean the gateway could
+// not be reached or did not understand the request.
+type Result struct {
+	Ref           string // the gateway's authorization id
+	Status        string
+	FailureReason string
+}
+
+// Refund is a refund the gateway has accepted.
+type Refund struct {
+	Ref    string
+	Amount money.Money
+}
+
+// Event is a webhook delivery from the gateway.
+type Event struct {
+	ID            string // unique per event; retried deliveries repeat it
+	Type          string
+	Ref           string // the authorization the event is about
+	FailureReason string
+}
+
+// Provider is a payment gateway.
+type Provider interface {
+	// Name identifies the provider in stored payments.
+	Name() string
+	Authorize(ctx context.Context, charge Charge) (Result, error)
+	// Capture collects amount of an authorization.
+	Capture(ctx context.Context, ref string, amount money.Money) (Result, error)
+	// Refund returns amount of a captured payment to the guest.
+	Refund(ctx context.Context, ref string, amount money.Money) (Refund, error)
+	// Void releases an authorization that will not be captured, so the
+	// funds stop being held on the guest's payment method. Voiding an
+	// authorization twice is not an error.
+	Void(ctx context.Context, ref string) error
+	// ParseWebhook checks that a webhook body was sent by the gateway and
+	// decodes it.
+	ParseWebhook(body []byte, header http.Header) (Event, error)
+}
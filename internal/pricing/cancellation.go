@@ -0,0 +1,83 @@
+package pricing
+
+import (
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+// HostPenaltyPercent is the share of a booking, net of tax, that a host is
+// charged for cancelling it.
+const HostPenaltyPercent = 10
+
+// refundTier refunds percent of the nights when the guest cancels at least
+// notice before check-in.
+type refundTier struct {
+	notice  time.Duration
+	percent float64
+}
+
+// cancellationPolicies lists each policy's tiers, longest notice first.
+// Cancelling later than the last tier refunds nothing.
+var cancellationPolicies = map[string][]refundTier{
+	models.PolicyFlexible: {{24 * time.Hour, 100}, {0, 50}},
+	models.PolicyModerate: {{5 * 24 * time.Hour, 100}, {24 * time.Hour, 50}},
+	models.PolicyStrict:   {{14 * 24 * time.Hour, 100}, {7 * 24 * time.Hour, 50}},
+}
+
+// CancellationRefund works out what a guest gets back for cancelling at now
+// a booking that cost total, of which cleaningFee and tax, with the stay
+// starting at checkIn. The cleaning fee comes back in full before check-in
+// unless the booking is non-refundable, and tax in proportion to what is
+// refunded of the rest.
+func CancellationRefund(policy string, total, cleaningFee, tax money.Amount, currency string, checkIn, now time.Time) models.RefundBreakdown {
+	until := checkIn.Sub(now)
+	r := models.RefundBreakdown{
+		Policy:             policy,
+		CancelledBy:        models.CancelledByGuest,
+		HoursBeforeCheckIn: until.Hours(),
+		BookingTotal:       total,
+		Currency:           currency,
+	}
+
+	for _, tier := range cancellationPolicies[policy] {
+		if until > 0 && until >= tier.notice {
+			r.RefundPercent = tier.percent
+			break
+		}
+	}
+
+	nights := total - cleaningFee - tax
+	r.Nights = nights.Percent(r.RefundPercent).Round(currency)
+	if until > 0 && policy != models.PolicyNonRefundable {
+		r.CleaningFee = cleaningFee
+	}
+	r.Tax = share(tax, r.Nights+r.CleaningFee, nights+cleaningFee).Round(currency)
+	r.Total = r.Nights + r.CleaningFee + r.Tax
+	return r
+}
+
+// HostCancellationRefund refunds a booking in full, as when the host
+// cancels it.
+func HostCancellationRefund(policy string, total, cleaningFee, tax money.Amount, currency string, checkIn, now time.Time) models.RefundBreakdown {
+	return models.RefundBreakdown{
+		Policy:             policy,
+		CancelledBy:        models.CancelledByHost,
+		HoursBeforeCheckIn: checkIn.Sub(now).Hours(),
+		RefundPercent:      100,
+		BookingTotal:       total,
+		Nights:             total - cleaningFee - tax,
+		CleaningFee:        cleaningFee,
+		Tax:                tax,
+		Total:              total,
+		Currency:           currency,
+	}
+}
+
+// PaymentRefund is the part of a payment of amount in currency that a
+// refund gives back; payments may be in the currency the guest was shown
+// rather than the booking's.
+func PaymentRefund(amount money.Amount, currency string, refund models.RefundBreakdown) money.Amount {
+	return share(amount, refund.Total, refund.BookingTotal).Round(currency)
+}
@@ -0,0 +1,103 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+func TestCancellationRefund(t *testing.T) {
+	checkIn := time.Date(2026, 6, 1, 15, 0, 0, 0, time.UTC)
+
+	// Unless set, a booking of 110.00 USD: 80.00 of nights, 20.00 of
+	// cleaning and 10.00 of tax.
+	tests := []struct {
+		name     string
+		policy   string
+		before   time.Duration
+		currency string
+		total    money.Amount
+		cleaning money.Amount
+		tax      money.Amount
+		percent  float64
+		want     models.RefundBreakdown
+	}{
+		{
+			name: "flexible with a day's notice", policy: models.PolicyFlexible, before: 48 * time.Hour, percent: 100,
+			want: models.RefundBreakdown{Nights: 8000, CleaningFee: 2000, Tax: 1000, Total: 11000},
+		},
+		{
+			name: "flexible on the day", policy: models.PolicyFlexible, before: 12 * time.Hour, percent: 50,
+			want: models.RefundBreakdown{Nights: 4000, CleaningFee: 2000, Tax: 600, Total: 6600},
+		},
+		{
+			name: "flexible after check-in", policy: models.PolicyFlexible, before: -time.Hour, percent: 0,
+			want: models.RefundBreakdown{},
+		},
+		{
+			name: "moderate within five days", policy: models.PolicyModerate, before: 3 * 24 * time.Hour, percent: 50,
+			want: models.RefundBreakdown{Nights: 4000, CleaningFee: 2000, Tax: 600, Total: 6600},
+		},
+		{
+			name: "moderate on the day keeps the cleaning fee", policy: models.PolicyModerate, before: 12 * time.Hour, percent: 0,
+			want: models.RefundBreakdown{CleaningFee: 2000, Tax: 200, Total: 2200},
+		},
+		{
+			name: "strict at exactly fourteen days", policy: models.PolicyStrict, before: 14 * 24 * time.Hour, percent: 100,
+			want: models.RefundBreakdown{Nights: 8000, CleaningFee: 2000, Tax: 1000, Total: 11000},
+		},
+		{
+			name: "strict within fourteen days", policy: models.PolicyStrict, before: 10 * 24 * time.Hour, percent: 50,
+			want: models.RefundBreakdown{Nights: 4000, CleaningFee: 2000, Tax: 600, Total: 6600},
+		},
+		{
+			name: "non-refundable", policy: models.PolicyNonRefundable, before: 30 * 24 * time.Hour, percent: 0,
+			want: models.RefundBreakdown{},
+		},
+		{
+			name: "rounded to whole yen", policy: models.PolicyFlexible, before: 12 * time.Hour, percent: 50,
+			currency: "JPY", total: 1000100, cleaning: 200000, tax: 100000,
+			want: models.RefundBreakdown{Nights: 350100, CleaningFee: 200000, Tax: 61100, Total: 611200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.currency == "" {
+				tt.currency, tt.total, tt.cleaning, tt.tax = "USD", 11000, 2000, 1000
+			}
+
+			r := CancellationRefund(tt.policy, tt.total, tt.cleaning, tt.tax, tt.currency, checkIn, checkIn.Add(-tt.before))
+			if r.RefundPercent != tt.percent {
+				t.Errorf("RefundPercent = %v, want %v", r.RefundPercent, tt.percent)
+			}
+			if r.Nights != tt.want.Nights || r.CleaningFee != tt.want.CleaningFee || r.Tax != tt.want.Tax || r.Total != tt.want.Total {
+				t.Errorf("refund = nights %s, cleaning %s, tax %s, total %s; want %s, %s, %s, %s",
+					r.Nights, r.CleaningFee, r.Tax, r.Total,
+					tt.want.Nights, tt.want.CleaningFee, tt.want.Tax, tt.want.Total)
+			}
+		})
+	}
+}
+
+func TestPaymentRefund(t *testing.T) {
+	refund := models.RefundBreakdown{BookingTotal: 11000, Total: 6600}
+
+	tests := []struct {
+		amount   money.Amount
+		currency string
+		want     money.Amount
+	}{
+		{11000, "USD", 6600},
+		{1650000, "JPY", 990000},
+		{1650050, "JPY", 990000},
+	}
+
+	for _, tt := range tests {
+		if got := PaymentRefund(tt.amount, tt.currency, refund); got != tt.want {
+			t.Errorf("PaymentRefund(%s %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
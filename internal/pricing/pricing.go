@@ -0,0 +1,160 @@
+// Package pricing turns a property's pricing rules into nightly rates and
+// itemised quotes, in the property's currency.
+package pricing
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	WeeklyNights  = 7
+	MonthlyNights = 28
+)
+
+// Rate returns the nightly price for day and the name of the rate rule that
+// set it, or "" when the base price applies.
+func Rate(rules models.PricingRules, day time.Time) (money.Amount, string) {
+	date := day.Format(dateLayout)
+	weekday := int(day.Weekday())
+
+	best, bestScore := -1, 0
+	for i, r := range rules.Rates {
+		score := 0
+		if r.StartDate != "" {
+			if date < r.StartDate || date >= r.EndDate {
+				continue
+			}
+			score += 2
+		}
+		if len(r.Weekdays) > 0 {
+			if !slices.Contains(r.Weekdays, weekday) {
+				continue
+			}
+			score++
+		}
+		// >= so later rules win ties.
+		if score > 0 && score >= bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return rules.BasePrice, ""
+	}
+	return rules.Rates[best].PricePerNight, rules.Rates[best].Name
+}
+
+// Quote prices a stay from checkIn to checkOut for guests, before taxes.
+// Discounts and fees are rounded to the currency's decimals.
+func Quote(rules models.PricingRules, checkIn, checkOut time.Time, guests int) models.PriceQuote {
+	quote := models.PriceQuote{
+		Currency:     rules.Currency,
+		CheckIn:      checkIn.Format(dateLayout),
+		CheckOut:     checkOut.Format(dateLayout),
+		Guests:       guests,
+		NightlyRates: []models.NightlyRate{},
+		Taxes:        []models.TaxLine{},
+	}
+
+	for day := checkIn; day.Before(checkOut); day = day.AddDate(0, 0, 1) {
+		price, rule := Rate(rules, day)
+		quote.Subtotal += price
+		quote.NightlyRates = append(quote.NightlyRates, models.NightlyRate{
+			Date:  day.Format(dateLayout),
+			Price: price,
+			Rule:  rule,
+		})
+	}
+	quote.Nights = len(quote.NightlyRates)
+
+	switch {
+	case quote.Nights >= MonthlyNights && rules.MonthlyDiscountPercent > 0:
+		quote.DiscountType = "monthly"
+		quote.Discount = quote.Subtotal.Percent(rules.MonthlyDiscountPercent).Round(rules.Currency)
+	case quote.Nights >= WeeklyNights && rules.WeeklyDiscountPercent > 0:
+		quote.DiscountType = "weekly"
+		quote.Discount = quote.Subtotal.Percent(rules.WeeklyDiscountPercent).Round(rules.Currency)
+	}
+
+	if guests > rules.GuestsIncluded {
+		extraGuests := money.Amount(guests - rules.GuestsIncluded)
+		quote.ExtraGuestFee = (rules.ExtraGuestFee * extraGuests * money.Amount(quote.Nights)).Round(rules.Currency)
+	}
+	quote.CleaningFee = rules.CleaningFee
+	quote.Total = total(quote)
+
+	return quote
+}
+
+// total sums the lines of a quote.
+func total(q models.PriceQuote) money.Amount {
+	t := q.Subtotal - q.Discount + q.ExtraGuestFee + q.CleaningFee + q.Tax
+	if q.Coupon != nil {
+		t -= q.Coupon.Discount
+	}
+	return t
+}
+
+// ApplyCoupon takes coupon off the nightly subtotal, after any
+// length-of-stay discount. A fixed amount must already be in the quote's
+// currency and is capped at what is left of the subtotal. Apply coupons
+// before taxes.
+func ApplyCoupon(quote models.PriceQuote, coupon models.Coupon) models.PriceQuote {
+	nightly := quote.Subtotal - quote.Discount
+
+	var discount money.Amount
+	switch coupon.Kind {
+	case models.CouponPercentage:
+		discount = nightly.Percent(*coupon.Percent).Round(quote.Currency)
+	case models.CouponFixed:
+		discount = min(coupon.Amount.Amount, nightly)
+	}
+
+	quote.Coupon = &models.AppliedCoupon{ID: coupon.ID, Code: coupon.Code, Discount: discount}
+	quote.Total = total(quote)
+	return quote
+}
+
+// Convert returns the quote in rate.To. Each line is converted on its own and
+// the total is re-summed, so the lines still add up.
+func Convert(quote models.PriceQuote, rate fx.Rate) models.PriceQuote {
+	if rate.From == rate.To {
+		return quote
+	}
+
+	converted := quote
+	converted.Currency = rate.To
+	converted.ExchangeRate = &rate
+	converted.NightlyRates = make([]models.NightlyRate, len(quote.NightlyRates))
+	converted.Subtotal = 0
+	for i, night := range quote.NightlyRates {
+		night.Price = rate.Convert(night.Price)
+		converted.Subtotal += night.Price
+		converted.NightlyRates[i] = night
+	}
+	converted.Discount = rate.Convert(quote.Discount)
+	converted.ExtraGuestFee = rate.Convert(quote.ExtraGuestFee)
+	converted.CleaningFee = rate.Convert(quote.CleaningFee)
+	if quote.Coupon != nil {
+		coupon := *quote.Coupon
+		coupon.Discount = rate.Convert(coupon.Discount)
+		converted.Coupon = &coupon
+	}
+	converted.Taxes = make([]models.TaxLine, len(quote.Taxes))
+	converted.Tax = 0
+	for i, tax := range quote.Taxes {
+		tax.Amount = rate.Convert(tax.Amount)
+		converted.Tax += tax.Amount
+		converted.Taxes[i] = tax
+	}
+	converted.Total = total(converted)
+
+	return converted
+}
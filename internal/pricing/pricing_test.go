@@ -0,0 +1,198 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+func day(t *testing.T, date string) time.Time {
+	t.Helper()
+	d, err := time.Parse(dateLayout, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestRate(t *testing.T) {
+	rules := models.PricingRules{
+		BasePrice: 10000,
+		Rates: []models.RateRule{
+			{Name: "weekend", Weekdays: []int{5, 6}, PricePerNight: 15000},
+			{Name: "summer", StartDate: "2026-07-01", EndDate: "2026-09-01", PricePerNight: 20000},
+			{Name: "summer saturday", StartDate: "2026-07-01", EndDate: "2026-09-01", Weekdays: []int{6}, PricePerNight: 25000},
+			{Name: "friday", Weekdays: []int{5}, PricePerNight: 12000},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		date  string
+		price money.Amount
+		rule  string
+	}{
+		{"base price", "2026-06-01", 10000, ""},
+		{"weekday rule", "2026-06-06", 15000, "weekend"},
+		{"later rule wins a tie", "2026-06-05", 12000, "friday"},
+		{"date range", "2026-07-06", 20000, "summer"},
+		{"date range beats weekday", "2026-07-03", 20000, "summer"},
+		{"date range and weekday beat date range", "2026-07-04", 25000, "summer saturday"},
+		{"end date is exclusive", "2026-09-01", 10000, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, rule := Rate(rules, day(t, tt.date))
+			if price != tt.price || rule != tt.rule {
+				t.Errorf("Rate(%s) = %s, %q, want %s, %q", tt.date, price, rule, tt.price, tt.rule)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name          string
+		rules         models.PricingRules
+		checkIn       string
+		checkOut      string
+		guests        int
+		subtotal      money.Amount
+		discountType  string
+		discount      money.Amount
+		extraGuestFee money.Amount
+		total         money.Amount
+	}{
+		{
+			name:          "short stay with fees",
+			rules:         models.PricingRules{Currency: "USD", BasePrice: 10000, CleaningFee: 5000, ExtraGuestFee: 1000, GuestsIncluded: 2, WeeklyDiscountPercent: 10},
+			checkIn:       "2026-06-01",
+			checkOut:      "2026-06-04",
+			guests:        3,
+			subtotal:      30000,
+			extraGuestFee: 3000,
+			total:         38000,
+		},
+		{
+			name:         "weekly discount",
+			rules:        models.PricingRules{Currency: "USD", BasePrice: 10000, GuestsIncluded: 2, WeeklyDiscountPercent: 10},
+			checkIn:      "2026-06-01",
+			checkOut:     "2026-06-08",
+			guests:       2,
+			subtotal:     70000,
+			discountType: "weekly",
+			discount:     7000,
+			total:        63000,
+		},
+		{
+			name:         "monthly discount replaces weekly",
+			rules:        models.PricingRules{Currency: "USD", BasePrice: 10000, GuestsIncluded: 2, WeeklyDiscountPercent: 10, MonthlyDiscountPercent: 20},
+			checkIn:      "2026-06-01",
+			checkOut:     "2026-06-29",
+			guests:       1,
+			subtotal:     280000,
+			discountType: "monthly",
+			discount:     56000,
+			total:        224000,
+		},
+		{
+			name:         "weekly discount without a monthly one",
+			rules:        models.PricingRules{Currency: "USD", BasePrice: 10000, GuestsIncluded: 2, WeeklyDiscountPercent: 10},
+			checkIn:      "2026-06-01",
+			checkOut:     "2026-06-29",
+			guests:       1,
+			subtotal:     280000,
+			discountType: "weekly",
+			discount:     28000,
+			total:        252000,
+		},
+		{
+			name:         "discount rounded to whole yen",
+			rules:        models.PricingRules{Currency: "JPY", BasePrice: 1234500, GuestsIncluded: 2, WeeklyDiscountPercent: 15},
+			checkIn:      "2026-06-01",
+			checkOut:     "2026-06-08",
+			guests:       2,
+			subtotal:     8641500,
+			discountType: "weekly",
+			discount:     1296200,
+			total:        7345300,
+		},
+		{
+			name:          "extra-guest fee rounded to whole yen",
+			rules:         models.PricingRules{Currency: "JPY", BasePrice: 1000000, ExtraGuestFee: 50025, GuestsIncluded: 1},
+			checkIn:       "2026-06-01",
+			checkOut:      "2026-06-02",
+			guests:        2,
+			subtotal:      1000000,
+			extraGuestFee: 50000,
+			total:         1050000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Quote(tt.rules, day(t, tt.checkIn), day(t, tt.checkOut), tt.guests)
+			if q.Subtotal != tt.subtotal {
+				t.Errorf("Subtotal = %s, want %s", q.Subtotal, tt.subtotal)
+			}
+			if q.DiscountType != tt.discountType || q.Discount != tt.discount {
+				t.Errorf("discount = %q %s, want %q %s", q.DiscountType, q.Discount, tt.discountType, tt.discount)
+			}
+			if q.ExtraGuestFee != tt.extraGuestFee {
+				t.Errorf("ExtraGuestFee = %s, want %s", q.ExtraGuestFee, tt.extraGuestFee)
+			}
+			if q.Total != tt.total {
+				t.Errorf("Total = %s, want %s", q.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestApplyCoupon(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+
+	tests := []struct {
+		name     string
+		quote    models.PriceQuote
+		coupon   models.Coupon
+		discount money.Amount
+		total    money.Amount
+	}{
+		{
+			name:     "percentage after stay discount",
+			quote:    models.PriceQuote{Currency: "USD", Subtotal: 70000, Discount: 7000, CleaningFee: 5000},
+			coupon:   models.Coupon{Kind: models.CouponPercentage, Percent: percent(10)},
+			discount: 6300,
+			total:    61700,
+		},
+		{
+			name:     "percentage rounded to whole yen",
+			quote:    models.PriceQuote{Currency: "JPY", Subtotal: 8641500, Discount: 1296200},
+			coupon:   models.Coupon{Kind: models.CouponPercentage, Percent: percent(10)},
+			discount: 734500,
+			total:    6610800,
+		},
+		{
+			name:     "fixed amount capped at the nightly subtotal",
+			quote:    models.PriceQuote{Currency: "USD", Subtotal: 20000, CleaningFee: 5000},
+			coupon:   models.Coupon{Kind: models.CouponFixed, Amount: &money.Money{Amount: 50000, Currency: "USD"}},
+			discount: 20000,
+			total:    5000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := ApplyCoupon(tt.quote, tt.coupon)
+			if q.Coupon == nil || q.Coupon.Discount != tt.discount {
+				t.Fatalf("Coupon = %+v, want discount %s", q.Coupon, tt.discount)
+			}
+			if q.Total != tt.total {
+				t.Errorf("Total = %s, want %s", q.Total, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package pricing
+
+import (
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+// ApplyTaxes adds the taxes in rules to quote and includes them in its
+// total. Flat per-night amounts must already be in the quote's currency.
+//
+// A rule is charged only for the nights it is in effect. A percentage rule
+// taxes those nights' rates less their share of the discount and coupon,
+// their share of the extra-guest fee, and the cleaning fee if it is in
+// effect on the check-in night.
+func ApplyTaxes(quote models.PriceQuote, rules []models.TaxRule) models.PriceQuote {
+	quote.Taxes = []models.TaxLine{}
+	quote.Tax = 0
+
+	discount := quote.Discount
+	if quote.Coupon != nil {
+		discount += quote.Coupon.Discount
+	}
+
+	for _, rule := range rules {
+		var (
+			nights  int
+			rates   money.Amount
+			checkIn bool
+		)
+		for i, night := range quote.NightlyRates {
+			if night.Date < rule.EffectiveFrom || (rule.EffectiveTo != "" && night.Date >= rule.EffectiveTo) {
+				continue
+			}
+			nights++
+			rates += night.Price
+			checkIn = checkIn || i == 0
+		}
+		if nights == 0 {
+			continue
+		}
+
+		line := models.TaxLine{
+			RuleID:       rule.ID,
+			Name:         rule.Name,
+			Jurisdiction: rule.Jurisdiction,
+			Kind:         rule.Kind,
+			Percent:      rule.Percent,
+			Nights:       nights,
+		}
+
+		switch rule.Kind {
+		case models.TaxPercentage:
+			base := rates - share(discount, rates, quote.Subtotal) +
+				share(quote.ExtraGuestFee, money.Amount(nights), money.Amount(quote.Nights))
+			if checkIn {
+				base += quote.CleaningFee
+			}
+			line.Amount = base.Percent(*rule.Percent).Round(quote.Currency)
+		case models.TaxPerNight:
+			line.Amount = rule.Amount.Amount * money.Amount(nights)
+		}
+
+		quote.Taxes = append(quote.Taxes, line)
+		quote.Tax += line.Amount
+	}
+
+	quote.Total = total(quote)
+	return quote
+}
+
+// share returns part/whole of amount, rounded to the cent.
+func share(amount, part, whole money.Amount) money.Amount {
+	if whole == 0 || part == whole {
+		return amount
+	}
+	return amount.Mul(float64(part) / float64(whole))
+}
@@ -0,0 +1,90 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+)
+
+func TestApplyTaxes(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+
+	tests := []struct {
+		name    string
+		rules   models.PricingRules
+		checkIn string
+		nights  int
+		guests  int
+		taxes   []models.TaxRule
+		amounts []money.Amount
+		total   money.Amount
+	}{
+		{
+			name:    "percentage and per-night rules over part of the stay",
+			rules:   models.PricingRules{Currency: "USD", BasePrice: 10000, CleaningFee: 5000, ExtraGuestFee: 1000, GuestsIncluded: 2},
+			checkIn: "2026-06-01",
+			nights:  3,
+			guests:  3,
+			taxes: []models.TaxRule{
+				{Name: "sales", Kind: models.TaxPercentage, Percent: percent(10), EffectiveFrom: "2026-01-01"},
+				{Name: "city", Kind: models.TaxPerNight, Amount: &money.Money{Amount: 250, Currency: "USD"}, EffectiveFrom: "2026-06-02"},
+				{Name: "expiring", Kind: models.TaxPercentage, Percent: percent(5), EffectiveFrom: "2026-01-01", EffectiveTo: "2026-06-02"},
+				{Name: "future", Kind: models.TaxPercentage, Percent: percent(5), EffectiveFrom: "2027-01-01"},
+			},
+			// 10% of 300 + 30 + 50; 2.50 for 2 nights; 5% of 100 + 10 + 50.
+			amounts: []money.Amount{3800, 500, 800},
+			total:   43100,
+		},
+		{
+			name:    "discount shared over the taxed nights",
+			rules:   models.PricingRules{Currency: "USD", BasePrice: 10000, CleaningFee: 5000, GuestsIncluded: 2, WeeklyDiscountPercent: 10},
+			checkIn: "2026-06-01",
+			nights:  7,
+			guests:  2,
+			taxes: []models.TaxRule{
+				{Name: "sales", Kind: models.TaxPercentage, Percent: percent(10), EffectiveFrom: "2026-06-04"},
+			},
+			// 10% of 400 less 4/7 of the 70 discount; no cleaning fee.
+			amounts: []money.Amount{3600},
+			total:   71600,
+		},
+		{
+			name:    "rounded to whole yen",
+			rules:   models.PricingRules{Currency: "JPY", BasePrice: 100500, GuestsIncluded: 2},
+			checkIn: "2026-06-01",
+			nights:  1,
+			guests:  2,
+			taxes: []models.TaxRule{
+				{Name: "consumption", Kind: models.TaxPercentage, Percent: percent(10), EffectiveFrom: "2026-01-01"},
+			},
+			amounts: []money.Amount{10100},
+			total:   110600,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkIn := day(t, tt.checkIn)
+			quote := Quote(tt.rules, checkIn, checkIn.AddDate(0, 0, tt.nights), tt.guests)
+			q := ApplyTaxes(quote, tt.taxes)
+
+			if len(q.Taxes) != len(tt.amounts) {
+				t.Fatalf("got %d tax lines, want %d: %+v", len(q.Taxes), len(tt.amounts), q.Taxes)
+			}
+			var tax money.Amount
+			for i, line := range q.Taxes {
+				if line.Amount != tt.amounts[i] {
+					t.Errorf("%s = %s, want %s", line.Name, line.Amount, tt.amounts[i])
+				}
+				tax += tt.amounts[i]
+			}
+			if q.Tax != tax {
+				t.Errorf("Tax = %s, want %s", q.Tax, tax)
+			}
+			if q.Total != tt.total {
+				t.Errorf("Total = %s, want %s", q.Total, tt.total)
+			}
+		})
+	}
+}
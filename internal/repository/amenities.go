@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (repo *Repository) UpdateAmenity(id uuid.UUID, req models.UpdateAmenityRequest, actor models.Actor) (*models.Amenity, error) {
+	selectQuery := `
+		SELECT id, name, category, icon
+		FROM amenities
+		WHERE id = $1
+		FOR UPDATE;
+	`
+
+	updateQuery := `
+		UPDATE amenities
+		SET name = $2, category = $3, icon = $4
+		WHERE id = $1
+		RETURNING id, name, category, icon;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before models.Amenity
+	if err := tx.QueryRowContext(ctx, selectQuery, id).Scan(&before.AmenityID, &before.Name, &before.Category, &before.Icon); err != nil {
+		return nil, err
+	}
+
+	name, category, icon := before.Name, before.Category, before.Icon
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Category != nil {
+		category = *req.Category
+	}
+	if req.Icon != nil {
+		icon = req.Icon
+		if *req.Icon == "" {
+			icon = nil
+		}
+	}
+
+	if name == "" || category == "" {
+		return nil, errors.New("amenity name and category cannot be empty")
+	}
+
+	var after models.Amenity
+	err = tx.QueryRowContext(ctx, updateQuery, id, name, category, icon).Scan(&after.AmenityID, &after.Name, &after.Category, &after.Icon)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "amenity.update", "amenity", id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &after, nil
+}
+
+// DeleteAmenity removes an amenity from the catalog and detaches it from
+// every property.
+func (repo *Repository) DeleteAmenity(id uuid.UUID, actor models.Actor) error {
+	query := `
+		DELETE FROM amenities
+		WHERE id = $1
+		RETURNING id, name, category, icon;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var before models.Amenity
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&before.AmenityID, &before.Name, &before.Category, &before.Icon); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "amenity.delete", "amenity", id, before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *Repository) DetachPropertyAmenity(propertyID, amenityID uuid.UUID, actor models.Actor) error {
+	query := `
+		DELETE FROM property_amenities
+		WHERE property_id = $1 AND amenity_id = $2;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, propertyID, amenityID)
+	if err != nil {
+		return fmt.Errorf("failed to detach amenity: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	before := models.PostAmenity{PropertyID: propertyID, AmenityID: amenityID}
+	if err := recordAudit(ctx, tx, actor, "property_amenity.detach", "property", propertyID, before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReplacePropertyAmenities makes amenityIDs the property's complete amenity
+// set, attaching and detaching as needed.
+func (repo *Repository) ReplacePropertyAmenities(propertyID uuid.UUID, amenityIDs []uuid.UUID, actor models.Actor) error {
+	lockQuery := `
+		SELECT id FROM properties WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
+	`
+
+	currentQuery := `
+		SELECT amenity_id
+		FROM property_amenities
+		WHERE property_id = $1
+		ORDER BY amenity_id;
+	`
+
+	countQuery := `
+		SELECT COUNT(*) FROM amenities WHERE id = ANY($1::uuid[]);
+	`
+
+	deleteQuery := `
+		DELETE FROM property_amenities
+		WHERE property_id = $1 AND NOT (amenity_id = ANY($2::uuid[]));
+	`
+
+	insertQuery := `
+		INSERT INTO property_amenities (property_id, amenity_id)
+		SELECT $1, id FROM unnest($2::uuid[]) AS id
+		ON CONFLICT (property_id, amenity_id) DO NOTHING;
+	`
+
+	unique := make([]string, 0, len(amenityIDs))
+	seen := map[uuid.UUID]bool{}
+	for _, id := range amenityIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id.String())
+		}
+	}
+	sort.Strings(unique)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	if err := tx.QueryRowContext(ctx, lockQuery, propertyID).Scan(&locked); err != nil {
+		return err
+	}
+
+	var known int
+	if err := tx.QueryRowContext(ctx, countQuery, pq.StringArray(unique)).Scan(&known); err != nil {
+		return err
+	}
+	if known != len(unique) {
+		return ErrUnknownAmenity
+	}
+
+	rows, err := tx.QueryContext(ctx, currentQuery, propertyID)
+	if err != nil {
+		return err
+	}
+	var current []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, propertyID, pq.StringArray(unique)); err != nil {
+		return fmt.Errorf("failed to detach amenities: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, insertQuery, propertyID, pq.StringArray(unique)); err != nil {
+		return fmt.Errorf("failed to attach amenities: %w", err)
+	}
+
+	before := map[string]any{"amenity_ids": current}
+	after := map[string]any{"amenity_ids": unique}
+	if err := recordAudit(ctx, tx, actor, "property_amenity.replace", "property", propertyID, before, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
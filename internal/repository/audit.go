@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+)
+
+// recordAudit appends an entry to the audit log inside the caller's
+// transaction, so the entry is only kept if the write itself commits.
+func recordAudit(ctx context.Context, tx *sql.Tx, actor models.Actor, action, entityType string, entityID any, before, after any) error {
+	query := `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, before, after, diff, request_id, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit before state: %w", err)
+	}
+
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit after state: %w", err)
+	}
+
+	diff, err := auditDiff(beforeJSON, afterJSON)
+	if err != nil {
+		return fmt.Errorf("failed to diff audit states: %w", err)
+	}
+
+	actorID := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+
+	_, err = tx.ExecContext(ctx, query,
+		actorID,
+		action,
+		entityType,
+		fmt.Sprint(entityID),
+		beforeJSON,
+		afterJSON,
+		diff,
+		actor.RequestID,
+		actor.IP,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// auditJSON encodes an entity state as a JSONB parameter; a nil state is
+// stored as NULL.
+func auditJSON(v any) (sql.NullString, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return sql.NullString{}, nil
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(js), Valid: true}, nil
+}
+
+// auditDiff returns the top-level fields that differ between two states as
+// {"field": {"from": ..., "to": ...}}.
+func auditDiff(before, after sql.NullString) (sql.NullString, error) {
+	from := map[string]any{}
+	to := map[string]any{}
+
+	if before.Valid {
+		if err := json.Unmarshal([]byte(before.String), &from); err != nil {
+			return sql.NullString{}, err
+		}
+	}
+	if after.Valid {
+		if err := json.Unmarshal([]byte(after.String), &to); err != nil {
+			return sql.NullString{}, err
+		}
+	}
+
+	diff := map[string]any{}
+	for key, old := range from {
+		if updated, ok := to[key]; !ok || !reflect.DeepEqual(old, updated) {
+			diff[key] = map[string]any{"from": old, "to": to[key]}
+		}
+	}
+	for key, updated := range to {
+		if _, ok := from[key]; !ok {
+			diff[key] = map[string]any{"from": nil, "to": updated}
+		}
+	}
+
+	return auditJSON(diff)
+}
+
+func (repo *Repository) GetAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	conditions := []string{}
+	args := []any{}
+
+	addCondition := func(clause string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	if filter.EntityType != "" {
+		addCondition("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		addCondition("entity_id = $%d", filter.EntityID)
+	}
+	if filter.ActorID != uuid.Nil {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT id, actor_id, action, entity_type, entity_id, before, after, diff,
+			COALESCE(request_id, ''), COALESCE(ip, ''), created_at
+		FROM audit_log
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d;
+	`, where, len(args))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after, diff []byte
+
+		if err := rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&e.Action,
+			&e.EntityType,
+			&e.EntityID,
+			&before,
+			&after,
+			&diff,
+			&e.RequestID,
+			&e.IP,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		e.Before, e.After, e.Diff = before, after, diff
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
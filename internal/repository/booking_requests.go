@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/google/uuid"
+)
+
+// RequestBooking asks the host of a listing booked on request for a stay,
+// priced and checked like CreateBooking. The request keeps its nights until
+// the host answers or respondWithin passes; paymentMethod is charged if the
+// host accepts. It returns the booking's id and deadline, and the quote in
+// displayCurrency. Listings booked instantly fail with ErrInstantBook.
+func (repo *Repository) RequestBooking(req models.StayRequest, displayCurrency string, expectedTotal *money.Amount, rates fx.Provider, paymentMethod string, respondWithin time.Duration, actor models.Actor) (uuid.UUID, time.Time, models.PriceQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, time.Time{}, models.PriceQuote{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	terms := bookingTerms{respondWithin: respondWithin, paymentMethod: paymentMethod}
+	stay, err := bookStay(ctx, tx, req, displayCurrency, expectedTotal, rates, terms, actor)
+	if err != nil {
+		return uuid.Nil, time.Time{}, stay.shown, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, time.Time{}, stay.shown, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return stay.id, stay.respondBy, stay.shown, nil
+}
+
+// AcceptBookingRequest turns request id into a booking waiting for payment
+// at the price it was requested at, with a pending payment through provider
+// like CreateBooking's. It returns the payment, the method the guest left to
+// pay with, and the quote in the currency the guest was shown. It fails with
+// ErrNotRequested if the booking is not waiting for the host and
+// ErrRequestExpired once its deadline has passed.
+func (repo *Repository) AcceptBookingRequest(id uuid.UUID, provider string, paymentTimeout time.Duration, actor models.Actor) (models.Payment, string, models.PriceQuote, error) {
+	lockQuery := `
+		SELECT b.status, b.respond_by <= NOW(), b.total_price, b.currency, b.price_breakdown,
+			COALESCE(b.request_payment_method, ''), ` + bookingDisplayColumns + `
+		FROM bookings b
+		WHERE b.id = $1
+		FOR UPDATE;
+	`
+
+	var (
+		status        string
+		expired       sql.NullBool
+		total         money.Amount
+		currency      string
+		breakdown     sql.NullString
+		paymentMethod string
+		display       bookingDisplayScan
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, "", models.PriceQuote{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, lockQuery, id).Scan(append([]any{
+		&status, &expired, &total, &currency, &breakdown, &paymentMethod,
+	}, display.dests()...)...)
+	if err != nil {
+		return models.Payment{}, "", models.PriceQuote{}, err
+	}
+	if status != models.BookingRequested {
+		return models.Payment{}, "", models.PriceQuote{}, ErrNotRequested
+	}
+	if expired.Bool {
+		return models.Payment{}, "", models.PriceQuote{}, ErrRequestExpired
+	}
+
+	quote, charge, err := bookedCharge(breakdown, total, currency, display)
+	if err != nil {
+		return models.Payment{}, "", quote, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'pending_payment', host_responded_at = NOW(), request_payment_method = NULL
+		WHERE id = $1;
+	`, id)
+	if err != nil {
+		return models.Payment{}, "", quote, err
+	}
+
+	payment, err := createPayment(ctx, tx, id, uuid.NullUUID{}, provider, charge, paymentTimeout)
+	if err != nil {
+		return models.Payment{}, "", quote, err
+	}
+
+	before := map[string]any{"status": status}
+	after := map[string]any{"status": models.BookingPendingPayment}
+	if err := recordAudit(ctx, tx, actor, "booking.request_accept", "booking", id, before, after); err != nil {
+		return models.Payment{}, "", quote, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Payment{}, "", quote, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return payment, paymentMethod, quote, nil
+}
+
+// DeclineBookingRequest turns down request id with an optional reason for
+// the guest, freeing its dates and coupon.
+func (repo *Repository) DeclineBookingRequest(id uuid.UUID, reason string, actor models.Actor) error {
+	return repo.endBookingRequest(id, models.BookingDeclined, reason, actor)
+}
+
+// ExpireBookingRequest frees the dates and coupon of a request the host did
+// not answer in time. It fails with ErrNotRequested if the request was
+// answered or withdrawn meanwhile.
+func (repo *Repository) ExpireBookingRequest(id uuid.UUID, actor models.Actor) error {
+	return repo.endBookingRequest(id, models.BookingRequestExpired, "", actor)
+}
+
+// endBookingRequest moves request id to status. Only requests past their
+// deadline can expire.
+func (repo *Repository) endBookingRequest(id uuid.UUID, status, reason string, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		previous string
+		expired  sql.NullBool
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, respond_by <= NOW() FROM bookings WHERE id = $1 FOR UPDATE;
+	`, id).Scan(&previous, &expired)
+	if err != nil {
+		return err
+	}
+	if previous != models.BookingRequested || (status == models.BookingRequestExpired && !expired.Bool) {
+		return ErrNotRequested
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = $2, decline_reason = NULLIF($3, ''), request_payment_method = NULL,
+			host_responded_at = CASE WHEN $2 = 'declined' THEN NOW() END
+		WHERE id = $1;
+	`, id, status, reason)
+	if err != nil {
+		return err
+	}
+	if err := releaseCoupon(ctx, tx, id); err != nil {
+		return err
+	}
+
+	before := map[string]any{"status": previous}
+	after := map[string]any{"status": status}
+	if reason != "" {
+		after["reason"] = reason
+	}
+	if err := recordAudit(ctx, tx, actor, "booking."+status, "booking", id, before, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetBookingRequests lists the requests waiting for hostID to answer, or
+// for any host when hostID is not set, soonest deadline first.
+func (repo *Repository) GetBookingRequests(hostID uuid.NullUUID) ([]models.Booking, error) {
+	query := `
+		SELECT b.id, b.start_date, b.end_date, p.title, p.location,
+			b.total_price, b.tax_total, b.currency, b.status, b.respond_by, ` + bookingDisplayColumns + `
+		FROM bookings b
+		JOIN properties p ON b.property_id = p.id
+		WHERE b.status = 'requested' AND ($1::uuid IS NULL OR p.user_id = $1)
+		ORDER BY b.respond_by;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, query, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		var (
+			booking   models.Booking
+			respondBy time.Time
+			display   bookingDisplayScan
+		)
+		err := rows.Scan(append([]any{
+			&booking.ID, &booking.StartDate, &booking.EndDate, &booking.Property.Title, &booking.Property.Location,
+			&booking.TotalPrice, &booking.Tax, &booking.Currency, &booking.Status, &respondBy,
+		}, display.dests()...)...)
+		if err != nil {
+			return bookings, err
+		}
+		booking.RespondBy = &respondBy
+		if booking.BookingDisplay, err = display.value(booking.Currency); err != nil {
+			return bookings, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
+// DueRequestExpiries returns up to limit requests whose deadline has passed,
+// oldest first.
+func (repo *Repository) DueRequestExpiries(limit int) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT id FROM bookings
+		WHERE status = 'requested' AND respond_by <= NOW()
+		ORDER BY respond_by
+		LIMIT $1;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
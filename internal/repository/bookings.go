@@ -3,16 +3,22 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
 	"github.com/google/uuid"
 )
 
 func (repo *Repository) GetBookings(userID uuid.UUID) ([]models.Booking, error) {
 	query := `
-		SELECT b.id, b.start_date, b.end_date, p.title, p.location, b.total_price, b.status
+		SELECT b.id, b.start_date, b.end_date,
+			COALESCE(p.title, b.property_title, ''), COALESCE(p.location, b.property_location, ''),
+			b.total_price, b.tax_total, b.currency, b.status, b.respond_by, ` + bookingDisplayColumns + `
 		FROM bookings b
 		LEFT JOIN properties p ON b.property_id = p.id
 		WHERE b.user_id = $1;
@@ -30,20 +36,33 @@ func (repo *Repository) GetBookings(userID uuid.UUID) ([]models.Booking, error)
 	}
 
 	for rows.Next() {
-		var booking models.Booking
+		var (
+			booking   models.Booking
+			respondBy sql.NullTime
+			display   bookingDisplayScan
+		)
 
-		err := rows.Scan(
+		err := rows.Scan(append([]any{
 			&booking.ID,
 			&booking.StartDate,
 			&booking.EndDate,
 			&booking.Property.Title,
 			&booking.Property.Location,
 			&booking.TotalPrice,
+			&booking.Tax,
+			&booking.Currency,
 			&booking.Status,
-		)
+			&respondBy,
+		}, display.dests()...)...)
 		if err != nil {
 			return bookings, err
 		}
+		if respondBy.Valid {
+			booking.RespondBy = &respondBy.Time
+		}
+		if booking.BookingDisplay, err = display.value(booking.Currency); err != nil {
+			return bookings, err
+		}
 		bookings = append(bookings, booking)
 	}
 
@@ -55,78 +74,685 @@ func (repo *Repository) GetBookings(userID uuid.UUID) ([]models.Booking, error)
 
 }
 
-func (repo *Repository) CreateBooking(userId uuid.UUID, propertyID uuid.UUID, startDate time.Time, endDate time.Time, totalPrice int) (uuid.UUID, error) {
-	query := `
-		INSERT INTO bookings (user_id, property_id, start_date, end_date, total_price)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id;
-	`
-	var id uuid.UUID
+// activeBookingStatuses are the booking statuses that hold their nights, as
+// in the bookings_no_overlap constraint.
+const activeBookingStatuses = `('held', 'requested', 'pending_payment', 'confirmed')`
+
+// bookingDisplayColumns are read by bookingDisplayScan.
+const bookingDisplayColumns = `b.display_currency, b.display_total, b.fx_rate, b.fx_rate_as_of`
+
+type bookingDisplayScan struct {
+	currency sql.NullString
+	total    sql.NullString
+	rate     sql.NullFloat64
+	asOf     sql.NullTime
+}
+
+func (s *bookingDisplayScan) dests() []any {
+	return []any{&s.currency, &s.total, &s.rate, &s.asOf}
+}
+
+// value builds the display total of a booking priced in currency.
+func (s *bookingDisplayScan) value(currency string) (models.BookingDisplay, error) {
+	if !s.currency.Valid || !s.total.Valid {
+		return models.BookingDisplay{}, nil
+	}
 
+	total, err := money.ParseAmount(s.total.String)
+	if err != nil {
+		return models.BookingDisplay{}, err
+	}
+
+	return models.BookingDisplay{
+		DisplayTotal: &money.Money{Amount: total, Currency: s.currency.String},
+		ExchangeRate: &fx.Rate{From: currency, To: s.currency.String, Value: s.rate.Float64, AsOf: s.asOf.Time},
+	}, nil
+}
+
+// bookedCharge is what the guest is to pay for a booking not yet charged,
+// in the currency they were shown, with the quote it was priced at in that
+// currency.
+func bookedCharge(breakdown sql.NullString, total money.Amount, currency string, display bookingDisplayScan) (models.PriceQuote, money.Money, error) {
+	var quote models.PriceQuote
+	if breakdown.Valid {
+		if err := json.Unmarshal([]byte(breakdown.String), &quote); err != nil {
+			return quote, money.Money{}, err
+		}
+	}
+
+	booked, err := display.value(currency)
+	if err != nil {
+		return quote, money.Money{}, err
+	}
+	if booked.DisplayTotal != nil {
+		return pricing.Convert(quote, *booked.ExchangeRate), *booked.DisplayTotal, nil
+	}
+	return quote, money.Money{Amount: total, Currency: currency}, nil
+}
+
+// CreateBooking books a stay at the price quoted now, in the property's
+// currency. When displayCurrency differs, the quote is converted with a rate
+// from rates that is stored on the booking, so the guest is held to the
+// amount they were shown. When expectedTotal (in displayCurrency) is set,
+// the booking fails with ErrPriceChanged unless it matches that price. The
+// returned quote is in displayCurrency. A coupon in req is redeemed by the
+// booking, and its co-guests are stored with it.
+//
+// The booking holds its nights while it waits for payment. It comes with a
+// pending payment through provider for the amount shown to the guest, which
+// must be captured within paymentTimeout to confirm it. Listings booked on
+// request fail with ErrRequestToBook; see RequestBooking.
+func (repo *Repository) CreateBooking(req models.StayRequest, displayCurrency string, expectedTotal *money.Amount, rates fx.Provider, provider string, paymentTimeout time.Duration, actor models.Actor) (models.Payment, models.PriceQuote, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := repo.db.QueryRowContext(ctx, query, userId, propertyID, startDate, endDate, totalPrice).Scan(&id)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, models.PriceQuote{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stay, err := bookStay(ctx, tx, req, displayCurrency, expectedTotal, rates, bookingTerms{}, actor)
+	if err != nil {
+		return models.Payment{}, stay.shown, err
+	}
 
+	payment, err := createPayment(ctx, tx, stay.id, uuid.NullUUID{}, provider, stay.charge, paymentTimeout)
 	if err != nil {
-		return uuid.Nil, err
+		return models.Payment{}, stay.shown, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Payment{}, stay.shown, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return payment, stay.shown, nil
+}
+
+// HoldLimits caps the holds active at once for a guest and for a listing.
+type HoldLimits struct {
+	PerUser     int
+	PerProperty int
+}
+
+// HoldStay reserves a stay for holdFor without taking payment, priced and
+// checked like CreateBooking. The hold keeps its nights until it expires,
+// is released, or is confirmed with ConfirmHold at the price quoted now. It
+// returns the hold's id and expiry, and the quote in displayCurrency.
+// Listings booked on request cannot be held (ErrRequestToBook), and a hold
+// that would take the guest or the listing over limits fails with
+// ErrTooManyHolds.
+func (repo *Repository) HoldStay(req models.StayRequest, displayCurrency string, expectedTotal *money.Amount, rates fx.Provider, holdFor time.Duration, limits HoldLimits, actor models.Actor) (uuid.UUID, time.Time, models.PriceQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, time.Time{}, models.PriceQuote{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the guest's row serialises their holds, as bookStay's lock on the
+	// listing does the listing's, so concurrent holds cannot both slip
+	// under a cap
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE;`, req.UserID); err != nil {
+		return uuid.Nil, time.Time{}, models.PriceQuote{}, err
+	}
+
+	stay, err := bookStay(ctx, tx, req, displayCurrency, expectedTotal, rates, bookingTerms{holdFor: holdFor}, actor)
+	if err != nil {
+		return uuid.Nil, time.Time{}, stay.shown, err
+	}
+
+	// counted with the new hold
+	var byUser, byProperty int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE user_id = $1), COUNT(*) FILTER (WHERE property_id = $2)
+		FROM bookings
+		WHERE status = 'held' AND hold_expires_at > NOW() AND (user_id = $1 OR property_id = $2);
+	`, req.UserID, req.PropertyID).Scan(&byUser, &byProperty)
+	if err != nil {
+		return uuid.Nil, time.Time{}, stay.shown, err
+	}
+	if byUser > limits.PerUser || byProperty > limits.PerProperty {
+		return uuid.Nil, time.Time{}, stay.shown, ErrTooManyHolds
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, time.Time{}, stay.shown, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return id, nil
+	return stay.id, stay.holdExpiresAt, stay.shown, nil
+}
+
+// bookedStay is a booking, hold or request made by bookStay. charge is
+// what the guest is to pay, in the currency they were shown.
+type bookedStay struct {
+	id            uuid.UUID
+	shown         models.PriceQuote
+	charge        money.Money
+	holdExpiresAt time.Time
+	respondBy     time.Time
+}
+
+// bookingTerms is how bookStay books a stay: as a hold for holdFor, as a
+// request the host answers within respondWithin, or, with neither, as a
+// booking waiting for payment.
+type bookingTerms struct {
+	holdFor       time.Duration
+	respondWithin time.Duration
+	// paymentMethod is charged when a request is accepted.
+	paymentMethod string
+}
+
+// bookStay prices and inserts a booking as terms say. Requests are only for
+// listings booked on request, and those take nothing else.
+func bookStay(ctx context.Context, tx *sql.Tx, req models.StayRequest, displayCurrency string, expectedTotal *money.Amount, rates fx.Provider, terms bookingTerms, actor models.Actor) (bookedStay, error) {
+	query := `
+		INSERT INTO bookings (user_id, property_id, start_date, end_date, total_price, guests, price_breakdown,
+			currency, display_currency, display_total, fx_rate, fx_rate_as_of, tax_total,
+			coupon_id, coupon_code, coupon_discount, cancellation_policy, adults, children, infants, pets,
+			status, hold_expires_at, respond_by, request_payment_method)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21,
+			$22, CASE WHEN $23 > 0 THEN NOW() + $23 * INTERVAL '1 second' END,
+			CASE WHEN $24 > 0 THEN NOW() + $24 * INTERVAL '1 second' END, NULLIF($25, ''))
+		RETURNING id, hold_expires_at, respond_by;
+	`
+
+	// FOR UPDATE keeps the listing from being unpublished mid-booking and
+	// serialises bookings with calendar changes for the property.
+	statusQuery := `
+		SELECT status, cancellation_policy, booking_mode FROM properties WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
+	`
+
+	var stay bookedStay
+
+	var status, policy, mode string
+	if err := tx.QueryRowContext(ctx, statusQuery, req.PropertyID).Scan(&status, &policy, &mode); err != nil {
+		return stay, err
+	}
+	if status != models.ListingPublished {
+		return stay, ErrListingUnavailable
+	}
+	if mode == models.BookOnRequest && terms.respondWithin <= 0 {
+		return stay, ErrRequestToBook
+	}
+	if mode != models.BookOnRequest && terms.respondWithin > 0 {
+		return stay, ErrInstantBook
+	}
+
+	quote, err := quoteStay(ctx, tx, req, rates)
+	if err != nil {
+		return stay, err
+	}
+
+	var display models.BookingDisplay
+	stay.shown = quote
+	if displayCurrency != "" && displayCurrency != quote.Currency {
+		rate, err := rates.Rate(ctx, quote.Currency, displayCurrency)
+		if err != nil {
+			return stay, err
+		}
+		stay.shown = pricing.Convert(quote, rate)
+		display = models.BookingDisplay{
+			DisplayTotal: &money.Money{Amount: stay.shown.Total, Currency: displayCurrency},
+			ExchangeRate: &rate,
+		}
+	}
+	if expectedTotal != nil && *expectedTotal != stay.shown.Total {
+		return stay, ErrPriceChanged
+	}
+
+	breakdown, err := json.Marshal(quote)
+	if err != nil {
+		return stay, err
+	}
+
+	var (
+		displayCode, displayTotal any
+		fxRate, fxAsOf            any
+		couponID                  uuid.NullUUID
+		couponCode                sql.NullString
+		couponDiscount            money.Amount
+		holdExpiresAt, respondBy  sql.NullTime
+	)
+	if display.DisplayTotal != nil {
+		displayCode, displayTotal = display.DisplayTotal.Currency, display.DisplayTotal.Amount
+		fxRate, fxAsOf = display.ExchangeRate.Value, display.ExchangeRate.AsOf
+	}
+	if c := quote.Coupon; c != nil {
+		couponID = uuid.NullUUID{UUID: c.ID, Valid: true}
+		couponCode = sql.NullString{String: c.Code, Valid: true}
+		couponDiscount = c.Discount
+	}
+
+	bookingStatus := models.BookingPendingPayment
+	switch {
+	case terms.holdFor > 0:
+		bookingStatus = models.BookingHeld
+	case terms.respondWithin > 0:
+		bookingStatus = models.BookingRequested
+	}
+
+	err = tx.QueryRowContext(ctx, query, req.UserID, req.PropertyID, req.StartDate, req.EndDate, quote.Total, req.Party.Guests(), string(breakdown),
+		quote.Currency, displayCode, displayTotal, fxRate, fxAsOf, quote.Tax,
+		couponID, couponCode, couponDiscount, policy,
+		req.Party.Adults, req.Party.Children, req.Party.Infants, req.Party.Pets,
+		bookingStatus, int64(terms.holdFor/time.Second), int64(terms.respondWithin/time.Second),
+		terms.paymentMethod).Scan(&stay.id, &holdExpiresAt, &respondBy)
+
+	if err != nil {
+		if isExclusionViolation(err) {
+			return stay, ErrDatesUnavailable
+		}
+		return stay, err
+	}
+	stay.holdExpiresAt = holdExpiresAt.Time
+	stay.respondBy = respondBy.Time
+
+	if err := recordBookingTaxes(ctx, tx, stay.id, quote.Taxes); err != nil {
+		return stay, err
+	}
+
+	if err := recordCoGuests(ctx, tx, stay.id, req.CoGuests); err != nil {
+		return stay, err
+	}
+
+	if quote.Coupon != nil {
+		if err := redeemCoupon(ctx, tx, *quote.Coupon, stay.id, req.UserID, quote.Currency); err != nil {
+			return stay, err
+		}
+	}
+
+	stay.charge = money.Money{Amount: quote.Total, Currency: quote.Currency}
+	if display.DisplayTotal != nil {
+		stay.charge = *display.DisplayTotal
+	}
+
+	after := map[string]any{
+		"user_id":     req.UserID,
+		"property_id": req.PropertyID,
+		"start_date":  req.StartDate.Format("2006-01-02"),
+		"end_date":    req.EndDate.Format("2006-01-02"),
+		"party":       req.Party,
+		"total_price": quote.Total,
+		"tax":         quote.Tax,
+		"currency":    quote.Currency,
+		"policy":      policy,
+		"status":      bookingStatus,
+	}
+	if quote.Coupon != nil {
+		after["coupon"] = quote.Coupon
+	}
+	if display.DisplayTotal != nil {
+		after["display_total"] = display.DisplayTotal
+		after["exchange_rate"] = display.ExchangeRate.Value
+	}
+	if holdExpiresAt.Valid {
+		after["hold_expires_at"] = holdExpiresAt.Time
+	}
+	if respondBy.Valid {
+		after["respond_by"] = respondBy.Time
+	}
+	if err := recordAudit(ctx, tx, actor, "booking.create", "booking", stay.id, nil, after); err != nil {
+		return stay, err
+	}
+
+	return stay, nil
 }
 
 func (repo *Repository) GetBookingByID(id uuid.UUID) (models.GetBooking, error) {
 	query := `
-		SELECT b.id, b.start_date, b.end_date, b.total_price, b.status, p.title, p.location, u.first_name, u.last_name
+		SELECT b.id, b.start_date, b.end_date, b.total_price, b.tax_total, b.currency, b.status,
+			COALESCE(p.title, b.property_title, ''), COALESCE(p.location, b.property_location, ''),
+			u.first_name, u.last_name, b.guests, b.adults, b.children, b.infants, b.pets,
+			b.price_breakdown, b.cancellation_policy, b.refund_breakdown, b.hold_expires_at,
+			b.respond_by, COALESCE(b.decline_reason, ''),
+			` + bookingDisplayColumns + `
 		FROM bookings b
 		LEFT JOIN properties p ON b.property_id = p.id
 		LEFT JOIN users u ON b.user_id = u.id
 		WHERE b.id = $1;
 	`
 
-	var booking models.GetBooking
+	var (
+		booking   models.GetBooking
+		breakdown sql.NullString
+		refund    sql.NullString
+		display   bookingDisplayScan
+
+		holdExpiresAt sql.NullTime
+		respondBy     sql.NullTime
+	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := repo.db.QueryRowContext(ctx, query, id).Scan(
+	err := repo.db.QueryRowContext(ctx, query, id).Scan(append([]any{
 		&booking.ID,
 		&booking.StartDate,
 		&booking.EndDate,
 		&booking.TotalPrice,
+		&booking.Tax,
+		&booking.Currency,
 		&booking.Status,
 		&booking.Property.Title,
 		&booking.Property.Location,
 		&booking.FirstName,
 		&booking.LastName,
-	)
+		&booking.Guests,
+		&booking.Party.Adults,
+		&booking.Party.Children,
+		&booking.Party.Infants,
+		&booking.Party.Pets,
+		&breakdown,
+		&booking.CancellationPolicy,
+		&refund,
+		&holdExpiresAt,
+		&respondBy,
+		&booking.DeclineReason,
+	}, display.dests()...)...)
 
 	if err != nil {
 		return booking, err
 	}
 
+	if booking.BookingDisplay, err = display.value(booking.Currency); err != nil {
+		return booking, err
+	}
+
+	if breakdown.Valid {
+		if err := json.Unmarshal([]byte(breakdown.String), &booking.PriceBreakdown); err != nil {
+			return booking, err
+		}
+	}
+
+	if refund.Valid {
+		if err := json.Unmarshal([]byte(refund.String), &booking.Refund); err != nil {
+			return booking, err
+		}
+	}
+
+	if holdExpiresAt.Valid {
+		booking.HoldExpiresAt = &holdExpiresAt.Time
+	}
+	if respondBy.Valid {
+		booking.RespondBy = &respondBy.Time
+	}
+
+	if booking.Payments, err = bookingPayments(ctx, repo.db, id); err != nil {
+		return booking, err
+	}
+
+	if booking.CoGuests, err = bookingCoGuests(ctx, repo.db, id); err != nil {
+		return booking, err
+	}
+
 	return booking, nil
 }
 
-func (repo *Repository) CancelBooking(id uuid.UUID, userID uuid.UUID) (string, error) {
-	query := `
-        UPDATE bookings
-        SET status = 'cancelled'
-        WHERE id = $1 AND user_id = $2
-        RETURNING status;
-    `
+// CancelBooking cancels a guest's booking at now under the cancellation
+// policy it was made with, and records the refund due.
+func (repo *Repository) CancelBooking(id uuid.UUID, userID uuid.UUID, now time.Time, actor models.Actor) (models.Cancellation, error) {
+	return repo.cancelBooking(id, &userID, models.CancelledByGuest, "", now, actor)
+}
+
+// HostCancelBooking cancels a booking on the host's side: the guest is
+// refunded in full and the host is charged a penalty of HostPenaltyPercent
+// of the booking net of tax.
+func (repo *Repository) HostCancelBooking(id uuid.UUID, reason string, now time.Time, actor models.Actor) (models.Cancellation, error) {
+	return repo.cancelBooking(id, nil, models.CancelledByHost, reason, now, actor)
+}
+
+// cancelBooking cancels booking id, which must belong to userID when it is
+// set. Only bookings holding their dates can be cancelled
+// (ErrNotCancellable), and not once the stay is over (ErrStayCompleted).
+// Guests can withdraw a request; hosts decline it instead.
+func (repo *Repository) cancelBooking(id uuid.UUID, userID *uuid.UUID, by, reason string, now time.Time, actor models.Actor) (models.Cancellation, error) {
+	// Check-in and check-out are taken in UTC at the property's times, or
+	// the defaults if the property has been purged.
+	lockQuery := `
+		SELECT b.user_id, b.property_id, p.user_id, b.status, b.total_price, b.tax_total, b.currency,
+			b.cancellation_policy, b.price_breakdown,
+			b.start_date + COALESCE(p.check_in_time, '15:00'::time),
+			b.end_date + COALESCE(p.check_out_time, '11:00'::time)
+		FROM bookings b
+		LEFT JOIN properties p ON p.id = b.property_id
+		WHERE b.id = $1
+		FOR UPDATE OF b;
+	`
+
+	var (
+		guestID           uuid.UUID
+		propertyID        uuid.NullUUID
+		hostID            uuid.NullUUID
+		previous          string
+		total, tax        money.Amount
+		currency, policy  string
+		breakdown         sql.NullString
+		checkIn, checkOut time.Time
+	)
 
-	var status string
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := repo.db.QueryRowContext(ctx, query, id, userID).Scan(&status)
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", sql.ErrNoRows
+		return models.Cancellation{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, lockQuery, id).Scan(&guestID, &propertyID, &hostID, &previous, &total, &tax,
+		&currency, &policy, &breakdown, &checkIn, &checkOut)
+	if err != nil {
+		return models.Cancellation{}, err
+	}
+	if userID != nil && *userID != guestID {
+		return models.Cancellation{}, sql.ErrNoRows
+	}
+	switch {
+	case previous == models.BookingPendingPayment, previous == models.BookingConfirmed:
+	case previous == models.BookingRequested && by == models.CancelledByGuest:
+	default:
+		return models.Cancellation{}, ErrNotCancellable
+	}
+	if !now.Before(checkOut) {
+		return models.Cancellation{}, ErrStayCompleted
+	}
+
+	var cleaningFee money.Amount
+	if breakdown.Valid {
+		var quote models.PriceQuote
+		if err := json.Unmarshal([]byte(breakdown.String), &quote); err != nil {
+			return models.Cancellation{}, err
 		}
-		return "", err
+		cleaningFee = quote.CleaningFee
+	}
+
+	// nothing has been paid yet for a request or a booking still waiting on
+	// payment; a capture arriving later is refunded in full
+	var refund models.RefundBreakdown
+	if by == models.CancelledByHost || previous == models.BookingPendingPayment || previous == models.BookingRequested {
+		refund = pricing.HostCancellationRefund(policy, total, cleaningFee, tax, currency, checkIn, now)
+		refund.CancelledBy = by
+	} else {
+		refund = pricing.CancellationRefund(policy, total, cleaningFee, tax, currency, checkIn, now)
+	}
+
+	refundJSON, err := json.Marshal(refund)
+	if err != nil {
+		return models.Cancellation{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'cancelled', cancelled_at = $2, cancelled_by = $3, refund_amount = $4, refund_breakdown = $5,
+			request_payment_method = NULL
+		WHERE id = $1;
+	`, id, now, by, refund.Total, string(refundJSON))
+	if err != nil {
+		return models.Cancellation{}, err
+	}
+
+	if err := releaseCoupon(ctx, tx, id); err != nil {
+		return models.Cancellation{}, err
+	}
+
+	if err := recordRefundsDue(ctx, tx, id, refund, "booking cancelled by "+refund.CancelledBy); err != nil {
+		return models.Cancellation{}, err
+	}
+
+	// a change still waiting for the host no longer has a booking to apply to
+	_, err = tx.ExecContext(ctx, `
+		UPDATE booking_modifications
+		SET status = 'declined', decision_reason = 'booking cancelled', decided_at = NOW()
+		WHERE booking_id = $1 AND status = 'pending';
+	`, id)
+	if err != nil {
+		return models.Cancellation{}, err
+	}
+
+	c := models.Cancellation{BookingID: id, Status: models.BookingCancelled, Refund: refund}
+
+	if by == models.CancelledByHost {
+		c.Penalty = &models.HostPenalty{
+			Amount:   (total - tax).Percent(pricing.HostPenaltyPercent).Round(currency),
+			Currency: currency,
+			Reason:   reason,
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO host_cancellation_penalties (booking_id, host_id, property_id, reason, amount, currency)
+			VALUES ($1, $2, $3, $4, $5, $6);
+		`, id, hostID, propertyID, reason, c.Penalty.Amount, currency)
+		if err != nil {
+			return models.Cancellation{}, err
+		}
+	}
+
+	before := map[string]any{"status": previous}
+	after := map[string]any{"status": c.Status, "refund": refund}
+	if c.Penalty != nil {
+		after["penalty"] = c.Penalty
+	}
+	if err := recordAudit(ctx, tx, actor, "booking.cancel", "booking", id, before, after); err != nil {
+		return models.Cancellation{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Cancellation{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return c, nil
+}
+
+// recordRefundsDue records on each captured payment of a booking its share
+// of refund, so what the guest is owed survives a gateway that will not
+// take the refund straight away.
+func recordRefundsDue(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID, refund models.RefundBreakdown, reason string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+paymentColumns+` FROM payments pm
+		WHERE pm.booking_id = $1 AND pm.status IN ('captured', 'partially_refunded')
+		FOR UPDATE;
+	`, bookingID)
+	if err != nil {
+		return err
+	}
+
+	var captured []models.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		captured = append(captured, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range captured {
+		due := min(pricing.PaymentRefund(p.Amount, p.Currency, refund), p.Amount-p.RefundedAmount)
+		if due <= 0 {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			UPDATE payments SET
+				refund_due = $2, refund_reason = $3, refund_error = NULL, refund_attempts = 0,
+				refund_retry_at = NOW() + $4 * INTERVAL '1 second', updated_at = NOW()
+			WHERE id = $1;
+		`, p.ID, due, reason, int64(refundRetryDelay/time.Second))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordCoGuests stores the named co-guests of a new booking, in order.
+func recordCoGuests(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID, guests []models.CoGuest) error {
+	for i, g := range guests {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO booking_guests (booking_id, position, first_name, last_name, age_group)
+			VALUES ($1, $2, $3, $4, $5);
+		`, bookingID, i, g.FirstName, g.LastName, g.AgeGroup)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bookingCoGuests(ctx context.Context, db queryer, bookingID uuid.UUID) ([]models.CoGuest, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT first_name, last_name, age_group FROM booking_guests WHERE booking_id = $1 ORDER BY position;
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guests := []models.CoGuest{}
+	for rows.Next() {
+		var g models.CoGuest
+		if err := rows.Scan(&g.FirstName, &g.LastName, &g.AgeGroup); err != nil {
+			return guests, err
+		}
+		guests = append(guests, g)
+	}
+
+	return guests, rows.Err()
+}
+
+// BookingPropertyID returns the property a booking is for, or uuid.Nil
+// once the property has been purged.
+func (repo *Repository) BookingPropertyID(id uuid.UUID) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var propertyID uuid.NullUUID
+	err := repo.db.QueryRowContext(ctx, `SELECT property_id FROM bookings WHERE id = $1;`, id).Scan(&propertyID)
+	return propertyID.UUID, err
+}
+
+// HasConfirmedBooking reports whether the user holds a paid booking for the
+// property that has not been cancelled.
+func (repo *Repository) HasConfirmedBooking(userID, propertyID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE user_id = $1 AND property_id = $2 AND status = 'confirmed'
+		);
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	if err := repo.db.QueryRowContext(ctx, query, userID, propertyID).Scan(&exists); err != nil {
+		return false, err
 	}
 
-	return status, nil
+	return exists, nil
 }
@@ -0,0 +1,480 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const dateLayout = "2006-01-02"
+
+// Calendar day statuses.
+const (
+	DayAvailable = "available"
+	DayBooked    = "booked"
+	DayBlocked   = "blocked"
+	DayPast      = "past"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	queryRower
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// loadStayRules reads a property's default stay rule and its overrides.
+func loadStayRules(ctx context.Context, db queryer, propertyID uuid.UUID) (models.StayRules, error) {
+	rules := models.StayRules{Overrides: []models.StayRule{}}
+
+	var (
+		minNights int
+		maxNights sql.NullInt64
+		days      pq.Int64Array
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT min_nights, max_nights, check_in_days
+		FROM properties WHERE id = $1 AND deleted_at IS NULL;
+	`, propertyID).Scan(&minNights, &maxNights, &days)
+	if err != nil {
+		return rules, err
+	}
+	rules.Default = models.StayRule{
+		MinNights:   &minNights,
+		MaxNights:   nullIntPtr(maxNights),
+		CheckInDays: intSlice(days),
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'),
+			min_nights, max_nights, check_in_days
+		FROM property_stay_rules
+		WHERE property_id = $1
+		ORDER BY start_date;
+	`, propertyID)
+	if err != nil {
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			rule     models.StayRule
+			min, max sql.NullInt64
+			days     pq.Int64Array
+		)
+		if err := rows.Scan(&rule.StartDate, &rule.EndDate, &min, &max, &days); err != nil {
+			return rules, err
+		}
+		rule.MinNights, rule.MaxNights, rule.CheckInDays = nullIntPtr(min), nullIntPtr(max), intSlice(days)
+		rules.Overrides = append(rules.Overrides, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// effectiveStayRule resolves the rule for a stay checking in on day: the
+// override covering day, with unset fields taken from the default.
+func effectiveStayRule(rules models.StayRules, day time.Time) (minNights int, maxNights *int, checkInDays []int) {
+	minNights, maxNights, checkInDays = 1, rules.Default.MaxNights, rules.Default.CheckInDays
+	if rules.Default.MinNights != nil {
+		minNights = *rules.Default.MinNights
+	}
+
+	// ISO dates compare correctly as strings.
+	date := day.Format(dateLayout)
+	for _, o := range rules.Overrides {
+		if date < o.StartDate || date >= o.EndDate {
+			continue
+		}
+		if o.MinNights != nil {
+			minNights = *o.MinNights
+		}
+		if o.MaxNights != nil {
+			maxNights = o.MaxNights
+		}
+		if o.CheckInDays != nil {
+			checkInDays = o.CheckInDays
+		}
+		break
+	}
+
+	return minNights, maxNights, checkInDays
+}
+
+func checkInAllowed(checkInDays []int, day time.Time) bool {
+	return len(checkInDays) == 0 || slices.Contains(checkInDays, int(day.Weekday()))
+}
+
+// checkStay reports whether a stay from start to end (check-out) satisfies
+// the property's stay rules and overlaps no booked or blocked night. The
+// nights of bookingID, when it is not uuid.Nil, are not counted.
+func checkStay(ctx context.Context, tx *sql.Tx, propertyID uuid.UUID, start, end time.Time, bookingID uuid.UUID) error {
+	rules, err := loadStayRules(ctx, tx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	nights := int(end.Sub(start).Hours() / 24)
+	minNights, maxNights, checkInDays := effectiveStayRule(rules, start)
+	switch {
+	case nights < minNights:
+		return &StayRuleError{Problem: fmt.Sprintf("stays starting %s must be at least %d nights", start.Format(dateLayout), minNights)}
+	case maxNights != nil && nights > *maxNights:
+		return &StayRuleError{Problem: fmt.Sprintf("stays starting %s can be at most %d nights", start.Format(dateLayout), *maxNights)}
+	case !checkInAllowed(checkInDays, start):
+		return &StayRuleError{Problem: fmt.Sprintf("check-in is not allowed on %s", start.Weekday())}
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE property_id = $1 AND status IN `+activeBookingStatuses+` AND id <> $4
+			AND daterange(start_date, end_date, '[)') && daterange($2::date, $3::date, '[)')
+		) OR EXISTS (
+			SELECT 1 FROM property_blocked_dates
+			WHERE property_id = $1
+			AND daterange(start_date, end_date, '[)') && daterange($2::date, $3::date, '[)')
+		);
+	`, propertyID, start, end, bookingID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDatesUnavailable
+	}
+
+	return nil
+}
+
+// GetPropertyCalendar returns one month of per-day availability, stay rules
+// and nightly rate. month is the first day of the month.
+func (repo *Repository) GetPropertyCalendar(propertyID uuid.UUID, month time.Time) (models.PropertyCalendar, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	next := first.AddDate(0, 1, 0)
+
+	calendar := models.PropertyCalendar{
+		PropertyID: propertyID,
+		Month:      first.Format("2006-01"),
+		Days:       []models.CalendarDay{},
+		Blocks:     []models.CalendarBlock{},
+	}
+
+	prices, err := loadPropertyPricing(ctx, repo.db, propertyID)
+	if err != nil {
+		return calendar, err
+	}
+	calendar.Currency = prices.Currency
+
+	rules, err := loadStayRules(ctx, repo.db, propertyID)
+	if err != nil {
+		return calendar, err
+	}
+
+	blocks, err := repo.getCalendarBlocks(ctx, propertyID, first, next)
+	if err != nil {
+		return calendar, err
+	}
+	calendar.Blocks = blocks
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD')
+		FROM bookings
+		WHERE property_id = $1 AND status IN `+activeBookingStatuses+`
+		AND daterange(start_date, end_date, '[)') && daterange($2::date, $3::date, '[)');
+	`, propertyID, first, next)
+	if err != nil {
+		return calendar, err
+	}
+	defer rows.Close()
+
+	var booked [][2]string
+	for rows.Next() {
+		var stay [2]string
+		if err := rows.Scan(&stay[0], &stay[1]); err != nil {
+			return calendar, err
+		}
+		booked = append(booked, stay)
+	}
+	if err := rows.Err(); err != nil {
+		return calendar, err
+	}
+
+	today := time.Now().UTC().Format(dateLayout)
+	for day := first; day.Before(next); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+
+		status := DayAvailable
+		switch {
+		case date < today:
+			status = DayPast
+		case slices.ContainsFunc(booked, func(s [2]string) bool { return date >= s[0] && date < s[1] }):
+			status = DayBooked
+		case slices.ContainsFunc(blocks, func(b models.CalendarBlock) bool { return date >= b.StartDate && date < b.EndDate }):
+			status = DayBlocked
+		}
+
+		minNights, maxNights, checkInDays := effectiveStayRule(rules, day)
+		price, _ := pricing.Rate(prices, day)
+		calendar.Days = append(calendar.Days, models.CalendarDay{
+			Date:           date,
+			Status:         status,
+			Available:      status == DayAvailable,
+			Price:          price,
+			MinNights:      minNights,
+			MaxNights:      maxNights,
+			CheckInAllowed: status == DayAvailable && checkInAllowed(checkInDays, day),
+		})
+	}
+
+	return calendar, nil
+}
+
+func (repo *Repository) getCalendarBlocks(ctx context.Context, propertyID uuid.UUID, from, to time.Time) ([]models.CalendarBlock, error) {
+	blocks := []models.CalendarBlock{}
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT id, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'), reason, import_id
+		FROM property_blocked_dates
+		WHERE property_id = $1
+		AND daterange(start_date, end_date, '[)') && daterange($2::date, $3::date, '[)')
+		ORDER BY start_date;
+	`, propertyID, from, to)
+	if err != nil {
+		return blocks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b models.CalendarBlock
+		var importID uuid.NullUUID
+		if err := rows.Scan(&b.ID, &b.StartDate, &b.EndDate, &b.Reason, &importID); err != nil {
+			return blocks, err
+		}
+		if importID.Valid {
+			b.ImportID = &importID.UUID
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
+
+// lockPropertyCalendar locks the property row so calendar changes and new
+// bookings for it are serialised.
+func lockPropertyCalendar(ctx context.Context, tx *sql.Tx, propertyID uuid.UUID) error {
+	var id uuid.UUID
+	return tx.QueryRowContext(ctx, `
+		SELECT id FROM properties WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
+	`, propertyID).Scan(&id)
+}
+
+// AddCalendarBlock takes the nights from block.StartDate up to block.EndDate
+// off the market. Nights that are already booked cannot be blocked.
+func (repo *Repository) AddCalendarBlock(propertyID uuid.UUID, block models.CalendarBlock, actor models.Actor) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPropertyCalendar(ctx, tx, propertyID); err != nil {
+		return uuid.Nil, err
+	}
+
+	var booked bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE property_id = $1 AND status IN `+activeBookingStatuses+`
+			AND daterange(start_date, end_date, '[)') && daterange($2::date, $3::date, '[)')
+		);
+	`, propertyID, block.StartDate, block.EndDate).Scan(&booked)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if booked {
+		return uuid.Nil, ErrDatesUnavailable
+	}
+
+	var createdBy uuid.NullUUID
+	if actor.UserID != uuid.Nil {
+		createdBy = uuid.NullUUID{UUID: actor.UserID, Valid: true}
+	}
+
+	var id uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO property_blocked_dates (property_id, start_date, end_date, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id;
+	`, propertyID, block.StartDate, block.EndDate, block.Reason, createdBy).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	block.ID = id
+	after := map[string]any{"property_id": propertyID, "block": block}
+	if err := recordAudit(ctx, tx, actor, "calendar_block.create", "calendar_block", id, nil, after); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteCalendarBlock removes a manually created block; imported ranges are
+// managed by their calendar import.
+func (repo *Repository) DeleteCalendarBlock(propertyID, blockID uuid.UUID, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block := models.CalendarBlock{ID: blockID}
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM property_blocked_dates
+		WHERE id = $1 AND property_id = $2 AND import_id IS NULL
+		RETURNING to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'), reason;
+	`, blockID, propertyID).Scan(&block.StartDate, &block.EndDate, &block.Reason)
+	if err != nil {
+		return err
+	}
+
+	before := map[string]any{"property_id": propertyID, "block": block}
+	if err := recordAudit(ctx, tx, actor, "calendar_block.delete", "calendar_block", blockID, before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *Repository) GetStayRules(propertyID uuid.UUID) (models.StayRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return loadStayRules(ctx, repo.db, propertyID)
+}
+
+// SetStayRules replaces a property's default stay rule and all overrides.
+// Overlapping overrides are rejected with ErrConflict.
+func (repo *Repository) SetStayRules(propertyID uuid.UUID, rules models.StayRules, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPropertyCalendar(ctx, tx, propertyID); err != nil {
+		return err
+	}
+
+	before, err := loadStayRules(ctx, tx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE properties SET min_nights = $2, max_nights = $3, check_in_days = $4
+		WHERE id = $1;
+	`, propertyID, *rules.Default.MinNights, rules.Default.MaxNights, checkInDaysArg(rules.Default.CheckInDays))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM property_stay_rules WHERE property_id = $1;`, propertyID); err != nil {
+		return err
+	}
+
+	if len(rules.Overrides) > 0 {
+		values := make([]string, 0, len(rules.Overrides))
+		args := []any{propertyID}
+		for _, o := range rules.Overrides {
+			n := len(args)
+			values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+			args = append(args, o.StartDate, o.EndDate, o.MinNights, o.MaxNights, checkInDaysArg(o.CheckInDays))
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO property_stay_rules (property_id, start_date, end_date, min_nights, max_nights, check_in_days)
+			VALUES `+strings.Join(values, ", ")+`;
+		`, args...)
+		if err != nil {
+			if isExclusionViolation(err) {
+				return ErrConflict
+			}
+			return err
+		}
+	}
+
+	if err := recordAudit(ctx, tx, actor, "property.stay_rules", "property", propertyID, before, rules); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkInDaysArg stores an empty day list as NULL, meaning any day.
+func checkInDaysArg(days []int) any {
+	if len(days) == 0 {
+		return nil
+	}
+
+	arr := make(pq.Int64Array, len(days))
+	for i, d := range days {
+		arr[i] = int64(d)
+	}
+	return arr
+}
+
+func intSlice(arr pq.Int64Array) []int {
+	if arr == nil {
+		return nil
+	}
+
+	out := make([]int, len(arr))
+	for i, v := range arr {
+		out[i] = int(v)
+	}
+	return out
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+
+	n := int(v.Int64)
+	return &n
+}
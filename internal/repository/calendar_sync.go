@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/ical"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxImportEvents caps the ranges kept from one imported calendar.
+	maxImportEvents = 1000
+	// importHorizon is how far ahead imported events are kept.
+	importHorizon = 2 * 365 * 24 * time.Hour
+	// feedHistoryDays keeps recent stays in the exported feed.
+	feedHistoryDays = 30
+	maxBlockReason  = 200
+)
+
+// ICalToken returns the secret token of the property's iCal feed, creating
+// it on first use or replacing it when rotate is set.
+func (repo *Repository) ICalToken(propertyID uuid.UUID, rotate bool, actor models.Actor) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var token sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		SELECT ical_token FROM properties WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
+	`, propertyID).Scan(&token)
+	if err != nil {
+		return "", err
+	}
+	if token.Valid && !rotate {
+		return token.String, nil
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	newToken := hex.EncodeToString(buf)
+
+	if _, err := tx.ExecContext(ctx, `UPDATE properties SET ical_token = $2 WHERE id = $1;`, propertyID, newToken); err != nil {
+		return "", err
+	}
+
+	// The token itself is a credential, so the audit only records the event.
+	before := map[string]any{"ical_feed": token.Valid}
+	after := map[string]any{"ical_feed": true, "rotated": token.Valid}
+	if err := recordAudit(ctx, tx, actor, "property.ical_token", "property", propertyID, before, after); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return newToken, nil
+}
+
+// GetICalFeed returns the title of the property owning token and its booked
+// stays and manually blocked ranges, from feedHistoryDays ago onwards.
+// Imported ranges are left out so platforms do not echo each other's blocks.
+func (repo *Repository) GetICalFeed(token string) (string, []models.CalendarBlock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var (
+		propertyID uuid.UUID
+		title      string
+	)
+	err := repo.db.QueryRowContext(ctx, `
+		SELECT id, title FROM properties WHERE ical_token = $1 AND deleted_at IS NULL;
+	`, token).Scan(&propertyID, &title)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT id, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'), 'Reserved'
+		FROM bookings
+		WHERE property_id = $1 AND status IN `+activeBookingStatuses+` AND end_date >= CURRENT_DATE - $2::int
+		UNION ALL
+		SELECT id, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD'), 'Not available'
+		FROM property_blocked_dates
+		WHERE property_id = $1 AND import_id IS NULL AND end_date >= CURRENT_DATE - $2::int
+		ORDER BY 2;
+	`, propertyID, feedHistoryDays)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var events []models.CalendarBlock
+	for rows.Next() {
+		var e models.CalendarBlock
+		if err := rows.Scan(&e.ID, &e.StartDate, &e.EndDate, &e.Reason); err != nil {
+			return "", nil, err
+		}
+		events = append(events, e)
+	}
+
+	return title, events, rows.Err()
+}
+
+const calendarImportColumns = `
+	id, property_id, name, url, event_count, conflict_count, last_synced_at, last_error, created_at
+`
+
+func scanCalendarImport(row interface{ Scan(...any) error }) (models.CalendarImport, error) {
+	var (
+		imp       models.CalendarImport
+		url       sql.NullString
+		synced    sql.NullTime
+		lastError sql.NullString
+	)
+	err := row.Scan(&imp.ID, &imp.PropertyID, &imp.Name, &url, &imp.EventCount, &imp.ConflictCount,
+		&synced, &lastError, &imp.CreatedAt)
+	if url.Valid {
+		imp.URL = &url.String
+	}
+	if synced.Valid {
+		imp.LastSyncedAt = &synced.Time
+	}
+	if lastError.Valid {
+		imp.LastError = &lastError.String
+	}
+	return imp, err
+}
+
+func (repo *Repository) queryCalendarImports(query string, args ...any) ([]models.CalendarImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	imports := []models.CalendarImport{}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return imports, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		imp, err := scanCalendarImport(rows)
+		if err != nil {
+			return imports, err
+		}
+		imports = append(imports, imp)
+	}
+
+	return imports, rows.Err()
+}
+
+func (repo *Repository) GetCalendarImports(propertyID uuid.UUID) ([]models.CalendarImport, error) {
+	return repo.queryCalendarImports(`
+		SELECT `+calendarImportColumns+`
+		FROM property_calendar_imports
+		WHERE property_id = $1
+		ORDER BY created_at;
+	`, propertyID)
+}
+
+// DueCalendarImports returns URL imports that have never been synced or
+// whose next sync time has passed, oldest first.
+func (repo *Repository) DueCalendarImports(limit int) ([]models.CalendarImport, error) {
+	return repo.queryCalendarImports(`
+		SELECT `+calendarImportColumns+`
+		FROM property_calendar_imports ci
+		WHERE ci.url IS NOT NULL
+		AND (ci.next_sync_at IS NULL OR ci.next_sync_at <= NOW())
+		AND EXISTS (SELECT 1 FROM properties p WHERE p.id = ci.property_id AND p.deleted_at IS NULL)
+		ORDER BY ci.next_sync_at NULLS FIRST
+		LIMIT $1;
+	`, limit)
+}
+
+// CreateCalendarImport registers an external calendar. URL imports are due
+// for sync straight away; pass a nil url for an uploaded file.
+func (repo *Repository) CreateCalendarImport(propertyID uuid.UUID, name string, url *string, actor models.Actor) (models.CalendarImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.CalendarImport{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPropertyCalendar(ctx, tx, propertyID); err != nil {
+		return models.CalendarImport{}, err
+	}
+
+	var createdBy uuid.NullUUID
+	if actor.UserID != uuid.Nil {
+		createdBy = uuid.NullUUID{UUID: actor.UserID, Valid: true}
+	}
+
+	imp, err := scanCalendarImport(tx.QueryRowContext(ctx, `
+		INSERT INTO property_calendar_imports (property_id, name, url, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+calendarImportColumns+`;
+	`, propertyID, name, url, createdBy))
+	if err != nil {
+		return imp, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "calendar_import.create", "calendar_import", imp.ID, nil, imp); err != nil {
+		return imp, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return imp, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return imp, nil
+}
+
+// DeleteCalendarImport removes an import together with its blocked ranges.
+func (repo *Repository) DeleteCalendarImport(propertyID, importID uuid.UUID, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := scanCalendarImport(tx.QueryRowContext(ctx, `
+		DELETE FROM property_calendar_imports
+		WHERE id = $1 AND property_id = $2
+		RETURNING `+calendarImportColumns+`;
+	`, importID, propertyID))
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "calendar_import.delete", "calendar_import", importID, before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ScheduleCalendarImport makes a URL import due for sync on the next run of
+// the refresh job.
+func (repo *Repository) ScheduleCalendarImport(propertyID, importID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id uuid.UUID
+	return repo.db.QueryRowContext(ctx, `
+		UPDATE property_calendar_imports SET next_sync_at = NULL
+		WHERE id = $1 AND property_id = $2 AND url IS NOT NULL
+		RETURNING id;
+	`, importID, propertyID).Scan(&id)
+}
+
+// SyncCalendarImport replaces the import's blocked ranges with events, keeping
+// only nights from today up to importHorizon. Events that overlap local
+// bookings are still blocked and counted as conflicts. The next sync is
+// scheduled interval from now.
+func (repo *Repository) SyncCalendarImport(importID uuid.UUID, events []ical.Event, interval time.Duration, actor models.Actor) (models.CalendarImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.CalendarImport{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var propertyID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		SELECT property_id FROM property_calendar_imports WHERE id = $1 FOR UPDATE;
+	`, importID).Scan(&propertyID)
+	if err != nil {
+		return models.CalendarImport{}, err
+	}
+
+	if err := lockPropertyCalendar(ctx, tx, propertyID); err != nil {
+		return models.CalendarImport{}, err
+	}
+
+	var before []string
+	rows, err := tx.QueryContext(ctx, `
+		SELECT to_char(start_date, 'YYYY-MM-DD') || '/' || to_char(end_date, 'YYYY-MM-DD')
+		FROM property_blocked_dates WHERE import_id = $1 ORDER BY 1;
+	`, importID)
+	if err != nil {
+		return models.CalendarImport{}, err
+	}
+	for rows.Next() {
+		var r string
+		if err := rows.Scan(&r); err != nil {
+			rows.Close()
+			return models.CalendarImport{}, err
+		}
+		before = append(before, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.CalendarImport{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM property_blocked_dates WHERE import_id = $1;`, importID); err != nil {
+		return models.CalendarImport{}, err
+	}
+
+	blocks := importedBlocks(events, time.Now().UTC())
+	var after []string
+	for _, b := range blocks {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO property_blocked_dates (property_id, start_date, end_date, reason, import_id)
+			VALUES ($1, $2, $3, $4, $5);
+		`, propertyID, b.StartDate, b.EndDate, b.Reason, importID)
+		if err != nil {
+			return models.CalendarImport{}, err
+		}
+		after = append(after, b.StartDate+"/"+b.EndDate)
+	}
+	slices.Sort(after)
+
+	imp, err := scanCalendarImport(tx.QueryRowContext(ctx, `
+		UPDATE property_calendar_imports ci SET
+			event_count = $2,
+			conflict_count = (
+				SELECT COUNT(*) FROM property_blocked_dates bd
+				WHERE bd.import_id = ci.id AND EXISTS (
+					SELECT 1 FROM bookings b
+					WHERE b.property_id = bd.property_id AND b.status IN `+activeBookingStatuses+`
+					AND daterange(b.start_date, b.end_date, '[)') && daterange(bd.start_date, bd.end_date, '[)')
+				)
+			),
+			last_synced_at = NOW(),
+			last_error = NULL,
+			next_sync_at = NOW() + $3 * INTERVAL '1 second'
+		WHERE ci.id = $1
+		RETURNING `+calendarImportColumns+`;
+	`, importID, len(blocks), interval.Seconds()))
+	if err != nil {
+		return imp, err
+	}
+
+	// Unchanged refreshes are not worth an audit entry.
+	if !slices.Equal(before, after) {
+		err := recordAudit(ctx, tx, actor, "calendar_import.sync", "calendar_import", importID,
+			map[string]any{"ranges": before}, map[string]any{"ranges": after})
+		if err != nil {
+			return imp, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return imp, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return imp, nil
+}
+
+// FailCalendarImport records a failed refresh; the existing ranges are kept
+// and the import is retried after interval.
+func (repo *Repository) FailCalendarImport(importID uuid.UUID, syncErr error, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, `
+		UPDATE property_calendar_imports
+		SET last_error = $2, next_sync_at = NOW() + $3 * INTERVAL '1 second'
+		WHERE id = $1;
+	`, importID, syncErr.Error(), interval.Seconds())
+	return err
+}
+
+// importedBlocks turns events into blocked ranges clipped to today through
+// importHorizon, dropping anything outside it.
+func importedBlocks(events []ical.Event, now time.Time) []models.CalendarBlock {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.Add(importHorizon)
+
+	var blocks []models.CalendarBlock
+	for _, e := range events {
+		start, end := e.Start, e.End
+		if start.Before(today) {
+			start = today
+		}
+		if end.After(horizon) {
+			end = horizon
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		reason := e.Summary
+		if utf8.RuneCountInString(reason) > maxBlockReason {
+			reason = string([]rune(reason)[:maxBlockReason])
+		}
+
+		blocks = append(blocks, models.CalendarBlock{
+			StartDate: start.Format(dateLayout),
+			EndDate:   end.Format(dateLayout),
+			Reason:    reason,
+		})
+		if len(blocks) == maxImportEvents {
+			break
+		}
+	}
+
+	return blocks
+}
@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
+	"github.com/google/uuid"
+)
+
+// couponColumns selects a coupon from coupons aliased c, in the order of
+// scanCoupon.
+const couponColumns = `
+	c.id, c.code, c.description, c.kind, c.percent, c.amount, c.currency,
+	c.valid_from, c.valid_until, c.max_redemptions, c.max_redemptions_per_user, c.min_nights,
+	c.property_id, c.host_id, c.active, c.redemption_count, c.created_at, c.updated_at`
+
+func scanCoupon(row interface{ Scan(...any) error }) (models.Coupon, error) {
+	var (
+		c                    models.Coupon
+		percent              sql.NullFloat64
+		amount, currency     sql.NullString
+		validFrom            time.Time
+		validUntil           sql.NullTime
+		maxTotal, maxPerUser sql.NullInt64
+		propertyID, hostID   uuid.NullUUID
+		active               bool
+	)
+
+	err := row.Scan(&c.ID, &c.Code, &c.Description, &c.Kind, &percent, &amount, &currency,
+		&validFrom, &validUntil, &maxTotal, &maxPerUser, &c.MinNights,
+		&propertyID, &hostID, &active, &c.RedemptionCount, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, err
+	}
+
+	if percent.Valid {
+		c.Percent = &percent.Float64
+	}
+	if amount.Valid && currency.Valid {
+		a, err := money.ParseAmount(amount.String)
+		if err != nil {
+			return c, err
+		}
+		c.Amount = &money.Money{Amount: a, Currency: currency.String}
+	}
+	c.ValidFrom, c.Active = &validFrom, &active
+	if validUntil.Valid {
+		c.ValidUntil = &validUntil.Time
+	}
+	c.MaxRedemptions = nullIntPtr(maxTotal)
+	c.MaxRedemptionsPerUser = nullIntPtr(maxPerUser)
+	if propertyID.Valid {
+		c.PropertyID = &propertyID.UUID
+	}
+	if hostID.Valid {
+		c.HostID = &hostID.UUID
+	}
+
+	return c, nil
+}
+
+func (repo *Repository) GetCoupons() ([]models.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT `+couponColumns+` FROM coupons c ORDER BY c.created_at DESC;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []models.Coupon{}
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return coupons, err
+		}
+		coupons = append(coupons, c)
+	}
+
+	return coupons, rows.Err()
+}
+
+func getCoupon(ctx context.Context, db queryer, id uuid.UUID, lock bool) (models.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons c WHERE c.id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	return scanCoupon(db.QueryRowContext(ctx, query, id))
+}
+
+func (repo *Repository) GetCoupon(id uuid.UUID) (models.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return getCoupon(ctx, repo.db, id, false)
+}
+
+// couponArgs are the writable columns of a coupon, from code to active.
+func couponArgs(c models.Coupon) []any {
+	var amount, currency any
+	if c.Amount != nil {
+		amount, currency = c.Amount.Amount, c.Amount.Currency
+	}
+	return []any{
+		c.Code, c.Description, c.Kind, c.Percent, amount, currency,
+		c.ValidFrom, c.ValidUntil, c.MaxRedemptions, c.MaxRedemptionsPerUser, c.MinNights,
+		c.PropertyID, c.HostID, c.Active,
+	}
+}
+
+// CreateCoupon adds a promo code, failing with ErrConflict if the code is
+// taken and ErrUnknownCouponScope if its property or host does not exist.
+func (repo *Repository) CreateCoupon(c models.Coupon, actor models.Actor) (models.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createdBy := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+
+	var id uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO coupons (code, description, kind, percent, amount, currency,
+			valid_from, valid_until, max_redemptions, max_redemptions_per_user, min_nights,
+			property_id, host_id, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, NOW()), $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id;
+	`, append(couponArgs(c), createdBy)...).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return c, ErrConflict
+		}
+		if isForeignKeyViolation(err) {
+			return c, ErrUnknownCouponScope
+		}
+		return c, err
+	}
+
+	created, err := getCoupon(ctx, tx, id, false)
+	if err != nil {
+		return c, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "coupon.create", "coupon", id, nil, created); err != nil {
+		return c, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return created, nil
+}
+
+// UpdateCoupon replaces a coupon's settings. The redemption count is kept.
+func (repo *Repository) UpdateCoupon(id uuid.UUID, c models.Coupon, actor models.Actor) (models.Coupon, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return c, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getCoupon(ctx, tx, id, true)
+	if err != nil {
+		return c, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE coupons SET
+			code = $1, description = $2, kind = $3, percent = $4, amount = $5, currency = $6,
+			valid_from = COALESCE($7, valid_from), valid_until = $8, max_redemptions = $9,
+			max_redemptions_per_user = $10, min_nights = $11, property_id = $12, host_id = $13,
+			active = $14, updated_at = NOW()
+		WHERE id = $15;
+	`, append(couponArgs(c), id)...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return c, ErrConflict
+		}
+		if isForeignKeyViolation(err) {
+			return c, ErrUnknownCouponScope
+		}
+		return c, err
+	}
+
+	after, err := getCoupon(ctx, tx, id, false)
+	if err != nil {
+		return c, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "coupon.update", "coupon", id, before, after); err != nil {
+		return c, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after, nil
+}
+
+// DeleteCoupon removes a coupon. Bookings that used it keep its code and
+// discount.
+func (repo *Repository) DeleteCoupon(id uuid.UUID, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getCoupon(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM coupons WHERE id = $1;`, id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "coupon.delete", "coupon", id, before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// couponForStay looks up code and checks it can be used for the stay in
+// req. Redemption limits are checked again when the booking redeems it.
+func couponForStay(ctx context.Context, db queryer, req models.StayRequest, nights int) (models.Coupon, error) {
+	c, err := scanCoupon(db.QueryRowContext(ctx,
+		`SELECT `+couponColumns+` FROM coupons c WHERE c.code = $1;`, strings.ToUpper(req.CouponCode)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c, &CouponError{Problem: "unknown code"}
+		}
+		return c, err
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case !*c.Active:
+		return c, &CouponError{Problem: "the code is no longer active"}
+	case now.Before(*c.ValidFrom):
+		return c, &CouponError{Problem: "the code is not valid yet"}
+	case c.ValidUntil != nil && !now.Before(*c.ValidUntil):
+		return c, &CouponError{Problem: "the code has expired"}
+	case nights < c.MinNights:
+		return c, &CouponError{Problem: fmt.Sprintf("the code needs a stay of at least %d nights", c.MinNights)}
+	case c.PropertyID != nil && *c.PropertyID != req.PropertyID:
+		return c, &CouponError{Problem: "the code is not valid for this property"}
+	case c.MaxRedemptions != nil && c.RedemptionCount >= *c.MaxRedemptions:
+		return c, &CouponError{Problem: "the code has been fully redeemed"}
+	}
+
+	if c.HostID != nil {
+		var hostID uuid.NullUUID
+		err := db.QueryRowContext(ctx, `SELECT user_id FROM properties WHERE id = $1;`, req.PropertyID).Scan(&hostID)
+		if err != nil {
+			return c, err
+		}
+		if !hostID.Valid || hostID.UUID != *c.HostID {
+			return c, &CouponError{Problem: "the code is not valid for this property"}
+		}
+	}
+
+	if c.MaxRedemptionsPerUser != nil && req.UserID != uuid.Nil {
+		used, err := userRedemptions(ctx, db, c.ID, req.UserID)
+		if err != nil {
+			return c, err
+		}
+		if used >= *c.MaxRedemptionsPerUser {
+			return c, &CouponError{Problem: "you have already used this code"}
+		}
+	}
+
+	return c, nil
+}
+
+func userRedemptions(ctx context.Context, db queryer, couponID, userID uuid.UUID) (int, error) {
+	var used int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = $1 AND user_id = $2;
+	`, couponID, userID).Scan(&used)
+	return used, err
+}
+
+// couponQuote applies the coupon in req, if any, to quote, converting a
+// fixed amount into the quote's currency with rates. A booking being
+// repriced keeps the coupon it redeemed, without its conditions being
+// checked again.
+func couponQuote(ctx context.Context, db queryer, quote models.PriceQuote, req models.StayRequest, rates fx.Provider) (models.PriceQuote, error) {
+	var (
+		c   models.Coupon
+		err error
+	)
+	switch {
+	case req.BookingID != uuid.Nil:
+		c, err = scanCoupon(db.QueryRowContext(ctx, `
+			SELECT `+couponColumns+` FROM coupons c
+			JOIN coupon_redemptions r ON r.coupon_id = c.id
+			WHERE r.booking_id = $1;
+		`, req.BookingID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return quote, nil
+		}
+	case req.CouponCode != "":
+		c, err = couponForStay(ctx, db, req, quote.Nights)
+	default:
+		return quote, nil
+	}
+	if err != nil {
+		return quote, err
+	}
+
+	if c.Amount != nil && c.Amount.Currency != quote.Currency {
+		rate, err := rates.Rate(ctx, c.Amount.Currency, quote.Currency)
+		if err != nil {
+			return quote, fmt.Errorf("coupon %s: %w", c.Code, err)
+		}
+		c.Amount = &money.Money{Amount: rate.Convert(c.Amount.Amount), Currency: quote.Currency}
+	}
+
+	return pricing.ApplyCoupon(quote, c), nil
+}
+
+// redeemCoupon records the use of a quoted coupon by a new booking. Bumping
+// the count locks the coupon row, so concurrent bookings with the same code
+// queue here and the global and per-guest limits cannot be overrun.
+func redeemCoupon(ctx context.Context, tx *sql.Tx, applied models.AppliedCoupon, bookingID, userID uuid.UUID, currency string) error {
+	var maxPerUser sql.NullInt64
+	err := tx.QueryRowContext(ctx, `
+		UPDATE coupons SET redemption_count = redemption_count + 1
+		WHERE id = $1 AND (max_redemptions IS NULL OR redemption_count < max_redemptions)
+		RETURNING max_redemptions_per_user;
+	`, applied.ID).Scan(&maxPerUser)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &CouponError{Problem: "the code has been fully redeemed"}
+		}
+		return err
+	}
+
+	if maxPerUser.Valid {
+		used, err := userRedemptions(ctx, tx, applied.ID, userID)
+		if err != nil {
+			return err
+		}
+		if used >= int(maxPerUser.Int64) {
+			return &CouponError{Problem: "you have already used this code"}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO coupon_redemptions (coupon_id, booking_id, user_id, discount, currency)
+		VALUES ($1, $2, $3, $4, $5);
+	`, applied.ID, bookingID, userID, applied.Discount, currency)
+	return err
+}
+
+// releaseCoupon gives back the coupon redemption of a cancelled booking.
+func releaseCoupon(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `
+		WITH released AS (
+			DELETE FROM coupon_redemptions WHERE booking_id = $1 RETURNING coupon_id
+		)
+		UPDATE coupons SET redemption_count = redemption_count - 1
+		WHERE id IN (SELECT coupon_id FROM released);
+	`, bookingID)
+	return err
+}
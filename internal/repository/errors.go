@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrConflict is returned when a write would violate a uniqueness rule.
+	ErrConflict = errors.New("conflicts with an existing record")
+	// ErrInvalidImageOrder is returned when a reorder does not list exactly
+	// the property's images.
+	ErrInvalidImageOrder = errors.New("image ids must list every image of the property exactly once")
+	// ErrUnknownAmenity is returned when an amenity id does not exist.
+	ErrUnknownAmenity = errors.New("unknown amenity id")
+	// ErrInvalidTransition is returned when a listing action is not allowed
+	// from the listing's current status.
+	ErrInvalidTransition = errors.New("action not allowed in the listing's current status")
+	// ErrReasonRequired is returned when rejecting or suspending a listing
+	// without a reason.
+	ErrReasonRequired = errors.New("a reason is required")
+	// ErrListingUnavailable is returned when booking a listing that is not
+	// published.
+	ErrListingUnavailable = errors.New("listing is not available for booking")
+	// ErrActiveBookings is returned when deleting a property that still has
+	// current or upcoming bookings.
+	ErrActiveBookings = errors.New("property has current or upcoming bookings")
+	// ErrVersionMismatch is returned when an update's If-Match version is no
+	// longer current.
+	ErrVersionMismatch = errors.New("property has been modified since it was read")
+	// ErrDatesUnavailable is returned when a stay or calendar block overlaps
+	// booked or blocked nights.
+	ErrDatesUnavailable = errors.New("dates are not available")
+	// ErrPriceChanged is returned when a booking's expected total no longer
+	// matches the quoted price.
+	ErrPriceChanged = errors.New("price has changed")
+	// ErrUnknownCouponScope is returned when a coupon is limited to a
+	// property or host that does not exist.
+	ErrUnknownCouponScope = errors.New("coupon property or host does not exist")
+	// ErrPaymentState is returned when a payment cannot move to the
+	// requested status from its current one.
+	ErrPaymentState = errors.New("payment cannot change to this status")
+	// ErrBookingReleased is returned when a payment is captured for a
+	// booking that already gave up its dates; the payment must be refunded.
+	ErrBookingReleased = errors.New("booking was released before its payment was captured")
+	// ErrRefundTooLarge is returned when refunds would exceed the captured
+	// amount.
+	ErrRefundTooLarge = errors.New("refund exceeds the amount paid")
+	// ErrNotCancellable is returned when cancelling a booking that is
+	// already cancelled or whose payment failed.
+	ErrNotCancellable = errors.New("booking cannot be cancelled in its current status")
+	// ErrStayCompleted is returned when cancelling a stay that has ended.
+	ErrStayCompleted = errors.New("stay has already ended")
+	// ErrNotModifiable is returned when changing a booking that is not
+	// confirmed or whose listing has been purged.
+	ErrNotModifiable = errors.New("booking cannot be changed in its current status")
+	// ErrNothingToModify is returned when a modification asks for the
+	// booking's current dates and guest count.
+	ErrNothingToModify = errors.New("the booking already has these dates and guests")
+	// ErrModificationPending is returned when requesting a change to a
+	// booking that already has one waiting for the host.
+	ErrModificationPending = errors.New("booking already has a change waiting for approval")
+	// ErrModificationState is returned when accepting or declining a
+	// modification that is no longer pending.
+	ErrModificationState = errors.New("modification is no longer pending")
+	// ErrNotHeld is returned when confirming or releasing a booking that is
+	// not, or no longer, a hold.
+	ErrNotHeld = errors.New("booking is not held")
+	// ErrHoldExpired is returned when confirming a hold that has run out.
+	ErrHoldExpired = errors.New("hold has expired")
+	// ErrTooManyHolds is returned when a hold would take the guest or the
+	// listing over its cap on holds active at once.
+	ErrTooManyHolds = errors.New("too many active holds")
+	// ErrRequestToBook is returned when booking or holding a listing that
+	// takes booking requests.
+	ErrRequestToBook = errors.New("listing takes booking requests")
+	// ErrInstantBook is returned when sending a request for a listing that
+	// is booked instantly.
+	ErrInstantBook = errors.New("listing is booked instantly")
+	// ErrNotRequested is returned when answering a booking that is not, or
+	// no longer, waiting for the host.
+	ErrNotRequested = errors.New("booking is not waiting for the host")
+	// ErrRequestExpired is returned when accepting a request after its
+	// deadline.
+	ErrRequestExpired = errors.New("booking request has expired")
+	// ErrIdempotencyKeyReused is returned when an idempotency key comes back
+	// with a different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+	// ErrIdempotencyInProgress is returned when a request is retried while
+	// the first one with its key is still being handled.
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// StayRuleError explains why a stay breaks the property's minimum/maximum
+// nights or check-in day rules.
+type StayRuleError struct {
+	Problem string
+}
+
+func (e *StayRuleError) Error() string {
+	return "stay not allowed: " + e.Problem
+}
+
+// CouponError explains why a promo code cannot be used for a stay.
+type CouponError struct {
+	Problem string
+}
+
+func (e *CouponError) Error() string {
+	return "coupon not valid: " + e.Problem
+}
+
+// IncompleteListingError lists what must be fixed before a listing can be
+// submitted for review.
+type IncompleteListingError struct {
+	Problems []string
+}
+
+func (e *IncompleteListingError) Error() string {
+	return "listing is incomplete: " + strings.Join(e.Problems, "; ")
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isExclusionViolation reports whether err is a Postgres exclusion_violation,
+// raised by the overlap constraints on bookings and stay rules.
+func isExclusionViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23P01"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres
+// foreign_key_violation.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
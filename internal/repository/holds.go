@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/google/uuid"
+)
+
+// ConfirmHold turns userID's hold id into a booking waiting for payment, at
+// the price it was held at, with a pending payment through provider like
+// CreateBooking's. It fails with ErrNotHeld if the booking is not a hold
+// and ErrHoldExpired once the hold has run out. The returned quote is in
+// the currency the guest was shown.
+func (repo *Repository) ConfirmHold(id, userID uuid.UUID, provider string, paymentTimeout time.Duration, actor models.Actor) (models.Payment, models.PriceQuote, error) {
+	lockQuery := `
+		SELECT b.user_id, b.status, b.hold_expires_at <= NOW(), b.total_price, b.currency, b.price_breakdown,
+			` + bookingDisplayColumns + `
+		FROM bookings b
+		WHERE b.id = $1
+		FOR UPDATE;
+	`
+
+	var (
+		guestID   uuid.UUID
+		status    string
+		expired   sql.NullBool
+		total     money.Amount
+		currency  string
+		breakdown sql.NullString
+		display   bookingDisplayScan
+		quote     models.PriceQuote
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, quote, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, lockQuery, id).Scan(append([]any{
+		&guestID, &status, &expired, &total, &currency, &breakdown,
+	}, display.dests()...)...)
+	if err != nil {
+		return models.Payment{}, quote, err
+	}
+	if guestID != userID {
+		return models.Payment{}, quote, sql.ErrNoRows
+	}
+	if status != models.BookingHeld {
+		return models.Payment{}, quote, ErrNotHeld
+	}
+	if expired.Bool {
+		return models.Payment{}, quote, ErrHoldExpired
+	}
+
+	quote, charge, err := bookedCharge(breakdown, total, currency, display)
+	if err != nil {
+		return models.Payment{}, quote, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings SET status = 'pending_payment', hold_converted_at = NOW() WHERE id = $1;
+	`, id)
+	if err != nil {
+		return models.Payment{}, quote, err
+	}
+
+	payment, err := createPayment(ctx, tx, id, uuid.NullUUID{}, provider, charge, paymentTimeout)
+	if err != nil {
+		return models.Payment{}, quote, err
+	}
+
+	before := map[string]any{"status": status}
+	after := map[string]any{"status": models.BookingPendingPayment}
+	if err := recordAudit(ctx, tx, actor, "booking.hold_confirm", "booking", id, before, after); err != nil {
+		return models.Payment{}, quote, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Payment{}, quote, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return payment, quote, nil
+}
+
+// ReleaseHold lets userID give up their hold id before it expires, freeing
+// its dates and coupon.
+func (repo *Repository) ReleaseHold(id, userID uuid.UUID, actor models.Actor) error {
+	return repo.endHold(id, &userID, models.BookingHoldReleased, actor)
+}
+
+// ExpireHold frees the dates and coupon of a hold that has run out. It
+// fails with ErrNotHeld if the hold was confirmed or released meanwhile.
+func (repo *Repository) ExpireHold(id uuid.UUID, actor models.Actor) error {
+	return repo.endHold(id, nil, models.BookingHoldExpired, actor)
+}
+
+// endHold moves hold id, which must belong to userID when it is set, to
+// status. Only holds that have run out can expire.
+func (repo *Repository) endHold(id uuid.UUID, userID *uuid.UUID, status string, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		guestID  uuid.UUID
+		previous string
+		expired  sql.NullBool
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, status, hold_expires_at <= NOW() FROM bookings WHERE id = $1 FOR UPDATE;
+	`, id).Scan(&guestID, &previous, &expired)
+	if err != nil {
+		return err
+	}
+	if userID != nil && *userID != guestID {
+		return sql.ErrNoRows
+	}
+	if previous != models.BookingHeld || (status == models.BookingHoldExpired && !expired.Bool) {
+		return ErrNotHeld
+	}
+
+	if err := setBookingStatus(ctx, tx, id, status); err != nil {
+		return err
+	}
+	if err := releaseCoupon(ctx, tx, id); err != nil {
+		return err
+	}
+
+	before := map[string]any{"status": previous}
+	after := map[string]any{"status": status}
+	if err := recordAudit(ctx, tx, actor, "booking."+status, "booking", id, before, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DueHoldExpiries returns up to limit holds that have run out, oldest
+// first.
+func (repo *Repository) DueHoldExpiries(limit int) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT id FROM bookings
+		WHERE status = 'held' AND hold_expires_at <= NOW()
+		ORDER BY hold_expires_at
+		LIMIT $1;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// HoldReport counts the holds placed from from up to to by what became of
+// them. A hold counts as converted once it is confirmed, whether or not
+// its payment then went through.
+func (repo *Repository) HoldReport(from, to time.Time) (models.HoldReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	report := models.HoldReport{From: from, To: to}
+	err := repo.db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE hold_converted_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE status = 'hold_expired'),
+			COUNT(*) FILTER (WHERE status = 'hold_released'),
+			COUNT(*) FILTER (WHERE status = 'held'),
+			COALESCE(AVG(EXTRACT(EPOCH FROM hold_converted_at - created_at)), 0)
+		FROM bookings
+		WHERE hold_expires_at IS NOT NULL AND created_at >= $1 AND created_at < $2;
+	`, from, to).Scan(&report.Created, &report.Converted, &report.Expired, &report.Released, &report.Active,
+		&report.AvgSecondsToConvert)
+	if err != nil {
+		return report, err
+	}
+
+	if settled := report.Created - report.Active; settled > 0 {
+		report.ConversionRate = float64(report.Converted) / float64(settled)
+	}
+	return report, nil
+}
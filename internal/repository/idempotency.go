@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+)
+
+// ClaimIdempotencyKey starts handling userID's request with key for ttl.
+// It returns nil when the request should run, or the response stored for
+// an earlier request with the same fingerprint. It fails with
+// ErrIdempotencyKeyReused if the key was used for a different request and
+// ErrIdempotencyInProgress while that request is still running. Expired
+// keys, and claims left without a response for longer than lease by a
+// request that never finished, are claimed afresh.
+func (repo *Repository) ClaimIdempotencyKey(userID uuid.UUID, key, fingerprint string, lease, ttl time.Duration) (*models.StoredResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var claimed bool
+	err := repo.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (user_id, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			content_type = NULL,
+			response_body = NULL,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+			OR (idempotency_keys.status_code IS NULL
+				AND idempotency_keys.created_at <= NOW() - $5 * INTERVAL '1 second')
+		RETURNING true;
+	`, userID, key, fingerprint, int64(ttl.Seconds()), int64(lease.Seconds())).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var (
+		stored      string
+		status      sql.NullInt64
+		contentType sql.NullString
+		res         models.StoredResponse
+	)
+	err = repo.db.QueryRowContext(ctx, `
+		SELECT fingerprint, status_code, content_type, response_body
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2;
+	`, userID, key).Scan(&stored, &status, &contentType, &res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if stored != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !status.Valid {
+		return nil, ErrIdempotencyInProgress
+	}
+
+	res.StatusCode = int(status.Int64)
+	res.ContentType = contentType.String
+	return &res, nil
+}
+
+// SaveIdempotentResponse stores the response to userID's request with key,
+// for retries to get back.
+func (repo *Repository) SaveIdempotentResponse(userID uuid.UUID, key string, res models.StoredResponse) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE user_id = $1 AND key = $2;
+	`, userID, key, res.StatusCode, res.ContentType, res.Body)
+	return err
+}
+
+// ReleaseIdempotencyKey gives up a claimed key without storing a response,
+// so a retry runs the request again.
+func (repo *Repository) ReleaseIdempotencyKey(userID uuid.UUID, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND status_code IS NULL;
+	`, userID, key)
+	return err
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys and returns how many
+// there were.
+func (repo *Repository) PurgeIdempotencyKeys() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := repo.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW();`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ClaimPendingImages marks up to limit images as processing and returns
+// them. Images stuck in processing (e.g. after a crash) are reclaimed after
+// staleAfter.
+func (repo *Repository) ClaimPendingImages(limit int, staleAfter time.Duration) ([]models.PendingImage, error) {
+	query := `
+		UPDATE property_images
+		SET processing_status = 'processing', processing_started_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM property_images
+			WHERE processing_status = 'pending'
+			OR (processing_status = 'processing' AND processing_started_at < NOW() - make_interval(secs => $2))
+			ORDER BY processing_started_at NULLS FIRST
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, property_id, image_url, COALESCE(storage_key, '');
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, query, limit, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []models.PendingImage
+	for rows.Next() {
+		var img models.PendingImage
+		if err := rows.Scan(&img.ImageID, &img.PropertyID, &img.ImageURL, &img.StorageKey); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// SaveImageDerivatives stores the processing result for an image. It
+// returns sql.ErrNoRows if the image was deleted while being processed.
+func (repo *Repository) SaveImageDerivatives(imageID uuid.UUID, width, height int, blurhash string, variants []models.ImageVariant, actor models.Actor) error {
+	updateQuery := `
+		UPDATE property_images
+		SET width = $2, height = $3, blurhash = $4,
+			processing_status = 'ready', processing_error = NULL
+		WHERE id = $1;
+	`
+
+	variantQuery := `
+		INSERT INTO property_image_variants (image_id, name, url, storage_key, width, height)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT (image_id, name) DO UPDATE
+		SET url = EXCLUDED.url, storage_key = EXCLUDED.storage_key,
+			width = EXCLUDED.width, height = EXCLUDED.height;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, updateQuery, imageID, width, height, blurhash)
+	if err != nil {
+		return fmt.Errorf("failed to update image: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	for _, v := range variants {
+		if _, err := tx.ExecContext(ctx, variantQuery, imageID, v.Name, v.URL, v.StorageKey, v.Width, v.Height); err != nil {
+			return fmt.Errorf("failed to save %s variant: %w", v.Name, err)
+		}
+	}
+
+	after := map[string]any{
+		"width":             width,
+		"height":            height,
+		"blurhash":          blurhash,
+		"variants":          variants,
+		"processing_status": "ready",
+	}
+	if err := recordAudit(ctx, tx, actor, "property_image.process", "property_image", imageID, nil, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *Repository) MarkImageFailed(imageID uuid.UUID, reason string, actor models.Actor) error {
+	query := `
+		UPDATE property_images
+		SET processing_status = 'failed', processing_error = $2
+		WHERE id = $1;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, imageID, reason); err != nil {
+		return err
+	}
+
+	after := map[string]any{"processing_status": "failed", "processing_error": reason}
+	if err := recordAudit(ctx, tx, actor, "property_image.process_failed", "property_image", imageID, nil, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lockImageOrder locks the property's images and returns their ids in
+// display order.
+func lockImageOrder(ctx context.Context, tx *sql.Tx, propertyID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT id
+		FROM property_images
+		WHERE property_id = $1
+		ORDER BY display_order ASC
+		FOR UPDATE;
+	`
+
+	rows, err := tx.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// writeImageOrder sets display_order to each image's index in ids.
+func writeImageOrder(ctx context.Context, tx *sql.Tx, propertyID uuid.UUID, ids []uuid.UUID) error {
+	query := `
+		UPDATE property_images pi
+		SET display_order = ordered.position - 1
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS ordered(id, position)
+		WHERE pi.id = ordered.id AND pi.property_id = $1;
+	`
+
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = id.String()
+	}
+
+	if _, err := tx.ExecContext(ctx, query, propertyID, pq.StringArray(list)); err != nil {
+		return fmt.Errorf("failed to update display order: %w", err)
+	}
+
+	return nil
+}
+
+// ReorderPropertyImages atomically rewrites display_order so images appear
+// in the order of ids, which must list every image of the property once.
+func (repo *Repository) ReorderPropertyImages(propertyID uuid.UUID, ids []uuid.UUID, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockImageOrder(ctx, tx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	if len(current) != len(ids) {
+		return ErrInvalidImageOrder
+	}
+
+	existing := make(map[uuid.UUID]bool, len(current))
+	for _, id := range current {
+		existing[id] = true
+	}
+	for _, id := range ids {
+		if !existing[id] {
+			return ErrInvalidImageOrder
+		}
+		delete(existing, id) // catches duplicates in ids
+	}
+
+	if err := writeImageOrder(ctx, tx, propertyID, ids); err != nil {
+		return err
+	}
+
+	before := map[string]any{"image_order": current}
+	after := map[string]any{"image_order": ids}
+	if err := recordAudit(ctx, tx, actor, "property_image.reorder", "property", propertyID, before, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePropertyImage changes an image's caption and, when cover is set,
+// moves it to the front so it becomes the listing's cover photo.
+func (repo *Repository) UpdatePropertyImage(propertyID, imageID uuid.UUID, update models.UpdateImageRequest, actor models.Actor) error {
+	selectQuery := `
+		SELECT caption, display_order
+		FROM property_images
+		WHERE id = $1 AND property_id = $2;
+	`
+
+	captionQuery := `
+		UPDATE property_images
+		SET caption = $2
+		WHERE id = $1;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	order, err := lockImageOrder(ctx, tx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	var before struct {
+		Caption      string `json:"caption"`
+		DisplayOrder int    `json:"display_order"`
+	}
+	if err := tx.QueryRowContext(ctx, selectQuery, imageID, propertyID).Scan(&before.Caption, &before.DisplayOrder); err != nil {
+		return err
+	}
+	after := before
+
+	if update.Caption != nil {
+		if _, err := tx.ExecContext(ctx, captionQuery, imageID, *update.Caption); err != nil {
+			return fmt.Errorf("failed to update caption: %w", err)
+		}
+		after.Caption = *update.Caption
+	}
+
+	if update.Cover {
+		reordered := []uuid.UUID{imageID}
+		for _, id := range order {
+			if id != imageID {
+				reordered = append(reordered, id)
+			}
+		}
+
+		if err := writeImageOrder(ctx, tx, propertyID, reordered); err != nil {
+			return err
+		}
+		after.DisplayOrder = 0
+	}
+
+	if err := recordAudit(ctx, tx, actor, "property_image.update", "property_image", imageID, before, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+)
+
+// Completeness requirements checked when a listing is submitted for review.
+const (
+	minListingImages     = 3
+	minDescriptionLength = 100
+	moderationQueueLimit = 100
+)
+
+type listingTransition struct {
+	from        []string
+	to          string
+	needsReason bool
+}
+
+// listingTransitions maps each listing action to the statuses it may be
+// taken from and the status it leads to.
+var listingTransitions = map[string]listingTransition{
+	"submit":    {from: []string{models.ListingDraft}, to: models.ListingPendingReview},
+	"approve":   {from: []string{models.ListingPendingReview}, to: models.ListingPublished},
+	"reject":    {from: []string{models.ListingPendingReview}, to: models.ListingDraft, needsReason: true},
+	"suspend":   {from: []string{models.ListingPublished}, to: models.ListingSuspended, needsReason: true},
+	"reinstate": {from: []string{models.ListingSuspended}, to: models.ListingPublished},
+	"archive": {
+		from: []string{models.ListingDraft, models.ListingPendingReview, models.ListingPublished, models.ListingSuspended},
+		to:   models.ListingArchived,
+	},
+	"unarchive": {from: []string{models.ListingArchived}, to: models.ListingDraft},
+}
+
+// TransitionListing applies a workflow action (submit, approve, reject,
+// suspend, reinstate, archive, unarchive) and returns the new status.
+// Submitting runs the completeness checks and may return an
+// *IncompleteListingError.
+func (repo *Repository) TransitionListing(id uuid.UUID, action, reason string, actor models.Actor) (string, error) {
+	updateQuery := `
+		UPDATE properties
+		SET status = $2,
+			review_note = NULLIF($3, ''),
+			submitted_at = CASE WHEN $2 = 'pending_review' THEN NOW() ELSE submitted_at END,
+			reviewed_at = CASE WHEN $4 THEN NOW() ELSE reviewed_at END,
+			reviewed_by = CASE WHEN $4 THEN $5 ELSE reviewed_by END,
+			version = version + 1, updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	transition, ok := listingTransitions[action]
+	if !ok {
+		return "", fmt.Errorf("unknown listing action %q", action)
+	}
+
+	if transition.needsReason && reason == "" {
+		return "", ErrReasonRequired
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(transition.from, before.Status) {
+		return "", ErrInvalidTransition
+	}
+
+	if action == "submit" {
+		problems, err := listingProblems(ctx, tx, before)
+		if err != nil {
+			return "", err
+		}
+		if len(problems) > 0 {
+			return "", &IncompleteListingError{Problems: problems}
+		}
+	}
+
+	reviewed := action != "submit" && action != "archive" && action != "unarchive"
+	reviewer := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+
+	if _, err := tx.ExecContext(ctx, updateQuery, id, transition.to, reason, reviewed, reviewer); err != nil {
+		return "", fmt.Errorf("failed to update listing status: %w", err)
+	}
+
+	after, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "property."+action, "property", id, before, after); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transition.to, nil
+}
+
+// listingProblems returns the reasons a listing is not ready for review.
+func listingProblems(ctx context.Context, tx *sql.Tx, property *models.Property) ([]string, error) {
+	var images int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM property_images WHERE property_id = $1;`, property.ID).Scan(&images)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+
+	if images < minListingImages {
+		problems = append(problems, fmt.Sprintf("at least %d images are required, found %d", minListingImages, images))
+	}
+	if len([]rune(property.Description)) < minDescriptionLength {
+		problems = append(problems, fmt.Sprintf("description must be at least %d characters", minDescriptionLength))
+	}
+	if property.Title == "" {
+		problems = append(problems, "title is required")
+	}
+	if property.Location == "" {
+		problems = append(problems, "location is required")
+	}
+	if property.PricePerNight <= 0 {
+		problems = append(problems, "price_per_night must be greater than zero")
+	}
+	if property.MaxGuests <= 0 {
+		problems = append(problems, "max_guests must be at least 1")
+	}
+
+	return problems, nil
+}
+
+// GetModerationQueue returns listings awaiting review, oldest submission
+// first.
+func (repo *Repository) GetModerationQueue() ([]models.ModerationItem, error) {
+	query := `
+		SELECT p.id, p.title, p.location, p.description, p.user_id,
+			(SELECT COUNT(*) FROM property_images pi WHERE pi.property_id = p.id),
+			p.submitted_at
+		FROM properties p
+		WHERE p.status = 'pending_review' AND p.deleted_at IS NULL
+		ORDER BY p.submitted_at ASC
+		LIMIT $1;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, query, moderationQueueLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ModerationItem{}
+	for rows.Next() {
+		var item models.ModerationItem
+		if err := rows.Scan(&item.ID, &item.Title, &item.Location, &item.Description, &item.HostID, &item.ImageCount, &item.SubmittedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,451 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
+	"github.com/google/uuid"
+)
+
+// modificationColumns selects a modification from booking_modifications
+// aliased bm, in the order of scanModification.
+const modificationColumns = `
+	bm.id, bm.booking_id, bm.requested_by, bm.status, bm.requires_approval,
+	bm.old_start_date, bm.old_end_date, bm.old_guests, bm.new_start_date, bm.new_end_date, bm.new_guests,
+	bm.old_adults, bm.old_children, bm.old_infants, bm.old_pets,
+	bm.new_adults, bm.new_children, bm.new_infants, bm.new_pets, bm.old_total, bm.new_total, bm.price_delta, bm.currency, bm.payment_delta, bm.payment_currency,
+	bm.price_breakdown, bm.payment_method, bm.decided_by, bm.decision_reason, bm.decided_at, bm.created_at`
+
+func scanModification(row interface{ Scan(...any) error }) (models.BookingModification, error) {
+	var (
+		m                      models.BookingModification
+		requestedBy, decidedBy uuid.NullUUID
+		reason                 sql.NullString
+		decidedAt              sql.NullTime
+		breakdown              string
+	)
+
+	err := row.Scan(&m.ID, &m.BookingID, &requestedBy, &m.Status, &m.RequiresApproval,
+		&m.OldStartDate, &m.OldEndDate, &m.OldGuests, &m.NewStartDate, &m.NewEndDate, &m.NewGuests,
+		&m.OldParty.Adults, &m.OldParty.Children, &m.OldParty.Infants, &m.OldParty.Pets,
+		&m.NewParty.Adults, &m.NewParty.Children, &m.NewParty.Infants, &m.NewParty.Pets, &m.OldTotal, &m.NewTotal, &m.PriceDelta, &m.Currency, &m.PaymentDelta, &m.PaymentCurrency,
+		&breakdown, &m.PaymentMethod, &decidedBy, &reason, &decidedAt, &m.CreatedAt)
+	if err != nil {
+		return m, err
+	}
+
+	if err := json.Unmarshal([]byte(breakdown), &m.Quote); err != nil {
+		return m, err
+	}
+	if requestedBy.Valid {
+		m.RequestedBy = &requestedBy.UUID
+	}
+	if decidedBy.Valid {
+		m.DecidedBy = &decidedBy.UUID
+	}
+	if reason.Valid {
+		m.DecisionReason = &reason.String
+	}
+	if decidedAt.Valid {
+		m.DecidedAt = &decidedAt.Time
+	}
+	return m, nil
+}
+
+func getModification(ctx context.Context, db queryer, id uuid.UUID, lock bool) (models.BookingModification, error) {
+	query := `SELECT ` + modificationColumns + ` FROM booking_modifications bm WHERE bm.id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	return scanModification(db.QueryRowContext(ctx, query, id))
+}
+
+// GetModification returns a modification of booking bookingID.
+func (repo *Repository) GetModification(bookingID, id uuid.UUID) (models.BookingModification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanModification(repo.db.QueryRowContext(ctx, `
+		SELECT `+modificationColumns+` FROM booking_modifications bm WHERE bm.id = $1 AND bm.booking_id = $2;
+	`, id, bookingID))
+}
+
+// GetBookingModifications lists the changes requested to a booking, oldest
+// first.
+func (repo *Repository) GetBookingModifications(bookingID uuid.UUID) ([]models.BookingModification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+modificationColumns+` FROM booking_modifications bm
+		WHERE bm.booking_id = $1
+		ORDER BY bm.created_at;
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mods := []models.BookingModification{}
+	for rows.Next() {
+		m, err := scanModification(rows)
+		if err != nil {
+			return mods, err
+		}
+		mods = append(mods, m)
+	}
+
+	return mods, rows.Err()
+}
+
+// BookingGuestID returns the guest who made a booking.
+func (repo *Repository) BookingGuestID(id uuid.UUID) (uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var guestID uuid.UUID
+	err := repo.db.QueryRowContext(ctx, `SELECT user_id FROM bookings WHERE id = $1;`, id).Scan(&guestID)
+	return guestID, err
+}
+
+// RequestModification asks to move confirmed booking req.BookingID, which
+// must belong to req.UserID, to the dates and party in req. The new
+// stay must be bookable with the booking's own nights left out, and is
+// priced now, keeping the booking's coupon; a booking paid in another
+// currency is converted at the rate it was booked at. Check-in cannot move
+// once the stay has begun, and a stay that is over cannot change
+// (ErrStayCompleted).
+//
+// A change that stays within the booked nights and adds no one is left
+// pending for the caller to apply at once; anything else needs the host's
+// approval. paymentMethod is charged for a price increase when the change
+// is applied.
+func (repo *Repository) RequestModification(req models.StayRequest, paymentMethod string, rates fx.Provider, now time.Time, actor models.Actor) (models.BookingModification, error) {
+	lockQuery := `
+		SELECT b.user_id, b.property_id, b.status, b.start_date, b.end_date, b.guests,
+			b.adults, b.children, b.infants, b.pets, b.total_price, b.currency,
+			` + bookingDisplayColumns + `,
+			b.start_date + COALESCE(p.check_in_time, '15:00'::time),
+			b.end_date + COALESCE(p.check_out_time, '11:00'::time)
+		FROM bookings b
+		LEFT JOIN properties p ON p.id = b.property_id
+		WHERE b.id = $1
+		FOR UPDATE OF b;
+	`
+
+	var (
+		m                 = models.BookingModification{BookingID: req.BookingID, Status: models.ModificationPending}
+		guestID           uuid.UUID
+		propertyID        uuid.NullUUID
+		status            string
+		display           bookingDisplayScan
+		checkIn, checkOut time.Time
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return m, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, lockQuery, req.BookingID).Scan(append([]any{
+		&guestID, &propertyID, &status, &m.OldStartDate, &m.OldEndDate, &m.OldGuests,
+		&m.OldParty.Adults, &m.OldParty.Children, &m.OldParty.Infants, &m.OldParty.Pets, &m.OldTotal, &m.Currency,
+	}, append(display.dests(), &checkIn, &checkOut)...)...)
+	if err != nil {
+		return m, err
+	}
+	if guestID != req.UserID {
+		return m, sql.ErrNoRows
+	}
+	if status != models.BookingConfirmed || !propertyID.Valid {
+		return m, ErrNotModifiable
+	}
+	if !now.Before(checkOut) {
+		return m, ErrStayCompleted
+	}
+	if req.StartDate.Equal(m.OldStartDate) && req.EndDate.Equal(m.OldEndDate) && req.Party == m.OldParty {
+		return m, ErrNothingToModify
+	}
+	if !now.Before(checkIn) && !req.StartDate.Equal(m.OldStartDate) {
+		return m, &StayRuleError{Problem: "check-in cannot be moved once the stay has begun"}
+	}
+
+	req.PropertyID = propertyID.UUID
+	quote, err := quoteStay(ctx, tx, req, rates)
+	if err != nil {
+		return m, err
+	}
+
+	m.NewStartDate, m.NewEndDate, m.NewGuests, m.NewParty = req.StartDate, req.EndDate, req.Party.Guests(), req.Party
+	m.NewTotal = quote.Total
+	m.PriceDelta = quote.Total - m.OldTotal
+	m.PaymentDelta, m.PaymentCurrency = m.PriceDelta, m.Currency
+	m.Quote = quote
+	m.PaymentMethod = paymentMethod
+	m.RequiresApproval = req.StartDate.Before(m.OldStartDate) || req.EndDate.After(m.OldEndDate) ||
+		m.NewGuests > m.OldGuests || m.NewParty.Infants > m.OldParty.Infants || m.NewParty.Pets > m.OldParty.Pets
+
+	booked, err := display.value(m.Currency)
+	if err != nil {
+		return m, err
+	}
+	if booked.DisplayTotal != nil {
+		shown := pricing.Convert(quote, *booked.ExchangeRate)
+		m.PaymentDelta, m.PaymentCurrency = shown.Total-booked.DisplayTotal.Amount, booked.DisplayTotal.Currency
+	}
+
+	breakdown, err := json.Marshal(quote)
+	if err != nil {
+		return m, err
+	}
+
+	requestedBy := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO booking_modifications (booking_id, requested_by, requires_approval,
+			old_start_date, old_end_date, old_guests, new_start_date, new_end_date, new_guests,
+			old_total, new_total, price_delta, currency, payment_delta, payment_currency,
+			price_breakdown, payment_method,
+			old_adults, old_children, old_infants, old_pets, new_adults, new_children, new_infants, new_pets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+			$18, $19, $20, $21, $22, $23, $24, $25)
+		RETURNING id, created_at;
+	`, m.BookingID, requestedBy, m.RequiresApproval,
+		m.OldStartDate, m.OldEndDate, m.OldGuests, m.NewStartDate, m.NewEndDate, m.NewGuests,
+		m.OldTotal, m.NewTotal, m.PriceDelta, m.Currency, m.PaymentDelta, m.PaymentCurrency,
+		string(breakdown), paymentMethod,
+		m.OldParty.Adults, m.OldParty.Children, m.OldParty.Infants, m.OldParty.Pets,
+		m.NewParty.Adults, m.NewParty.Children, m.NewParty.Infants, m.NewParty.Pets).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return m, ErrModificationPending
+		}
+		return m, err
+	}
+	if requestedBy.Valid {
+		m.RequestedBy = &requestedBy.UUID
+	}
+
+	if err := recordAudit(ctx, tx, actor, "booking_modification.request", "booking_modification", m.ID, nil, m); err != nil {
+		return m, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return m, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return m, nil
+}
+
+// CreateModificationPayment opens a pending payment through provider for a
+// modification's price increase.
+func (repo *Repository) CreateModificationPayment(m models.BookingModification, provider string, timeout time.Duration) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := createPayment(ctx, tx, m.BookingID, uuid.NullUUID{UUID: m.ID, Valid: true}, provider,
+		money.Money{Amount: m.PaymentDelta, Currency: m.PaymentCurrency}, timeout)
+	if err != nil {
+		return payment, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return payment, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return payment, nil
+}
+
+// ApplyModification moves a booking to the stay and price of its pending
+// modification id. The nights are checked again, since others may have
+// been booked while the change waited for approval. Paying for a price
+// increase and refunding a decrease are left to the caller.
+func (repo *Repository) ApplyModification(id uuid.UUID, actor models.Actor) (models.BookingModification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.BookingModification{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	m, err := getModification(ctx, tx, id, true)
+	if err != nil {
+		return m, err
+	}
+	if m.Status != models.ModificationPending {
+		return m, ErrModificationState
+	}
+
+	var (
+		status     string
+		propertyID uuid.NullUUID
+		display    bookingDisplayScan
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT b.status, b.property_id, `+bookingDisplayColumns+` FROM bookings b WHERE b.id = $1 FOR UPDATE;
+	`, m.BookingID).Scan(append([]any{&status, &propertyID}, display.dests()...)...)
+	if err != nil {
+		return m, err
+	}
+	if status != models.BookingConfirmed || !propertyID.Valid {
+		return m, ErrNotModifiable
+	}
+
+	if err := lockPropertyCalendar(ctx, tx, propertyID.UUID); err != nil {
+		return m, err
+	}
+	if err := checkStay(ctx, tx, propertyID.UUID, m.NewStartDate, m.NewEndDate, m.BookingID); err != nil {
+		return m, err
+	}
+
+	booked, err := display.value(m.Currency)
+	if err != nil {
+		return m, err
+	}
+	var displayTotal any
+	if booked.DisplayTotal != nil {
+		displayTotal = booked.DisplayTotal.Amount + m.PaymentDelta
+	}
+
+	var couponDiscount money.Amount
+	if m.Quote.Coupon != nil {
+		couponDiscount = m.Quote.Coupon.Discount
+	}
+
+	breakdown, err := json.Marshal(m.Quote)
+	if err != nil {
+		return m, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings SET
+			start_date = $2, end_date = $3, guests = $4, total_price = $5, tax_total = $6,
+			price_breakdown = $7, display_total = COALESCE($8, display_total), coupon_discount = $9,
+			adults = $10, children = $11, infants = $12, pets = $13
+		WHERE id = $1;
+	`, m.BookingID, m.NewStartDate, m.NewEndDate, m.NewGuests, m.NewTotal, m.Quote.Tax,
+		string(breakdown), displayTotal, couponDiscount,
+		m.NewParty.Adults, m.NewParty.Children, m.NewParty.Infants, m.NewParty.Pets)
+	if err != nil {
+		if isExclusionViolation(err) {
+			return m, ErrDatesUnavailable
+		}
+		return m, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM booking_taxes WHERE booking_id = $1;`, m.BookingID); err != nil {
+		return m, err
+	}
+	if err := recordBookingTaxes(ctx, tx, m.BookingID, m.Quote.Taxes); err != nil {
+		return m, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE coupon_redemptions SET discount = $2 WHERE booking_id = $1;`, m.BookingID, couponDiscount)
+	if err != nil {
+		return m, err
+	}
+
+	after, err := decideModification(ctx, tx, m, models.ModificationApplied, "", actor)
+	if err != nil {
+		return m, err
+	}
+
+	before := map[string]any{
+		"start_date":  m.OldStartDate.Format(dateLayout),
+		"end_date":    m.OldEndDate.Format(dateLayout),
+		"party":       m.OldParty,
+		"total_price": m.OldTotal,
+	}
+	changed := map[string]any{
+		"start_date":   m.NewStartDate.Format(dateLayout),
+		"end_date":     m.NewEndDate.Format(dateLayout),
+		"party":        m.NewParty,
+		"total_price":  m.NewTotal,
+		"modification": m.ID,
+	}
+	if err := recordAudit(ctx, tx, actor, "booking.modify", "booking", m.BookingID, before, changed); err != nil {
+		return m, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return m, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after, nil
+}
+
+// CloseModification settles a pending modification without applying it:
+// status is ModificationDeclined when the host turns it down, or
+// ModificationPaymentFailed when its price increase could not be
+// collected.
+func (repo *Repository) CloseModification(id uuid.UUID, status, reason string, actor models.Actor) (models.BookingModification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.BookingModification{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	m, err := getModification(ctx, tx, id, true)
+	if err != nil {
+		return m, err
+	}
+	if m.Status != models.ModificationPending {
+		return m, ErrModificationState
+	}
+
+	after, err := decideModification(ctx, tx, m, status, reason, actor)
+	if err != nil {
+		return m, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return m, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after, nil
+}
+
+// decideModification moves a pending modification to its final status and
+// audits it.
+func decideModification(ctx context.Context, tx *sql.Tx, m models.BookingModification, status, reason string, actor models.Actor) (models.BookingModification, error) {
+	decidedBy := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE booking_modifications
+		SET status = $2, decided_by = $3, decision_reason = NULLIF($4, ''), decided_at = NOW()
+		WHERE id = $1;
+	`, m.ID, status, decidedBy, reason)
+	if err != nil {
+		return m, err
+	}
+
+	after, err := getModification(ctx, tx, m.ID, false)
+	if err != nil {
+		return m, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "booking_modification."+status, "booking_modification", m.ID, m, after); err != nil {
+		return m, err
+	}
+	return after, nil
+}
@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/google/uuid"
+)
+
+const (
+	// refundRetryDelay leaves a refund due to the request that cancelled
+	// the booking before the background retries may pick it up.
+	refundRetryDelay = 5 * time.Minute
+	// refundRetryMax caps the wait between attempts at a refund the gateway
+	// keeps turning down.
+	refundRetryMax = 6 * time.Hour
+)
+
+// paymentColumns selects a payment from payments aliased pm, in the order of
+// scanPayment.
+const paymentColumns = `
+	pm.id, pm.booking_id, pm.provider, pm.provider_ref, pm.status, pm.amount, pm.currency,
+	pm.refunded_amount, pm.failure_reason, pm.modification_id, pm.expires_at, pm.created_at, pm.updated_at,
+	pm.refund_due, pm.refund_reason, pm.refund_error`
+
+func scanPayment(row interface{ Scan(...any) error }) (models.Payment, error) {
+	var (
+		p                        models.Payment
+		ref, reason, refundError sql.NullString
+		modificationID           uuid.NullUUID
+	)
+
+	err := row.Scan(&p.ID, &p.BookingID, &p.Provider, &ref, &p.Status, &p.Amount, &p.Currency,
+		&p.RefundedAmount, &reason, &modificationID, &p.ExpiresAt, &p.CreatedAt, &p.UpdatedAt,
+		&p.RefundDue, &p.RefundReason, &refundError)
+	if err != nil {
+		return p, err
+	}
+
+	if ref.Valid {
+		p.ProviderRef = &ref.String
+	}
+	if reason.Valid {
+		p.FailureReason = &reason.String
+	}
+	if modificationID.Valid {
+		p.ModificationID = &modificationID.UUID
+	}
+	if p.RefundDue > 0 {
+		p.RefundStatus = models.RefundPending
+		if refundError.Valid {
+			p.RefundStatus = models.RefundFailed
+			p.RefundError = &refundError.String
+		}
+	}
+	return p, nil
+}
+
+// paymentTransitions lists the statuses a payment can move to from each
+// status. A pending payment may stay pending to record the gateway's
+// reference.
+var paymentTransitions = map[string][]string{
+	models.PaymentPending:    {models.PaymentPending, models.PaymentAuthorized, models.PaymentCaptured, models.PaymentFailed, models.PaymentExpired},
+	models.PaymentAuthorized: {models.PaymentCaptured, models.PaymentFailed, models.PaymentExpired},
+	// a late capture of a payment that already timed out is still recorded,
+	// so it can be refunded
+	models.PaymentExpired: {models.PaymentCaptured},
+	models.PaymentFailed:  {models.PaymentCaptured},
+}
+
+// createPayment opens a pending payment for a new booking, or for the price
+// increase of modificationID when it is valid.
+func createPayment(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID, modificationID uuid.NullUUID, provider string, amount money.Money, timeout time.Duration) (models.Payment, error) {
+	var id uuid.UUID
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO payments (booking_id, modification_id, provider, amount, currency, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW() + $6 * INTERVAL '1 second')
+		RETURNING id;
+	`, bookingID, modificationID, provider, amount.Amount, amount.Currency, int64(timeout/time.Second)).Scan(&id)
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	return getPayment(ctx, tx, id, false)
+}
+
+func getPayment(ctx context.Context, db queryer, id uuid.UUID, lock bool) (models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments pm WHERE pm.id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	return scanPayment(db.QueryRowContext(ctx, query, id))
+}
+
+// GetPaymentByRef finds a payment by the gateway's reference for it.
+func (repo *Repository) GetPaymentByRef(provider, ref string) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanPayment(repo.db.QueryRowContext(ctx, `
+		SELECT `+paymentColumns+` FROM payments pm WHERE pm.provider = $1 AND pm.provider_ref = $2;
+	`, provider, ref))
+}
+
+func bookingPayments(ctx context.Context, db queryer, bookingID uuid.UUID) ([]models.Payment, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+paymentColumns+` FROM payments pm WHERE pm.booking_id = $1 ORDER BY pm.created_at;
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []models.Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return payments, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+// GetBookingPayments lists the payments made for a booking, oldest first.
+func (repo *Repository) GetBookingPayments(bookingID uuid.UUID) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return bookingPayments(ctx, repo.db, bookingID)
+}
+
+// UpdatePaymentStatus records the gateway's answer for a payment: ref, when
+// set, is the gateway's reference and reason why it failed. A captured
+// payment confirms its booking; a failed or expired one releases the
+// booking's nights and coupon. When a payment is captured after its booking
+// was released or cancelled, the capture is recorded and ErrBookingReleased
+// is returned so the caller can refund it. Payments for a modification's
+// price increase leave the booking alone, and are released the same way
+// once the modification has fallen through. Transitions the payment's
+// current status does not allow fail with ErrPaymentState.
+func (repo *Repository) UpdatePaymentStatus(id uuid.UUID, status, ref, reason string, actor models.Actor) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getPayment(ctx, tx, id, true)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if !slices.Contains(paymentTransitions[before.Status], status) {
+		return before, ErrPaymentState
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments SET
+			status = $2,
+			provider_ref = COALESCE(NULLIF($3, ''), provider_ref),
+			failure_reason = NULLIF($4, ''),
+			updated_at = NOW()
+		WHERE id = $1;
+	`, id, status, ref, reason)
+	if err != nil {
+		return before, err
+	}
+
+	released := false
+	if before.ModificationID != nil {
+		if status == models.PaymentCaptured {
+			var modStatus string
+			err := tx.QueryRowContext(ctx, `
+				SELECT status FROM booking_modifications WHERE id = $1 FOR UPDATE;
+			`, *before.ModificationID).Scan(&modStatus)
+			if err != nil {
+				return before, err
+			}
+			released = modStatus != models.ModificationPending && modStatus != models.ModificationApplied
+		}
+	} else if released, err = updateBookingForPayment(ctx, tx, before.BookingID, status); err != nil {
+		return before, err
+	}
+
+	after, err := getPayment(ctx, tx, id, false)
+	if err != nil {
+		return before, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "payment."+status, "payment", id, before, after); err != nil {
+		return before, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return before, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if released {
+		return after, ErrBookingReleased
+	}
+	return after, nil
+}
+
+// updateBookingForPayment confirms or releases a booking waiting for its
+// payment as the payment moves to status, and reports whether a capture
+// came after the booking had already given up its dates.
+func updateBookingForPayment(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID, status string) (bool, error) {
+	var bookingStatus string
+	err := tx.QueryRowContext(ctx, `SELECT status FROM bookings WHERE id = $1 FOR UPDATE;`, bookingID).Scan(&bookingStatus)
+	if err != nil {
+		return false, err
+	}
+
+	if bookingStatus != models.BookingPendingPayment {
+		return status == models.PaymentCaptured, nil
+	}
+
+	switch status {
+	case models.PaymentCaptured:
+		err = setBookingStatus(ctx, tx, bookingID, models.BookingConfirmed)
+	case models.PaymentFailed, models.PaymentExpired:
+		err = setBookingStatus(ctx, tx, bookingID, models.BookingPaymentFailed)
+		if err == nil {
+			err = releaseCoupon(ctx, tx, bookingID)
+		}
+	}
+	return false, err
+}
+
+func setBookingStatus(ctx context.Context, tx *sql.Tx, id uuid.UUID, status string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE bookings SET status = $2 WHERE id = $1;`, id, status)
+	return err
+}
+
+// RecordRefund records a refund the gateway has accepted for a captured
+// payment, settling as much of the refund due on it. It fails with
+// ErrRefundTooLarge if more than the captured amount would be refunded.
+func (repo *Repository) RecordRefund(id uuid.UUID, amount money.Amount, ref, reason string, actor models.Actor) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getPayment(ctx, tx, id, true)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if before.Status != models.PaymentCaptured && before.Status != models.PaymentPartiallyRefunded {
+		return before, ErrPaymentState
+	}
+	if amount <= 0 || before.RefundedAmount+amount > before.Amount {
+		return before, ErrRefundTooLarge
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO payment_refunds (payment_id, provider_ref, amount, reason)
+		VALUES ($1, $2, $3, $4);
+	`, id, ref, amount, reason)
+	if err != nil {
+		return before, err
+	}
+
+	status := models.PaymentPartiallyRefunded
+	if before.RefundedAmount+amount == before.Amount {
+		status = models.PaymentRefunded
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments SET
+			refunded_amount = refunded_amount + $2,
+			status = $3,
+			refund_due = GREATEST(refund_due - $2, 0),
+			refund_error = CASE WHEN refund_due > $2 THEN refund_error END,
+			refund_retry_at = CASE WHEN refund_due > $2 THEN refund_retry_at END,
+			updated_at = NOW()
+		WHERE id = $1;
+	`, id, amount, status)
+	if err != nil {
+		return before, err
+	}
+
+	after, err := getPayment(ctx, tx, id, false)
+	if err != nil {
+		return before, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "payment.refund", "payment", id, before, after); err != nil {
+		return before, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return before, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after, nil
+}
+
+// RecordRefundFailure records that the gateway turned down the refund due
+// on a payment, and puts off the next attempt by twice as long each time,
+// up to refundRetryMax.
+func (repo *Repository) RecordRefundFailure(id uuid.UUID, failure string, actor models.Actor) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getPayment(ctx, tx, id, true)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if before.RefundDue <= 0 {
+		return before, ErrPaymentState
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments SET
+			refund_error = $2,
+			refund_attempts = refund_attempts + 1,
+			refund_retry_at = NOW() + LEAST(POWER(2, refund_attempts) * 60, $3) * INTERVAL '1 second',
+			updated_at = NOW()
+		WHERE id = $1;
+	`, id, failure, int64(refundRetryMax/time.Second))
+	if err != nil {
+		return before, err
+	}
+
+	after, err := getPayment(ctx, tx, id, false)
+	if err != nil {
+		return before, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "payment.refund_failed", "payment", id, before, after); err != nil {
+		return before, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return before, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after, nil
+}
+
+// DueRefunds returns up to limit captured payments with a refund due whose
+// next attempt is due, oldest first.
+func (repo *Repository) DueRefunds(limit int) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+paymentColumns+` FROM payments pm
+		WHERE pm.refund_due > 0 AND pm.refund_retry_at <= NOW()
+			AND pm.status IN ('captured', 'partially_refunded')
+		ORDER BY pm.refund_retry_at
+		LIMIT $1;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return payments, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+// DuePaymentExpiries returns up to limit open payments that were not
+// captured in time, oldest first.
+func (repo *Repository) DuePaymentExpiries(limit int) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+paymentColumns+` FROM payments pm
+		WHERE pm.status IN ('pending', 'authorized') AND pm.expires_at <= NOW()
+		ORDER BY pm.expires_at
+		LIMIT $1;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return payments, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+// RecordPaymentEvent stores a webhook delivery and reports whether it is
+// new; gateways retry deliveries, and repeats should be acknowledged but
+// not acted on again.
+func (repo *Repository) RecordPaymentEvent(provider, eventID, eventType string, payload []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := repo.db.ExecContext(ctx, `
+		INSERT INTO payment_events (provider, event_id, type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING;
+	`, provider, eventID, eventType, string(payload))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ForgetPaymentEvent removes a recorded webhook delivery that could not be
+// applied, so the gateway's retry is.
+func (repo *Repository) ForgetPaymentEvent(provider, eventID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.db.ExecContext(ctx, `DELETE FROM payment_events WHERE provider = $1 AND event_id = $2;`, provider, eventID)
+	return err
+}
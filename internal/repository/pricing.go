@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// loadPricingRules reads the pricing rules of each property in ids that
+// exists and is not deleted.
+func loadPricingRules(ctx context.Context, db queryer, ids []uuid.UUID) (map[uuid.UUID]models.PricingRules, error) {
+	all := make(map[uuid.UUID]models.PricingRules, len(ids))
+	if len(ids) == 0 {
+		return all, nil
+	}
+
+	keys := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, currency, price_per_night, cleaning_fee, extra_guest_fee, guests_included,
+			weekly_discount_percent, monthly_discount_percent, max_guests
+		FROM properties
+		WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL;
+	`, keys)
+	if err != nil {
+		return all, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id    uuid.UUID
+			rules = models.PricingRules{Rates: []models.RateRule{}}
+		)
+		err := rows.Scan(&id, &rules.Currency, &rules.BasePrice, &rules.CleaningFee, &rules.ExtraGuestFee, &rules.GuestsIncluded,
+			&rules.WeeklyDiscountPercent, &rules.MonthlyDiscountPercent, &rules.MaxGuests)
+		if err != nil {
+			return all, err
+		}
+		all[id] = rules
+	}
+	if err := rows.Err(); err != nil {
+		return all, err
+	}
+
+	rateRows, err := db.QueryContext(ctx, `
+		SELECT property_id, name,
+			COALESCE(to_char(start_date, 'YYYY-MM-DD'), ''), COALESCE(to_char(end_date, 'YYYY-MM-DD'), ''),
+			weekdays, price_per_night
+		FROM property_rate_rules
+		WHERE property_id = ANY($1::uuid[])
+		ORDER BY property_id, position;
+	`, keys)
+	if err != nil {
+		return all, err
+	}
+	defer rateRows.Close()
+
+	for rateRows.Next() {
+		var (
+			id       uuid.UUID
+			rate     models.RateRule
+			weekdays pq.Int64Array
+		)
+		if err := rateRows.Scan(&id, &rate.Name, &rate.StartDate, &rate.EndDate, &weekdays, &rate.PricePerNight); err != nil {
+			return all, err
+		}
+		rate.Weekdays = intSlice(weekdays)
+
+		if rules, ok := all[id]; ok {
+			rules.Rates = append(rules.Rates, rate)
+			all[id] = rules
+		}
+	}
+
+	return all, rateRows.Err()
+}
+
+func loadPropertyPricing(ctx context.Context, db queryer, id uuid.UUID) (models.PricingRules, error) {
+	all, err := loadPricingRules(ctx, db, []uuid.UUID{id})
+	if err != nil {
+		return models.PricingRules{}, err
+	}
+
+	rules, ok := all[id]
+	if !ok {
+		return rules, sql.ErrNoRows
+	}
+	return rules, nil
+}
+
+func (repo *Repository) GetPricingRules(propertyID uuid.UUID) (models.PricingRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return loadPropertyPricing(ctx, repo.db, propertyID)
+}
+
+// SetPricingRules replaces a property's fees, discounts and rate rules. The
+// base price is left alone; it is edited with the rest of the property.
+func (repo *Repository) SetPricingRules(propertyID uuid.UUID, rules models.PricingRules, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockPropertyCalendar(ctx, tx, propertyID); err != nil {
+		return err
+	}
+
+	before, err := loadPropertyPricing(ctx, tx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE properties SET
+			cleaning_fee = $2, extra_guest_fee = $3, guests_included = $4,
+			weekly_discount_percent = $5, monthly_discount_percent = $6
+		WHERE id = $1;
+	`, propertyID, rules.CleaningFee, rules.ExtraGuestFee, rules.GuestsIncluded,
+		rules.WeeklyDiscountPercent, rules.MonthlyDiscountPercent)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM property_rate_rules WHERE property_id = $1;`, propertyID); err != nil {
+		return err
+	}
+
+	if len(rules.Rates) > 0 {
+		values := make([]string, 0, len(rules.Rates))
+		args := []any{propertyID}
+		for i, rate := range rules.Rates {
+			var start, end sql.NullString
+			if rate.StartDate != "" {
+				start = sql.NullString{String: rate.StartDate, Valid: true}
+				end = sql.NullString{String: rate.EndDate, Valid: true}
+			}
+
+			n := len(args)
+			values = append(values, fmt.Sprintf("($1, $%d, $%d, $%d::date, $%d::date, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+			args = append(args, i, rate.Name, start, end, checkInDaysArg(rate.Weekdays), rate.PricePerNight)
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO property_rate_rules (property_id, position, name, start_date, end_date, weekdays, price_per_night)
+			VALUES `+strings.Join(values, ", ")+`;
+		`, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	rules.Currency, rules.BasePrice, rules.MaxGuests = before.Currency, before.BasePrice, before.MaxGuests
+	if err := recordAudit(ctx, tx, actor, "property.pricing", "property", propertyID, before, rules); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// quoteStay checks that a stay is bookable by its party and prices it,
+// with its coupon and taxes.
+func quoteStay(ctx context.Context, tx *sql.Tx, req models.StayRequest, rates fx.Provider) (models.PriceQuote, error) {
+	rules, err := loadPropertyPricing(ctx, tx, req.PropertyID)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+
+	if req.Party.Guests() > rules.MaxGuests {
+		return models.PriceQuote{}, &StayRuleError{Problem: fmt.Sprintf("the property sleeps at most %d guests", rules.MaxGuests)}
+	}
+
+	if req.Party.Pets > 0 {
+		var petsAllowed bool
+		err := tx.QueryRowContext(ctx, `SELECT pets_allowed FROM properties WHERE id = $1;`, req.PropertyID).Scan(&petsAllowed)
+		if err != nil {
+			return models.PriceQuote{}, err
+		}
+		if !petsAllowed {
+			return models.PriceQuote{}, &StayRuleError{Problem: "the property does not allow pets"}
+		}
+	}
+
+	if err := checkStay(ctx, tx, req.PropertyID, req.StartDate, req.EndDate, req.BookingID); err != nil {
+		return models.PriceQuote{}, err
+	}
+
+	quote, err := couponQuote(ctx, tx, pricing.Quote(rules, req.StartDate, req.EndDate, req.Party.Guests()), req, rates)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+
+	taxes, err := loadTaxRules(ctx, tx, []uuid.UUID{req.PropertyID}, req.StartDate, req.EndDate)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+
+	return taxQuote(ctx, quote, taxes[req.PropertyID], rates)
+}
+
+// QuoteStay prices a stay at a published listing, failing the same way
+// CreateBooking would if it cannot be booked or its coupon cannot be used.
+// rates converts flat taxes and coupons set in another currency.
+func (repo *Repository) QuoteStay(req models.StayRequest, rates fx.Provider) (models.PriceQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return models.PriceQuote{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		SELECT status FROM properties WHERE id = $1 AND deleted_at IS NULL;
+	`, req.PropertyID).Scan(&status)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+	if status != models.ListingPublished {
+		return models.PriceQuote{}, ErrListingUnavailable
+	}
+
+	return quoteStay(ctx, tx, req, rates)
+}
+
+// quoteProperties attaches the price of the stay, taxes included, to each
+// search result.
+func (repo *Repository) quoteProperties(props []models.GetProperty, start, end time.Time, guests int, rates fx.Provider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ids := make([]uuid.UUID, len(props))
+	for i, p := range props {
+		ids[i] = p.ID
+	}
+
+	all, err := loadPricingRules(ctx, repo.db, ids)
+	if err != nil {
+		return err
+	}
+
+	taxes, err := loadTaxRules(ctx, repo.db, ids, start, end)
+	if err != nil {
+		return err
+	}
+
+	for i := range props {
+		if rules, ok := all[props[i].ID]; ok {
+			quote, err := taxQuote(ctx, pricing.Quote(rules, start, end, guests), taxes[props[i].ID], rates)
+			if err != nil {
+				return err
+			}
+			props[i].Quote = &quote
+		}
+	}
+
+	return nil
+}
@@ -8,73 +8,69 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/geo"
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-// TODO: Handle Null value when no images are provided
-func (repo *Repository) GetAllProperties() ([]models.GetProperty, error) {
-	query := `
+// propertyCoverJoin joins the first image of each property (aliased cover),
+// preferring its thumbnail derivative over the full-size original.
+const propertyCoverJoin = `
+	LEFT JOIN LATERAL (
 		SELECT
-			p.id,
-			p.title,
-			p.location,
-			p.price_per_night,
-			p.max_guests,
-			p.created_at,
+			COALESCE(
+				(SELECT v.url FROM property_image_variants v WHERE v.image_id = pi.id AND v.name = 'thumb'),
+				pi.image_url
+			) AS thumbnail_url,
 			(
-				SELECT pi.image_url
-				FROM property_images pi
-				WHERE pi.property_id = p.id
-				ORDER BY pi.display_order ASC
-				LIMIT 1
-			) AS thumbnail_url
-		FROM properties p
-		ORDER BY p.created_at DESC;
-	`
-
-	var properties []models.GetProperty
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	rows, err := repo.db.QueryContext(ctx, query)
-
-	if err != nil {
-		return properties, err
-	}
-
-	for rows.Next() {
-		var property models.GetProperty
-
-		err := rows.Scan(
-			&property.ID,
-			&property.Title,
-			&property.Location,
-			&property.PricePerNight,
-			&property.MaxGuests,
-			&property.CreatedAt,
-			&property.ThumbnailURL,
-		)
-
-		if err != nil {
-			return properties, err
-		}
-
-		properties = append(properties, property)
-
-	}
+				SELECT string_agg(v.url || ' ' || v.width || 'w', ', ' ORDER BY v.width)
+				FROM property_image_variants v
+				WHERE v.image_id = pi.id
+			) AS srcset,
+			pi.blurhash
+		FROM property_images pi
+		WHERE pi.property_id = p.id
+		ORDER BY pi.display_order ASC
+		LIMIT 1
+	) cover ON TRUE
+`
+
+// propertyAttributeColumns selects models.PropertyAttributes from a
+// properties table aliased p, in the order of attributeDests.
+var propertyAttributeColumns = []string{
+	"p.property_type", "p.bedrooms", "p.beds", "p.bathrooms",
+	"to_char(p.check_in_time, 'HH24:MI')", "to_char(p.check_out_time, 'HH24:MI')",
+	"p.pets_allowed", "p.smoking_allowed", "p.events_allowed", "p.cancellation_policy", "p.booking_mode",
+}
 
-	if err = rows.Err(); err != nil {
-		return properties, err
+func attributeDests(a *models.PropertyAttributes) []any {
+	return []any{
+		&a.PropertyType, &a.Bedrooms, &a.Beds, &a.Bathrooms,
+		&a.CheckInTime, &a.CheckOutTime,
+		&a.HouseRules.PetsAllowed, &a.HouseRules.SmokingAllowed, &a.HouseRules.EventsAllowed,
+		&a.CancellationPolicy, &a.BookingMode,
 	}
+}
 
-	return properties, nil
+// GetAllProperties lists properties, optionally narrowed by a text query
+// and location. Text matches are ordered by relevance.
+func (repo *Repository) GetAllProperties(params models.SearchPropertyParams) ([]models.GetProperty, error) {
+	return repo.searchProperties(params, func(q *propertyQuery) {
+		q.location(params.Location)
+		q.near(params)
+		q.attributes(params)
+	})
 }
 
 func (repo *Repository) GetPropertyByID(id uuid.UUID) (models.Property, error) {
 	query1 := `
-		SELECT id, title, location, max_guests, price_per_night, description, created_at
-		FROM properties WHERE id = $1;
+		SELECT p.id, p.title, p.location, p.max_guests, p.price_per_night, p.currency, p.country, p.region, p.city, p.description, p.created_at,
+			p.latitude, p.longitude, p.public_latitude, p.public_longitude, p.user_id,
+			p.status, p.review_note, p.version,
+			` + strings.Join(propertyAttributeColumns, ", ") + `
+		FROM properties p WHERE p.id = $1 AND p.deleted_at IS NULL;
 	`
 
 	query2 := `
@@ -82,19 +78,34 @@ func (repo *Repository) GetPropertyByID(id uuid.UUID) (models.Property, error) {
 			id,
 			image_url,
 			caption,
-			display_order
+			display_order,
+			width,
+			height,
+			blurhash,
+			processing_status
 		FROM property_images
 		WHERE property_id = $1
 		ORDER BY display_order ASC;
 	`
 
+	variantQuery := `
+		SELECT v.image_id, v.name, v.url, v.width, v.height
+		FROM property_image_variants v
+		JOIN property_images pi ON pi.id = v.image_id
+		WHERE pi.property_id = $1
+		ORDER BY v.width ASC;
+	`
+
 	query3 := `
 		SELECT
 			a.id,
-			a.name
+			a.name,
+			a.category,
+			a.icon
 		FROM amenities a
 		JOIN property_amenities pa ON pa.amenity_id = a.id
-		WHERE pa.property_id = $1;
+		WHERE pa.property_id = $1
+		ORDER BY a.category, a.name;
 	`
 
 	var property models.Property
@@ -102,15 +113,27 @@ func (repo *Repository) GetPropertyByID(id uuid.UUID) (models.Property, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := repo.db.QueryRowContext(ctx, query1, id).Scan(
+	err := repo.db.QueryRowContext(ctx, query1, id).Scan(append([]any{
 		&property.ID,
 		&property.Title,
 		&property.Location,
 		&property.MaxGuests,
 		&property.PricePerNight,
+		&property.Currency,
+		&property.Jurisdiction.Country,
+		&property.Jurisdiction.Region,
+		&property.Jurisdiction.City,
 		&property.Description,
 		&property.CreatedAt,
-	)
+		&property.Latitude,
+		&property.Longitude,
+		&property.PublicLatitude,
+		&property.PublicLongitude,
+		&property.HostID,
+		&property.Status,
+		&property.ReviewNote,
+		&property.Version,
+	}, attributeDests(&property.PropertyAttributes)...)...)
 
 	if err != nil {
 		return property, err
@@ -124,23 +147,59 @@ func (repo *Repository) GetPropertyByID(id uuid.UUID) (models.Property, error) {
 	defer imgRows.Close()
 
 	for imgRows.Next() {
-		var images struct {
-			ImageID      uuid.UUID `json:"image_id"`
-			ImageURL     string    `json:"image_url"`
-			Caption      string    `json:"caption"`
-			DisplayOrder int       `json:"display_order"`
+		var image models.PropertyImage
+
+		if err := imgRows.Scan(
+			&image.ImageID,
+			&image.ImageURL,
+			&image.Caption,
+			&image.DisplayOrder,
+			&image.Width,
+			&image.Height,
+			&image.Blurhash,
+			&image.ProcessingStatus,
+		); err != nil {
+			return property, err
 		}
+		property.Images = append(property.Images, image)
+	}
 
-		if err := imgRows.Scan(&images.ImageID, &images.ImageURL, &images.Caption, &images.DisplayOrder); err != nil {
+	if err = imgRows.Err(); err != nil {
+		return property, err
+	}
+
+	variantRows, err := repo.db.QueryContext(ctx, variantQuery, id)
+	if err != nil {
+		return property, err
+	}
+	defer variantRows.Close()
+
+	variants := map[uuid.UUID][]models.ImageVariant{}
+	for variantRows.Next() {
+		var imageID uuid.UUID
+		var v models.ImageVariant
+
+		if err := variantRows.Scan(&imageID, &v.Name, &v.URL, &v.Width, &v.Height); err != nil {
 			return property, err
 		}
-		property.Images = append(property.Images, images)
+		variants[imageID] = append(variants[imageID], v)
 	}
 
-	if err = imgRows.Err(); err != nil {
+	if err = variantRows.Err(); err != nil {
 		return property, err
 	}
 
+	for i := range property.Images {
+		image := &property.Images[i]
+		image.Variants = variants[image.ImageID]
+
+		srcset := make([]string, 0, len(image.Variants))
+		for _, v := range image.Variants {
+			srcset = append(srcset, fmt.Sprintf("%s %dw", v.URL, v.Width))
+		}
+		image.Srcset = strings.Join(srcset, ", ")
+	}
+
 	amenityRows, err := repo.db.QueryContext(ctx, query3, id)
 
 	if err != nil {
@@ -149,12 +208,9 @@ func (repo *Repository) GetPropertyByID(id uuid.UUID) (models.Property, error) {
 	defer amenityRows.Close()
 
 	for amenityRows.Next() {
-		var amenity struct {
-			AmenityID uuid.UUID `json:"amenity_id"`
-			Name      string    `json:"name"`
-		}
+		var amenity models.Amenity
 
-		if err := amenityRows.Scan(&amenity.AmenityID, &amenity.Name); err != nil {
+		if err := amenityRows.Scan(&amenity.AmenityID, &amenity.Name, &amenity.Category, &amenity.Icon); err != nil {
 			return property, err
 		}
 
@@ -168,94 +224,519 @@ func (repo *Repository) GetPropertyByID(id uuid.UUID) (models.Property, error) {
 	return property, nil
 }
 
-func (repo *Repository) PostProperty(property models.PostProperty) (uuid.UUID, error) {
+func (repo *Repository) PostProperty(property models.PostProperty, actor models.Actor) (uuid.UUID, error) {
 	query := `
-		INSERT INTO properties (title, description, location, price_per_night, max_guests, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO properties (title, description, location, price_per_night, max_guests, user_id,
+			latitude, longitude, public_latitude, public_longitude,
+			property_type, bedrooms, beds, bathrooms, check_in_time, check_out_time,
+			pets_allowed, smoking_allowed, events_allowed, currency, country, region, city, cancellation_policy,
+			booking_mode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15::time, $16::time, $17, $18, $19, $20,
+			$21, $22, $23, $24, $25)
 		RETURNING id;
 	`
 	var id uuid.UUID
 
+	publicLat, publicLng := fuzzCoordinates(property.Latitude, property.Longitude)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := repo.db.QueryRowContext(ctx, query,
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query,
 		property.Title,
 		property.Description,
 		property.Location,
 		property.PricePerNight,
 		property.MaxGuests,
 		property.UserID,
+		property.Latitude,
+		property.Longitude,
+		publicLat,
+		publicLng,
+		property.PropertyType,
+		property.Bedrooms,
+		property.Beds,
+		property.Bathrooms,
+		property.CheckInTime,
+		property.CheckOutTime,
+		property.HouseRules.PetsAllowed,
+		property.HouseRules.SmokingAllowed,
+		property.HouseRules.EventsAllowed,
+		property.Currency,
+		property.Jurisdiction.Country,
+		property.Jurisdiction.Region,
+		property.Jurisdiction.City,
+		property.CancellationPolicy,
+		property.BookingMode,
 	).Scan(&id)
 
 	if err != nil {
 		return uuid.Nil, err
 	}
 
+	after, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := recordRevision(ctx, tx, after, "create", nil, actor); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "property.create", "property", id, nil, after); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return id, nil
 }
 
-func (repo *Repository) DeleteProperty(id uuid.UUID) (int64, error) {
+// propertySnapshot reads and locks the property's own columns, for audit
+// before/after states. Deleted properties are treated as missing.
+func propertySnapshot(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*models.Property, error) {
 	query := `
-		DELETE FROM properties
-		WHERE id = $1
+		SELECT p.id, p.title, p.location, p.max_guests, p.price_per_night, p.currency, p.country, p.region, p.city, p.description, p.created_at, p.updated_at,
+			p.latitude, p.longitude, p.public_latitude, p.public_longitude,
+			p.status, p.review_note, p.version,
+			` + strings.Join(propertyAttributeColumns, ", ") + `
+		FROM properties p WHERE p.id = $1 AND p.deleted_at IS NULL
+		FOR UPDATE;
+	`
+
+	var property models.Property
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(append([]any{
+		&property.ID,
+		&property.Title,
+		&property.Location,
+		&property.MaxGuests,
+		&property.PricePerNight,
+		&property.Currency,
+		&property.Jurisdiction.Country,
+		&property.Jurisdiction.Region,
+		&property.Jurisdiction.City,
+		&property.Description,
+		&property.CreatedAt,
+		&property.UpdatedAt,
+		&property.Latitude,
+		&property.Longitude,
+		&property.PublicLatitude,
+		&property.PublicLongitude,
+		&property.Status,
+		&property.ReviewNote,
+		&property.Version,
+	}, attributeDests(&property.PropertyAttributes)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &property, nil
+}
+
+// fuzzCoordinates returns the public position for a real one, or nils when
+// the property has no coordinates.
+func fuzzCoordinates(lat, lng *float64) (*float64, *float64) {
+	if lat == nil || lng == nil {
+		return nil, nil
+	}
+
+	publicLat, publicLng := geo.Fuzz(*lat, *lng)
+	return &publicLat, &publicLng
+}
+
+func sameCoordinate(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// DeleteProperty soft-deletes a property: it disappears from every public
+// query but keeps its bookings, images and amenities until the retention job
+// purges it. Properties with upcoming bookings cannot be deleted.
+func (repo *Repository) DeleteProperty(id uuid.UUID, actor models.Actor) (int64, error) {
+	activeQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM bookings
+			WHERE property_id = $1 AND status IN ` + activeBookingStatuses + ` AND end_date >= CURRENT_DATE
+		);
+	`
+
+	query := `
+		UPDATE properties
+		SET deleted_at = NOW(), deleted_by = $2, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING deleted_at;
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	exisitingProperty, err := repo.GetPropertyByID(id)
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	exisitingProperty, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.New("property do not exist")
+		}
 		return 0, err
 	}
 
-	if exisitingProperty.ID == uuid.Nil {
-		return 0, errors.New("property do not exist")
+	var active bool
+	if err := tx.QueryRowContext(ctx, activeQuery, id).Scan(&active); err != nil {
+		return 0, err
+	}
+	if active {
+		return 0, ErrActiveBookings
 	}
 
-	result, err := repo.db.ExecContext(ctx, query, id)
-	if err != nil {
+	deletedBy := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+
+	var deletedAt time.Time
+	if err := tx.QueryRowContext(ctx, query, id, deletedBy).Scan(&deletedAt); err != nil {
 		return 0, err
 	}
 
-	count, err := result.RowsAffected()
-	if err != nil {
+	after := map[string]any{"deleted_at": deletedAt}
+	if err := recordAudit(ctx, tx, actor, "property.delete", "property", id, exisitingProperty, after); err != nil {
 		return 0, err
 	}
 
-	return count, err
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return 1, nil
 }
 
-func (repo *Repository) UpdateProperty(property models.Property) error {
+// RestoreProperty undoes a soft delete. It returns sql.ErrNoRows if the
+// property is not deleted or has already been purged.
+func (repo *Repository) RestoreProperty(id uuid.UUID, actor models.Actor) error {
+	lockQuery := `
+		SELECT deleted_at
+		FROM properties
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE;
+	`
+
 	query := `
 		UPDATE properties
-		SET title = $1, description = $2, location = $3, max_guests = $4, price_per_night = $5, updated_at = NOW()
-		WHERE id = $6
+		SET deleted_at = NULL, deleted_by = NULL, version = version + 1, updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	if err := tx.QueryRowContext(ctx, lockQuery, id).Scan(&deletedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to restore property: %w", err)
+	}
+
+	after, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	before := map[string]any{"deleted_at": deletedAt}
+	if err := recordAudit(ctx, tx, actor, "property.restore", "property", id, before, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedProperties permanently removes up to limit properties that
+// were soft-deleted more than retention ago. Their bookings keep a copy of
+// the title and location. It returns the number purged and the storage keys
+// of their images so the caller can delete the blobs.
+func (repo *Repository) PurgeDeletedProperties(retention time.Duration, limit int, actor models.Actor) (int, []string, error) {
+	selectQuery := `
+		SELECT id, title, location, deleted_at
+		FROM properties
+		WHERE deleted_at < NOW() - make_interval(secs => $1)
+		ORDER BY deleted_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED;
+	`
+
+	keysQuery := `
+		SELECT storage_key
+		FROM property_images
+		WHERE property_id = ANY($1::uuid[]) AND storage_key IS NOT NULL
+		UNION ALL
+		SELECT v.storage_key
+		FROM property_image_variants v
+		JOIN property_images pi ON pi.id = v.image_id
+		WHERE pi.property_id = ANY($1::uuid[]) AND v.storage_key IS NOT NULL;
+	`
+
+	bookingsQuery := `
+		UPDATE bookings b
+		SET property_title = p.title, property_location = p.location
+		FROM properties p
+		WHERE b.property_id = p.id AND p.id = ANY($1::uuid[]);
+	`
+
+	deleteQuery := `
+		DELETE FROM properties WHERE id = ANY($1::uuid[]);
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := repo.db.ExecContext(ctx, query,
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type purged struct {
+		ID        uuid.UUID `json:"id"`
+		Title     string    `json:"title"`
+		Location  string    `json:"location"`
+		DeletedAt time.Time `json:"deleted_at"`
+	}
+
+	rows, err := tx.QueryContext(ctx, selectQuery, retention.Seconds(), limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	var properties []purged
+	var ids []string
+	for rows.Next() {
+		var p purged
+		if err := rows.Scan(&p.ID, &p.Title, &p.Location, &p.DeletedAt); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		properties = append(properties, p)
+		ids = append(ids, p.ID.String())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	if len(properties) == 0 {
+		return 0, nil, nil
+	}
+
+	keyRows, err := tx.QueryContext(ctx, keysQuery, pq.StringArray(ids))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read image keys: %w", err)
+	}
+	var keys []string
+	for keyRows.Next() {
+		var key string
+		if err := keyRows.Scan(&key); err != nil {
+			keyRows.Close()
+			return 0, nil, err
+		}
+		keys = append(keys, key)
+	}
+	keyRows.Close()
+	if err := keyRows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, bookingsQuery, pq.StringArray(ids)); err != nil {
+		return 0, nil, fmt.Errorf("failed to preserve booking history: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, pq.StringArray(ids)); err != nil {
+		return 0, nil, fmt.Errorf("failed to purge properties: %w", err)
+	}
+
+	for _, p := range properties {
+		if err := recordAudit(ctx, tx, actor, "property.purge", "property", p.ID, p, nil); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(properties), keys, nil
+}
+
+// UpdateProperty locks the property, lets apply change its editable fields
+// and saves the result, returning the new version. If ifMatch is set and is
+// not the current version it returns ErrVersionMismatch without calling
+// apply. Errors from apply are returned unchanged.
+func (repo *Repository) UpdateProperty(id uuid.UUID, ifMatch *int, apply func(p *models.EditableProperty) error, actor models.Actor) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if ifMatch != nil && *ifMatch != before.Version {
+		return 0, ErrVersionMismatch
+	}
+
+	// Listings edited before revisions existed get their current state
+	// recorded first, so the change can be rolled back.
+	if err := recordRevision(ctx, tx, before, "baseline", nil, actor); err != nil {
+		return 0, err
+	}
+
+	property := editableFields(before)
+	if err := apply(&property); err != nil {
+		return 0, err
+	}
+
+	if err := writeEditableFields(ctx, tx, before, property); err != nil {
+		return 0, err
+	}
+
+	after, err := propertySnapshot(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := recordRevision(ctx, tx, after, "update", nil, actor); err != nil {
+		return 0, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "property.update", "property", id, before, after); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after.Version, nil
+}
+
+// writeEditableFields saves property over the locked row before and bumps
+// its version.
+func writeEditableFields(ctx context.Context, tx *sql.Tx, before *models.Property, property models.EditableProperty) error {
+	query := `
+		UPDATE properties
+		SET title = $1, description = $2, location = $3, max_guests = $4, price_per_night = $5,
+			latitude = $7, longitude = $8, public_latitude = $9, public_longitude = $10,
+			property_type = $11, bedrooms = $12, beds = $13, bathrooms = $14,
+			check_in_time = $15::time, check_out_time = $16::time,
+			pets_allowed = $17, smoking_allowed = $18, events_allowed = $19,
+			currency = COALESCE(NULLIF($20, ''), currency),
+			country = COALESCE($21, country), region = COALESCE($22, region), city = COALESCE($23, city),
+			cancellation_policy = COALESCE(NULLIF($24, ''), cancellation_policy),
+			booking_mode = COALESCE(NULLIF($25, ''), booking_mode),
+			version = version + 1, updated_at = NOW()
+		WHERE id = $6;
+	`
+
+	// Keep the existing public position unless the property moved, so it
+	// cannot be narrowed down by re-saving and averaging.
+	publicLat, publicLng := before.PublicLatitude, before.PublicLongitude
+	if !sameCoordinate(before.Latitude, property.Latitude) || !sameCoordinate(before.Longitude, property.Longitude) {
+		publicLat, publicLng = fuzzCoordinates(property.Latitude, property.Longitude)
+	}
+
+	// A nil jurisdiction, from a revision saved before listings had one,
+	// leaves it unchanged.
+	var country, region, city any
+	if j := property.Jurisdiction; j != nil {
+		country, region, city = j.Country, j.Region, j.City
+	}
+
+	_, err := tx.ExecContext(ctx, query,
 		property.Title,
 		property.Description,
 		property.Location,
 		property.MaxGuests,
 		property.PricePerNight,
-		property.ID,
+		before.ID,
+		property.Latitude,
+		property.Longitude,
+		publicLat,
+		publicLng,
+		property.PropertyType,
+		property.Bedrooms,
+		property.Beds,
+		property.Bathrooms,
+		property.CheckInTime,
+		property.CheckOutTime,
+		property.HouseRules.PetsAllowed,
+		property.HouseRules.SmokingAllowed,
+		property.HouseRules.EventsAllowed,
+		property.Currency,
+		country,
+		region,
+		city,
+		property.CancellationPolicy,
+		property.BookingMode,
 	)
-
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to update property: %w", err)
 	}
 
 	return nil
 }
 
-func (repo *Repository) PostPropertyImages(data models.AddImagesRequest) error {
+func editableFields(p *models.Property) models.EditableProperty {
+	jurisdiction := p.Jurisdiction
+	return models.EditableProperty{
+		Title:              p.Title,
+		Location:           p.Location,
+		Description:        p.Description,
+		PricePerNight:      p.PricePerNight,
+		Currency:           p.Currency,
+		Jurisdiction:       &jurisdiction,
+		MaxGuests:          p.MaxGuests,
+		Latitude:           p.Latitude,
+		Longitude:          p.Longitude,
+		PropertyAttributes: p.PropertyAttributes,
+	}
+}
+
+func (repo *Repository) PostPropertyImages(data models.AddImagesRequest, actor models.Actor) ([]uuid.UUID, error) {
 	query := `
 		INSERT INTO property_images
-		(property_id, image_url, caption, display_order)
-		VALUES ($1, $2, $3, $4);
+		(property_id, image_url, caption, display_order, storage_key)
+		VALUES (
+			$1, $2, $3,
+			COALESCE($4, (SELECT COALESCE(MAX(display_order) + 1, 0) FROM property_images WHERE property_id = $1)),
+			NULLIF($5, '')
+		)
+		RETURNING id, display_order;
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -263,60 +744,136 @@ func (repo *Repository) PostPropertyImages(data models.AddImagesRequest) error {
 
 	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
 
+	ids := make([]uuid.UUID, 0, len(data.Images))
 	for _, img := range data.Images {
-		_, err := tx.ExecContext(ctx, query,
+		var imageID uuid.UUID
+		var displayOrder int
+		err := tx.QueryRowContext(ctx, query,
 			data.PropertyID,
 			img.ImageURL,
 			img.Caption,
 			img.DisplayOrder,
-		)
+			img.StorageKey,
+		).Scan(&imageID, &displayOrder)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to insert image: %w", err)
+			return nil, fmt.Errorf("failed to insert image: %w", err)
+		}
+
+		after := map[string]any{
+			"property_id":   data.PropertyID,
+			"image_url":     img.ImageURL,
+			"caption":       img.Caption,
+			"display_order": displayOrder,
+		}
+		if err := recordAudit(ctx, tx, actor, "property_image.create", "property_image", imageID, nil, after); err != nil {
+			tx.Rollback()
+			return nil, err
 		}
+
+		ids = append(ids, imageID)
 	}
 
+	// display_order uniqueness is checked at commit.
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return nil
+	return ids, nil
 }
 
-func (repo *Repository) DeletePropertyImage(imageID uuid.UUID) error {
+// DeletePropertyImage removes the image row and returns the storage keys of
+// the original and its derivatives, so the caller can delete the blobs.
+func (repo *Repository) DeletePropertyImage(propertyID uuid.UUID, imageID uuid.UUID, actor models.Actor) ([]string, error) {
+	variantQuery := `
+		SELECT storage_key
+		FROM property_image_variants
+		WHERE image_id = $1 AND storage_key IS NOT NULL;
+	`
+
 	query := `
 		DELETE FROM property_images
-		WHERE id = $1;
+		WHERE id = $1 AND property_id = $2
+		RETURNING property_id, image_url, caption, display_order, COALESCE(storage_key, '');
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	res, err := repo.db.ExecContext(ctx, query, imageID)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var keys []string
+
+	rows, err := tx.QueryContext(ctx, variantQuery, imageID)
 	if err != nil {
-		return fmt.Errorf("failed to delete property image: %w", err)
+		return nil, fmt.Errorf("failed to read image variants: %w", err)
 	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
 
-	rowsAffected, err := res.RowsAffected()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var image struct {
+		PropertyID   uuid.UUID `json:"property_id"`
+		ImageURL     string    `json:"image_url"`
+		Caption      string    `json:"caption"`
+		DisplayOrder int       `json:"display_order"`
+		StorageKey   string    `json:"storage_key"`
+	}
+
+	err = tx.QueryRowContext(ctx, query, imageID, propertyID).Scan(
+		&image.PropertyID,
+		&image.ImageURL,
+		&image.Caption,
+		&image.DisplayOrder,
+		&image.StorageKey,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to check delete result: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to delete property image: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if image.StorageKey != "" {
+		keys = append(keys, image.StorageKey)
 	}
 
-	return nil
+	if err := recordAudit(ctx, tx, actor, "property_image.delete", "property_image", imageID, image, nil); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return keys, nil
 }
 
 func (repo *Repository) GetAllAmenities() ([]models.Amenity, error) {
 	query := `
-		SELECT id, name
+		SELECT id, name, category, icon
 		FROM amenities
-		ORDER BY name ASC;
+		ORDER BY category ASC, name ASC;
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -331,7 +888,7 @@ func (repo *Repository) GetAllAmenities() ([]models.Amenity, error) {
 	var amenities []models.Amenity
 	for rows.Next() {
 		var a models.Amenity
-		if err := rows.Scan(&a.AmenityID, &a.Name); err != nil {
+		if err := rows.Scan(&a.AmenityID, &a.Name, &a.Category, &a.Icon); err != nil {
 			return nil, err
 		}
 		amenities = append(amenities, a)
@@ -344,83 +901,115 @@ func (repo *Repository) GetAllAmenities() ([]models.Amenity, error) {
 	return amenities, nil
 }
 
-func (repo *Repository) AddAmenity(name string) (*models.Amenity, error) {
-	if name == "" {
+func (repo *Repository) AddAmenity(req models.AddAmenityRequest, actor models.Actor) (*models.Amenity, error) {
+	if req.Name == "" {
 		return nil, errors.New("amenity name required")
 	}
 
+	if req.Category == "" {
+		req.Category = "general"
+	}
+
 	query := `
-		INSERT INTO amenities (name)
-		VALUES ($1)
-		RETURNING id, name;
+		INSERT INTO amenities (name, category, icon)
+		VALUES ($1, $2, $3)
+		RETURNING id, name, category, icon;
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var a models.Amenity
-	err := repo.db.QueryRowContext(ctx, query, name).
-		Scan(&a.AmenityID, &a.Name)
+	err = tx.QueryRowContext(ctx, query, req.Name, req.Category, req.Icon).
+		Scan(&a.AmenityID, &a.Name, &a.Category, &a.Icon)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "amenity.create", "amenity", a.AmenityID, nil, a); err != nil {
 		return nil, err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return &a, nil
 }
 
-func (repo *Repository) SearchAvailability(searchParams models.SearchPropertyParams) ([]models.GetProperty, error) {
-	query := `
-		SELECT
-			p.id,
-			p.title,
-			p.location,
-			p.price_per_night,
-			p.max_guests,
-			p.created_at,
-			(
-				SELECT pi.image_url
-				FROM property_images pi
-				WHERE pi.property_id = p.id
-				ORDER BY pi.display_order ASC
-				LIMIT 1
-			) AS thumbnail_url
-		FROM properties p
-		WHERE LOWER(p.location) LIKE LOWER($1)
-		AND NOT EXISTS (
-				SELECT 1 FROM bookings b
-				WHERE b.property_id = p.id
-				AND daterange(b.start_date, b.end_date, '[]')
-					&& daterange($2, $3, '[]')
-		);
-
-	`
+// SearchAvailability finds listings bookable for the searched stay and
+// prices it for each. The price filters apply to the stay's average nightly
+// rate rather than the base price.
+func (repo *Repository) SearchAvailability(searchParams models.SearchPropertyParams, rates fx.Provider) ([]models.GetProperty, error) {
+	start, err := time.Parse(dateLayout, searchParams.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(dateLayout, searchParams.EndDate)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	filters := searchParams
+	filters.MinPrice, filters.MaxPrice = nil, nil
 
-	var props []models.GetProperty
+	props, err := repo.searchProperties(searchParams, func(q *propertyQuery) {
+		q.location(filters.Location)
+		q.near(filters)
+		q.attributes(filters)
+		q.available(filters.StartDate, filters.EndDate)
+	})
+	if err != nil || len(props) == 0 {
+		return props, err
+	}
 
-	rows, err := repo.db.QueryContext(ctx, query, searchParams.Location, searchParams.StartDate, searchParams.EndDate)
-	if err != nil {
+	guests := 1
+	if searchParams.Guests != nil && *searchParams.Guests > 1 {
+		guests = *searchParams.Guests
+	}
+	if err := repo.quoteProperties(props, start, end, guests, rates); err != nil {
 		return props, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var p models.GetProperty
-		if err := rows.Scan(
-			&p.ID, &p.Title,
-			&p.Location, &p.PricePerNight, &p.MaxGuests, &p.CreatedAt, &p.ThumbnailURL,
-		); err != nil {
-			return props, err
+	if searchParams.MinPrice == nil && searchParams.MaxPrice == nil {
+		return props, nil
+	}
+
+	matching := props[:0]
+	for _, p := range props {
+		if p.Quote == nil {
+			continue
+		}
+		nightly := p.Quote.Subtotal.Float() / float64(p.Quote.Nights)
+		if searchParams.PriceRates != nil {
+			rate, ok := searchParams.PriceRates[p.Quote.Currency]
+			if !ok {
+				continue
+			}
+			nightly *= rate
+		}
+		if searchParams.MinPrice != nil && nightly < *searchParams.MinPrice {
+			continue
 		}
-		props = append(props, p)
+		if searchParams.MaxPrice != nil && nightly > *searchParams.MaxPrice {
+			continue
+		}
+		matching = append(matching, p)
 	}
 
-	return props, nil
+	return matching, nil
 }
 
-func (repo *Repository) PostPropertyAmenity(amenities []models.PostAmenity) error {
+func (repo *Repository) PostPropertyAmenity(amenities []models.PostAmenity, actor models.Actor) error {
 	if len(amenities) == 0 {
 		return nil
 	}
@@ -437,13 +1026,49 @@ func (repo *Repository) PostPropertyAmenity(amenities []models.PostAmenity) erro
 	query := fmt.Sprintf(`
         INSERT INTO property_amenities (property_id, amenity_id)
         VALUES %s
-        ON CONFLICT (property_id, amenity_id) DO NOTHING`,
+        ON CONFLICT (property_id, amenity_id) DO NOTHING
+        RETURNING property_id, amenity_id`,
 		strings.Join(valueStrings, ","))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := repo.db.ExecContext(ctx, query, valueArgs...)
-	return err
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, valueArgs...)
+	if err != nil {
+		return err
+	}
+
+	var attached []models.PostAmenity
+	for rows.Next() {
+		var a models.PostAmenity
+		if err := rows.Scan(&a.PropertyID, &a.AmenityID); err != nil {
+			rows.Close()
+			return err
+		}
+		attached = append(attached, a)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range attached {
+		if err := recordAudit(ctx, tx, actor, "property_amenity.attach", "property", a.PropertyID, nil, a); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
 
 }
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// recordRevision stores the property's current fields, images and amenities
+// as the revision for property.Version. A revision that already exists for
+// that version is left untouched.
+func recordRevision(ctx context.Context, tx *sql.Tx, property *models.Property, action string, sourceVersion *int, actor models.Actor) error {
+	query := `
+		INSERT INTO property_revisions (property_id, version, action, source_version, snapshot, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (property_id, version) DO NOTHING;
+	`
+
+	snapshot, err := revisionSnapshot(ctx, tx, property)
+	if err != nil {
+		return err
+	}
+
+	js, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision: %w", err)
+	}
+
+	createdBy := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+
+	if _, err := tx.ExecContext(ctx, query, property.ID, property.Version, action, sourceVersion, string(js), createdBy); err != nil {
+		return fmt.Errorf("failed to record revision: %w", err)
+	}
+
+	return nil
+}
+
+func revisionSnapshot(ctx context.Context, tx *sql.Tx, property *models.Property) (*models.RevisionSnapshot, error) {
+	imageQuery := `
+		SELECT id, image_url, caption
+		FROM property_images
+		WHERE property_id = $1
+		ORDER BY display_order ASC;
+	`
+
+	amenityQuery := `
+		SELECT a.id, a.name
+		FROM amenities a
+		JOIN property_amenities pa ON pa.amenity_id = a.id
+		WHERE pa.property_id = $1
+		ORDER BY a.name;
+	`
+
+	snapshot := &models.RevisionSnapshot{
+		EditableProperty: editableFields(property),
+		Images:           []models.RevisionImage{},
+		Amenities:        []models.RevisionAmenity{},
+	}
+
+	rows, err := tx.QueryContext(ctx, imageQuery, property.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var img models.RevisionImage
+		if err := rows.Scan(&img.ImageID, &img.ImageURL, &img.Caption); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Images = append(snapshot.Images, img)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, amenityQuery, property.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a models.RevisionAmenity
+		if err := rows.Scan(&a.AmenityID, &a.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Amenities = append(snapshot.Amenities, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// GetPropertyRevisions returns the listing's revisions, newest first, each
+// with its diff against the revision before it.
+func (repo *Repository) GetPropertyRevisions(propertyID uuid.UUID) ([]models.PropertyRevision, error) {
+	query := `
+		SELECT version, action, source_version, snapshot, created_by, created_at
+		FROM property_revisions
+		WHERE property_id = $1
+		ORDER BY version ASC;
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, query, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.PropertyRevision{}
+	for rows.Next() {
+		var rev models.PropertyRevision
+		var snapshot []byte
+
+		if err := rows.Scan(&rev.Version, &rev.Action, &rev.SourceVersion, &snapshot, &rev.CreatedBy, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(snapshot, &rev.Snapshot); err != nil {
+			return nil, fmt.Errorf("failed to decode revision %d: %w", rev.Version, err)
+		}
+
+		var previous *models.RevisionSnapshot
+		if len(revisions) > 0 {
+			previous = &revisions[len(revisions)-1].Snapshot
+		}
+		if rev.Diff, err = revisionDiff(previous, &rev.Snapshot); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// DiffPropertyRevisions compares two revisions of a listing. It returns
+// sql.ErrNoRows if either does not exist.
+func (repo *Repository) DiffPropertyRevisions(propertyID uuid.UUID, from, to int) (map[string]models.FieldChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	a, err := loadRevision(ctx, repo.db, propertyID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := loadRevision(ctx, repo.db, propertyID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisionDiff(a, b)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func loadRevision(ctx context.Context, db queryRower, propertyID uuid.UUID, version int) (*models.RevisionSnapshot, error) {
+	query := `
+		SELECT snapshot FROM property_revisions
+		WHERE property_id = $1 AND version = $2;
+	`
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, query, propertyID, version).Scan(&raw); err != nil {
+		return nil, err
+	}
+
+	var snapshot models.RevisionSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d: %w", version, err)
+	}
+
+	return &snapshot, nil
+}
+
+// RollbackProperty restores the listing's fields, amenities, and the
+// captions and order of its surviving images to those of an earlier
+// revision. The rollback is saved as a new version, which is returned.
+func (repo *Repository) RollbackProperty(propertyID uuid.UUID, toVersion int, ifMatch *int, actor models.Actor) (*models.RollbackResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := propertySnapshot(ctx, tx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ifMatch != nil && *ifMatch != before.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	target, err := loadRevision(ctx, tx, propertyID, toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, before, "baseline", nil, actor); err != nil {
+		return nil, err
+	}
+
+	if err := writeEditableFields(ctx, tx, before, target.EditableProperty); err != nil {
+		return nil, err
+	}
+
+	if err := restoreRevisionAmenities(ctx, tx, propertyID, target.Amenities); err != nil {
+		return nil, err
+	}
+
+	missing, err := restoreRevisionImages(ctx, tx, propertyID, target.Images)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := propertySnapshot(ctx, tx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, after, "rollback", &toVersion, actor); err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "property.rollback", "property", propertyID, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.RollbackResult{Version: after.Version, MissingImages: missing}, nil
+}
+
+// restoreRevisionAmenities makes the property's amenity set match the
+// revision, skipping amenities that have since been removed from the catalog.
+func restoreRevisionAmenities(ctx context.Context, tx *sql.Tx, propertyID uuid.UUID, amenities []models.RevisionAmenity) error {
+	deleteQuery := `
+		DELETE FROM property_amenities
+		WHERE property_id = $1 AND NOT (amenity_id = ANY($2::uuid[]));
+	`
+
+	insertQuery := `
+		INSERT INTO property_amenities (property_id, amenity_id)
+		SELECT $1, a.id FROM amenities a WHERE a.id = ANY($2::uuid[])
+		ON CONFLICT (property_id, amenity_id) DO NOTHING;
+	`
+
+	ids := make([]string, len(amenities))
+	for i, a := range amenities {
+		ids[i] = a.AmenityID.String()
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, propertyID, pq.StringArray(ids)); err != nil {
+		return fmt.Errorf("failed to detach amenities: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, insertQuery, propertyID, pq.StringArray(ids)); err != nil {
+		return fmt.Errorf("failed to attach amenities: %w", err)
+	}
+
+	return nil
+}
+
+// restoreRevisionImages puts the revision's surviving images back in their
+// old order with their old captions; images added since follow them. It
+// returns the revision's images that no longer exist.
+func restoreRevisionImages(ctx context.Context, tx *sql.Tx, propertyID uuid.UUID, images []models.RevisionImage) ([]uuid.UUID, error) {
+	captionQuery := `
+		UPDATE property_images SET caption = $3
+		WHERE id = $1 AND property_id = $2;
+	`
+
+	current, err := lockImageOrder(ctx, tx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[uuid.UUID]bool, len(current))
+	for _, id := range current {
+		existing[id] = true
+	}
+
+	missing := []uuid.UUID{}
+	order := make([]uuid.UUID, 0, len(current))
+	for _, img := range images {
+		if !existing[img.ImageID] {
+			missing = append(missing, img.ImageID)
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, captionQuery, img.ImageID, propertyID, img.Caption); err != nil {
+			return nil, fmt.Errorf("failed to restore caption: %w", err)
+		}
+
+		order = append(order, img.ImageID)
+		delete(existing, img.ImageID)
+	}
+
+	for _, id := range current {
+		if existing[id] {
+			order = append(order, id)
+		}
+	}
+
+	if err := writeImageOrder(ctx, tx, propertyID, order); err != nil {
+		return nil, err
+	}
+
+	return missing, nil
+}
+
+// revisionDiff returns the changed fields between two snapshots, flattening
+// nested objects into dotted paths. Image and amenity lists are compared as
+// a whole. A nil from is treated as an empty listing.
+func revisionDiff(from, to *models.RevisionSnapshot) (map[string]models.FieldChange, error) {
+	a, err := flattenSnapshot(from)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := flattenSnapshot(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]models.FieldChange{}
+	for key, old := range a {
+		if updated, ok := b[key]; !ok || !reflect.DeepEqual(old, updated) {
+			diff[key] = models.FieldChange{From: old, To: b[key]}
+		}
+	}
+	for key, updated := range b {
+		if _, ok := a[key]; !ok {
+			diff[key] = models.FieldChange{From: nil, To: updated}
+		}
+	}
+
+	return diff, nil
+}
+
+func flattenSnapshot(s *models.RevisionSnapshot) (map[string]any, error) {
+	flat := map[string]any{}
+	if s == nil {
+		return flat, nil
+	}
+
+	js, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(js, &doc); err != nil {
+		return nil, err
+	}
+
+	var walk func(prefix string, v map[string]any)
+	walk = func(prefix string, v map[string]any) {
+		for key, value := range v {
+			if nested, ok := value.(map[string]any); ok {
+				walk(prefix+key+".", nested)
+				continue
+			}
+			flat[prefix+key] = value
+		}
+	}
+	walk("", doc)
+
+	return flat, nil
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/geo"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/lib/pq"
+)
+
+// propertyQuery builds the listing query shared by GetAllProperties and
+// SearchAvailability. Columns are paired with their scan destination so
+// optional columns (rank, snippets, ...) can be added per search.
+type propertyQuery struct {
+	columns    []string
+	dests      []func(p *models.GetProperty) any
+	conditions []string
+	orderBy    []string
+	args       []any
+	// distance is the distance expression of a radius search; results are
+	// ordered by it after any text relevance.
+	distance string
+}
+
+func newPropertyQuery() *propertyQuery {
+	q := &propertyQuery{}
+	q.column("p.id", func(p *models.GetProperty) any { return &p.ID })
+	q.column("p.title", func(p *models.GetProperty) any { return &p.Title })
+	q.column("p.location", func(p *models.GetProperty) any { return &p.Location })
+	q.column("p.price_per_night", func(p *models.GetProperty) any { return &p.PricePerNight })
+	q.column("p.currency", func(p *models.GetProperty) any { return &p.Currency })
+	q.column("p.max_guests", func(p *models.GetProperty) any { return &p.MaxGuests })
+	q.column("p.created_at", func(p *models.GetProperty) any { return &p.CreatedAt })
+	q.column("cover.thumbnail_url", func(p *models.GetProperty) any { return &p.ThumbnailURL })
+	q.column("cover.srcset", func(p *models.GetProperty) any { return &p.ThumbnailSrcset })
+	q.column("cover.blurhash", func(p *models.GetProperty) any { return &p.ThumbnailBlurhash })
+	q.column("p.public_latitude", func(p *models.GetProperty) any { return &p.Latitude })
+	q.column("p.public_longitude", func(p *models.GetProperty) any { return &p.Longitude })
+	for i, column := range propertyAttributeColumns {
+		q.column(column, func(p *models.GetProperty) any {
+			return attributeDests(&p.PropertyAttributes)[i]
+		})
+	}
+
+	// Listing searches are public, so only live, published listings are shown.
+	q.where("p.status = 'published'")
+	q.where("p.deleted_at IS NULL")
+	return q
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *propertyQuery) arg(v any) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *propertyQuery) column(expr string, dest func(p *models.GetProperty) any) {
+	q.columns = append(q.columns, expr)
+	q.dests = append(q.dests, dest)
+}
+
+func (q *propertyQuery) where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+func (q *propertyQuery) sql() string {
+	where := ""
+	if len(q.conditions) > 0 {
+		where = "WHERE " + strings.Join(q.conditions, "\n\t\tAND ")
+	}
+
+	orderBy := q.orderBy
+	if q.distance != "" {
+		orderBy = append(orderBy, q.distance+" ASC")
+	}
+	orderBy = append(orderBy, "p.created_at DESC")
+
+	return fmt.Sprintf(`
+		SELECT %s
+		FROM properties p
+		%s
+		%s
+		ORDER BY %s;
+	`, strings.Join(q.columns, ", "), propertyCoverJoin, where, strings.Join(orderBy, ", "))
+}
+
+// textSearch restricts the query to properties matching params.Query.
+// Full-text matches are ranked with ts_rank; the fuzzy mode instead uses
+// trigram similarity so misspelled queries still find something.
+func (q *propertyQuery) textSearch(text string, fuzzy bool) {
+	if text == "" {
+		return
+	}
+
+	t := q.arg(text)
+
+	if fuzzy {
+		similarity := fmt.Sprintf("GREATEST(word_similarity(%s, p.title), word_similarity(%s, p.location))", t, t)
+		q.where(similarity + " >= 0.3")
+		q.column(similarity, func(p *models.GetProperty) any { return &p.Rank })
+		q.column("NULL::text", func(p *models.GetProperty) any { return &p.Highlight })
+		q.orderBy = append(q.orderBy, similarity+" DESC")
+		return
+	}
+
+	tsquery := fmt.Sprintf("(websearch_to_tsquery('english', %s) || websearch_to_tsquery('simple', %s))", t, t)
+	rank := fmt.Sprintf("ts_rank(p.search_vector, %s)", tsquery)
+	// The text is HTML-escaped first so the only markup in the snippet is
+	// the <mark> tags ts_headline adds.
+	headline := fmt.Sprintf(
+		"ts_headline('english', %s, %s, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15')",
+		htmlEscapeSQL("p.title || ' — ' || p.description"), tsquery,
+	)
+
+	q.where("p.search_vector @@ " + tsquery)
+	q.column(rank, func(p *models.GetProperty) any { return &p.Rank })
+	q.column(headline, func(p *models.GetProperty) any { return &p.Highlight })
+	q.orderBy = append(q.orderBy, rank+" DESC")
+}
+
+// htmlEscapeSQL wraps the text expression expr so it evaluates to the same
+// text with HTML special characters replaced by entities.
+func htmlEscapeSQL(expr string) string {
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&quot;"}, {"'", "&#39;"}} {
+		expr = fmt.Sprintf("replace(%s, '%s', '%s')", expr, strings.ReplaceAll(r[0], "'", "''"), r[1])
+	}
+	return expr
+}
+
+func (q *propertyQuery) location(location string) {
+	if location == "" {
+		return
+	}
+	q.where("p.location ILIKE '%' || " + q.arg(location) + " || '%'")
+}
+
+// near restricts the query to properties whose public position lies within
+// params.RadiusKm of params.Latitude/Longitude and/or inside params.BBox.
+// The fuzzed position is used so results never reveal more than a listing
+// page does.
+func (q *propertyQuery) near(params models.SearchPropertyParams) {
+	if params.Latitude != nil && params.Longitude != nil && params.RadiusKm != nil {
+		lat, lng := q.arg(*params.Latitude), q.arg(*params.Longitude)
+
+		q.distance = fmt.Sprintf(`(%v * 2 * asin(least(1, sqrt(
+			power(sin(radians(p.public_latitude - %s) / 2), 2) +
+			cos(radians(%s)) * cos(radians(p.public_latitude)) *
+			power(sin(radians(p.public_longitude - %s) / 2), 2)
+		))))`, geo.EarthRadiusKm, lat, lat, lng)
+
+		// The box lets the coordinate index discard most rows before the
+		// haversine is evaluated.
+		q.boundingBox(geo.Around(*params.Latitude, *params.Longitude, *params.RadiusKm))
+		q.where(fmt.Sprintf("%s <= %s", q.distance, q.arg(*params.RadiusKm)))
+		q.column(q.distance, func(p *models.GetProperty) any { return &p.DistanceKm })
+	}
+
+	if params.BBox != nil {
+		q.boundingBox(*params.BBox)
+	}
+}
+
+func (q *propertyQuery) boundingBox(box geo.BoundingBox) {
+	q.where(fmt.Sprintf("p.public_latitude BETWEEN %s AND %s", q.arg(box.South), q.arg(box.North)))
+
+	west, east := q.arg(box.West), q.arg(box.East)
+	if box.West <= box.East {
+		q.where(fmt.Sprintf("p.public_longitude BETWEEN %s AND %s", west, east))
+	} else {
+		q.where(fmt.Sprintf("(p.public_longitude >= %s OR p.public_longitude <= %s)", west, east))
+	}
+}
+
+// convertedPrice is the nightly base price in the currency the price filters
+// are given in. Listings in a currency missing from rates convert to NULL and
+// so never match a price filter.
+func (q *propertyQuery) convertedPrice(rates map[string]float64) string {
+	if rates == nil {
+		return "p.price_per_night"
+	}
+
+	currencies := make(pq.StringArray, 0, len(rates))
+	values := make(pq.Float64Array, 0, len(rates))
+	for currency, rate := range rates {
+		currencies = append(currencies, currency)
+		values = append(values, rate)
+	}
+
+	return fmt.Sprintf(`(p.price_per_night * (
+		SELECT fx.rate FROM unnest(%s::text[], %s::float8[]) AS fx(currency, rate)
+		WHERE fx.currency = p.currency
+	))`, q.arg(currencies), q.arg(values))
+}
+
+// attributes applies the price, capacity, room count and house rule filters.
+func (q *propertyQuery) attributes(params models.SearchPropertyParams) {
+	if params.MinPrice != nil || params.MaxPrice != nil {
+		price := q.convertedPrice(params.PriceRates)
+		if params.MinPrice != nil {
+			q.where(price + " >= " + q.arg(*params.MinPrice))
+		}
+		if params.MaxPrice != nil {
+			q.where(price + " <= " + q.arg(*params.MaxPrice))
+		}
+	}
+	if params.Guests != nil {
+		q.where("p.max_guests >= " + q.arg(*params.Guests))
+	}
+	if len(params.PropertyTypes) > 0 {
+		q.where("p.property_type = ANY(" + q.arg(pq.StringArray(params.PropertyTypes)) + "::text[])")
+	}
+	if params.MinBedrooms != nil {
+		q.where("p.bedrooms >= " + q.arg(*params.MinBedrooms))
+	}
+	if params.MinBeds != nil {
+		q.where("p.beds >= " + q.arg(*params.MinBeds))
+	}
+	if params.MinBathrooms != nil {
+		q.where("p.bathrooms >= " + q.arg(*params.MinBathrooms))
+	}
+	if params.PetsAllowed != nil {
+		q.where("p.pets_allowed = " + q.arg(*params.PetsAllowed))
+	}
+	if params.SmokingAllowed != nil {
+		q.where("p.smoking_allowed = " + q.arg(*params.SmokingAllowed))
+	}
+	if params.EventsAllowed != nil {
+		q.where("p.events_allowed = " + q.arg(*params.EventsAllowed))
+	}
+}
+
+// available restricts the query to properties that can be booked from start
+// to end (check-out): no booked or blocked night in between, and a stay that
+// meets the minimum/maximum nights and check-in days for its start date.
+func (q *propertyQuery) available(start, end string) {
+	s, e := q.arg(start), q.arg(end)
+	stay := fmt.Sprintf("daterange(%s::date, %s::date, '[)')", s, e)
+	nights := fmt.Sprintf("(%s::date - %s::date)", e, s)
+
+	q.where(fmt.Sprintf(`NOT EXISTS (
+				SELECT 1 FROM bookings b
+				WHERE b.property_id = p.id AND b.status IN `+activeBookingStatuses+`
+				AND daterange(b.start_date, b.end_date, '[)') && %s
+		)`, stay))
+	q.where(fmt.Sprintf(`NOT EXISTS (
+				SELECT 1 FROM property_blocked_dates bd
+				WHERE bd.property_id = p.id
+				AND daterange(bd.start_date, bd.end_date, '[)') && %s
+		)`, stay))
+
+	// Overrides cannot overlap, so at most one covers the check-in date.
+	override := func(column string) string {
+		return fmt.Sprintf(`(SELECT r.%s FROM property_stay_rules r
+				WHERE r.property_id = p.id AND %s::date >= r.start_date AND %s::date < r.end_date)`, column, s, s)
+	}
+	q.where(fmt.Sprintf("%s >= COALESCE(%s, p.min_nights)", nights, override("min_nights")))
+	q.where(fmt.Sprintf("%s <= COALESCE(%s, p.max_nights, %s)", nights, override("max_nights"), nights))
+	q.where(fmt.Sprintf("COALESCE(COALESCE(%s, p.check_in_days) @> ARRAY[EXTRACT(DOW FROM %s::date)::int], TRUE)", override("check_in_days"), s))
+}
+
+func (repo *Repository) runPropertyQuery(q *propertyQuery) ([]models.GetProperty, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var props []models.GetProperty
+
+	rows, err := repo.db.QueryContext(ctx, q.sql(), q.args...)
+	if err != nil {
+		return props, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.GetProperty
+
+		dests := make([]any, len(q.dests))
+		for i, dest := range q.dests {
+			dests[i] = dest(&p)
+		}
+
+		if err := rows.Scan(dests...); err != nil {
+			return props, err
+		}
+		props = append(props, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return props, err
+	}
+
+	return props, nil
+}
+
+// searchProperties runs the listing query built by build. When a text query
+// finds nothing it is retried with trigram matching to tolerate typos.
+func (repo *Repository) searchProperties(params models.SearchPropertyParams, build func(q *propertyQuery)) ([]models.GetProperty, error) {
+	q := newPropertyQuery()
+	build(q)
+	q.textSearch(params.Query, false)
+
+	props, err := repo.runPropertyQuery(q)
+	if err != nil || len(props) > 0 || params.Query == "" {
+		return props, err
+	}
+
+	q = newPropertyQuery()
+	build(q)
+	q.textSearch(params.Query, true)
+
+	return repo.runPropertyQuery(q)
+}
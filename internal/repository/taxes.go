@@ -0,0 +1,338 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/fx"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/money"
+	"github.com/Umesh-Tiruvalluru/BookBnb/internal/pricing"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// taxRuleColumns selects a tax rule from tax_rules aliased t, in the order
+// of scanTaxRule.
+const taxRuleColumns = `
+	t.id, t.name, t.country, t.region, t.city, t.kind, t.percent, t.amount, t.currency,
+	to_char(t.effective_from, 'YYYY-MM-DD'), COALESCE(to_char(t.effective_to, 'YYYY-MM-DD'), ''),
+	t.created_at, t.updated_at`
+
+func scanTaxRule(row interface{ Scan(...any) error }, extra ...any) (models.TaxRule, error) {
+	var (
+		rule     models.TaxRule
+		percent  sql.NullFloat64
+		amount   sql.NullString
+		currency sql.NullString
+	)
+
+	err := row.Scan(append([]any{
+		&rule.ID, &rule.Name, &rule.Country, &rule.Region, &rule.City, &rule.Kind,
+		&percent, &amount, &currency, &rule.EffectiveFrom, &rule.EffectiveTo,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	}, extra...)...)
+	if err != nil {
+		return rule, err
+	}
+
+	if percent.Valid {
+		rule.Percent = &percent.Float64
+	}
+	if amount.Valid && currency.Valid {
+		a, err := money.ParseAmount(amount.String)
+		if err != nil {
+			return rule, err
+		}
+		rule.Amount = &money.Money{Amount: a, Currency: currency.String}
+	}
+
+	return rule, nil
+}
+
+// GetTaxRules lists tax rules, all of them or those of one country.
+func (repo *Repository) GetTaxRules(country string) ([]models.TaxRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT `+taxRuleColumns+`
+		FROM tax_rules t
+		WHERE $1 = '' OR t.country = $1
+		ORDER BY t.country, t.region, t.city, t.effective_from, t.name;
+	`, country)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.TaxRule{}
+	for rows.Next() {
+		rule, err := scanTaxRule(rows)
+		if err != nil {
+			return rules, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func getTaxRule(ctx context.Context, db queryer, id uuid.UUID, lock bool) (models.TaxRule, error) {
+	query := `SELECT ` + taxRuleColumns + ` FROM tax_rules t WHERE t.id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	return scanTaxRule(db.QueryRowContext(ctx, query, id))
+}
+
+func (repo *Repository) GetTaxRule(id uuid.UUID) (models.TaxRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return getTaxRule(ctx, repo.db, id, false)
+}
+
+// taxRuleArgs are the writable columns of a rule, from name to
+// effective_to.
+func taxRuleArgs(rule models.TaxRule) []any {
+	var amount, currency, effectiveTo any
+	if rule.Amount != nil {
+		amount, currency = rule.Amount.Amount, rule.Amount.Currency
+	}
+	if rule.EffectiveTo != "" {
+		effectiveTo = rule.EffectiveTo
+	}
+	return []any{
+		rule.Name, rule.Country, rule.Region, rule.City, rule.Kind,
+		rule.Percent, amount, currency, rule.EffectiveFrom, effectiveTo,
+	}
+}
+
+func (repo *Repository) CreateTaxRule(rule models.TaxRule, actor models.Actor) (models.TaxRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return rule, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createdBy := uuid.NullUUID{UUID: actor.UserID, Valid: actor.UserID != uuid.Nil}
+
+	var id uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO tax_rules (name, country, region, city, kind, percent, amount, currency,
+			effective_from, effective_to, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::date, $10::date, $11)
+		RETURNING id;
+	`, append(taxRuleArgs(rule), createdBy)...).Scan(&id)
+	if err != nil {
+		return rule, err
+	}
+
+	created, err := getTaxRule(ctx, tx, id, false)
+	if err != nil {
+		return rule, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "tax_rule.create", "tax_rule", id, nil, created); err != nil {
+		return rule, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rule, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return created, nil
+}
+
+// UpdateTaxRule replaces a rule. Bookings keep the taxes they were charged.
+func (repo *Repository) UpdateTaxRule(id uuid.UUID, rule models.TaxRule, actor models.Actor) (models.TaxRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return rule, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getTaxRule(ctx, tx, id, true)
+	if err != nil {
+		return rule, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tax_rules SET
+			name = $1, country = $2, region = $3, city = $4, kind = $5, percent = $6, amount = $7,
+			currency = $8, effective_from = $9::date, effective_to = $10::date, updated_at = NOW()
+		WHERE id = $11;
+	`, append(taxRuleArgs(rule), id)...)
+	if err != nil {
+		return rule, err
+	}
+
+	after, err := getTaxRule(ctx, tx, id, false)
+	if err != nil {
+		return rule, err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "tax_rule.update", "tax_rule", id, before, after); err != nil {
+		return rule, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rule, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return after, nil
+}
+
+func (repo *Repository) DeleteTaxRule(id uuid.UUID, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := getTaxRule(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tax_rules WHERE id = $1;`, id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, actor, "tax_rule.delete", "tax_rule", id, before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// loadTaxRules returns, for each property in ids, the tax rules of its
+// jurisdiction in effect on any night from start up to end.
+func loadTaxRules(ctx context.Context, db queryer, ids []uuid.UUID, start, end time.Time) (map[uuid.UUID][]models.TaxRule, error) {
+	all := make(map[uuid.UUID][]models.TaxRule, len(ids))
+	if len(ids) == 0 {
+		return all, nil
+	}
+
+	keys := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+taxRuleColumns+`, p.id
+		FROM properties p
+		JOIN tax_rules t ON t.country = p.country
+			AND (t.region = '' OR lower(t.region) = lower(p.region))
+			AND (t.city = '' OR lower(t.city) = lower(p.city))
+		WHERE p.id = ANY($1::uuid[])
+		AND t.effective_from < $3::date
+		AND (t.effective_to IS NULL OR t.effective_to > $2::date)
+		ORDER BY p.id, t.country, t.region, t.city, t.name;
+	`, keys, start, end)
+	if err != nil {
+		return all, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var propertyID uuid.UUID
+		rule, err := scanTaxRule(rows, &propertyID)
+		if err != nil {
+			return all, err
+		}
+		all[propertyID] = append(all[propertyID], rule)
+	}
+
+	return all, rows.Err()
+}
+
+// taxQuote adds the taxes in rules to quote, converting flat per-night
+// amounts into the quote's currency with rates.
+func taxQuote(ctx context.Context, quote models.PriceQuote, rules []models.TaxRule, rates fx.Provider) (models.PriceQuote, error) {
+	for i, rule := range rules {
+		if rule.Amount == nil || rule.Amount.Currency == quote.Currency {
+			continue
+		}
+		rate, err := rates.Rate(ctx, rule.Amount.Currency, quote.Currency)
+		if err != nil {
+			return quote, fmt.Errorf("tax rule %s: %w", rule.ID, err)
+		}
+		rules[i].Amount = &money.Money{Amount: rate.Convert(rule.Amount.Amount), Currency: quote.Currency}
+	}
+
+	return pricing.ApplyTaxes(quote, rules), nil
+}
+
+// recordBookingTaxes itemises the taxes of a new booking.
+func recordBookingTaxes(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID, taxes []models.TaxLine) error {
+	for _, tax := range taxes {
+		ruleID := uuid.NullUUID{UUID: tax.RuleID, Valid: tax.RuleID != uuid.Nil}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO booking_taxes (booking_id, tax_rule_id, name, country, region, city, kind, percent, nights, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+		`, bookingID, ruleID, tax.Name, tax.Country, tax.Region, tax.City, tax.Kind, tax.Percent, tax.Nights, tax.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaxReport totals the tax charged on bookings checking in from from up to
+// to, per rule and currency, with the revenue of those bookings net of all
+// their taxes. A booking has one line per rule, so each is counted once per
+// row. Cancelled bookings are left out.
+func (repo *Repository) TaxReport(from, to time.Time) ([]models.TaxReportRow, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := repo.db.QueryContext(ctx, `
+		SELECT bt.tax_rule_id, bt.name, bt.country, bt.region, bt.city, b.currency,
+			COUNT(*), SUM(bt.amount), SUM(b.total_price - b.tax_total)
+		FROM booking_taxes bt
+		JOIN bookings b ON b.id = bt.booking_id
+		WHERE b.status = 'confirmed' AND b.start_date >= $1::date AND b.start_date < $2::date
+		GROUP BY bt.tax_rule_id, bt.name, bt.country, bt.region, bt.city, b.currency
+		ORDER BY bt.country, bt.region, bt.city, bt.name, b.currency;
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []models.TaxReportRow{}
+	for rows.Next() {
+		var (
+			row    models.TaxReportRow
+			ruleID uuid.NullUUID
+		)
+		err := rows.Scan(&ruleID, &row.Name, &row.Country, &row.Region, &row.City, &row.Currency,
+			&row.Bookings, &row.Tax, &row.Revenue)
+		if err != nil {
+			return report, err
+		}
+		if ruleID.Valid {
+			row.RuleID = &ruleID.UUID
+		}
+		report = append(report, row)
+	}
+
+	return report, rows.Err()
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Umesh-Tiruvalluru/BookBnb/internal/models"
@@ -27,7 +28,7 @@ func (repo *Repository) GetUserByEmail(email string) (models.LoginUser, error) {
 	return user, nil
 }
 
-func (repo *Repository) RegisterUser(user *models.RegisterUser) (uuid.UUID, error) {
+func (repo *Repository) RegisterUser(user *models.RegisterUser, actor models.Actor) (uuid.UUID, error) {
 	const query = `
 		INSERT INTO users (first_name, last_name, email, password_hash)
 		VALUES ($1, $2, $3, $4)
@@ -51,10 +52,29 @@ func (repo *Repository) RegisterUser(user *models.RegisterUser) (uuid.UUID, erro
 		return uuid.Nil, errors.New("user already exists")
 	}
 
-	err = repo.db.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Email, user.PasswordHash).Scan(&id)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, user.FirstName, user.LastName, user.Email, user.PasswordHash).Scan(&id)
 	if err != nil {
 		return uuid.Nil, err
 	}
+
+	after := map[string]any{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      user.Email,
+	}
+	if err := recordAudit(ctx, tx, actor, "user.register", "user", id, nil, after); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return id, nil
 }
 
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{"properties/abc/photo.jpg", true},
+		{"photo.jpg", true},
+		{"properties/abc/..photo.jpg", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"..", false},
+		{"../secret", false},
+		{"properties/../../secret", false},
+		{"properties/abc/..", false},
+		{"properties/./photo.jpg", false},
+		{"properties//photo.jpg", false},
+		{"properties/abc/", false},
+		{`properties\..\..\secret`, false},
+		{`..\secret`, false},
+	}
+
+	for _, tt := range tests {
+		err := validateKey(tt.key)
+		if tt.ok && err != nil {
+			t.Errorf("validateKey(%q) error = %v, want nil", tt.key, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("validateKey(%q) error = %v, want %v", tt.key, err, ErrInvalidKey)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "uploads")
+	l, err := NewLocal(dir, "http://localhost:8080/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	url, err := l.Put(ctx, "properties/abc/photo.jpg", strings.NewReader("image bytes"), 11, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://localhost:8080/uploads/properties/abc/photo.jpg"; url != want {
+		t.Errorf("Put() = %q, want %q", url, want)
+	}
+
+	rc, err := l.Get(ctx, "properties/abc/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "image bytes" {
+		t.Errorf("Get() = %q, want %q", got, "image bytes")
+	}
+
+	for _, key := range []string{"../outside.txt", "properties/../../outside.txt"} {
+		if _, err := l.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+		if _, err := l.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+		if err := l.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q) error = %v, want %v", key, err, ErrInvalidKey)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("a key escaped the upload directory")
+	}
+
+	if err := l.Delete(ctx, "properties/abc/photo.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ctx, "properties/abc/photo.jpg"); err != nil {
+		t.Errorf("Delete() of a missing key error = %v", err)
+	}
+}
+
+func TestLocalHandler(t *testing.T) {
+	l, err := NewLocal(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Put(context.Background(), "properties/abc/photo.jpg", strings.NewReader("image bytes"), 11, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/properties/abc/photo.jpg", http.StatusOK},
+		{"/properties/abc/", http.StatusNotFound},
+		{"/properties/abc/missing.jpg", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		l.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("GET %s is missing X-Content-Type-Options", tt.path)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Storage stores uploaded blobs such as property photos. Keys are
+// slash-separated paths like "properties/<id>/<file>.jpg".
+type Storage interface {
+	// Put writes the blob under key and returns the public URL it is served from.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (string, error)
+	// Get opens the blob for reading; callers must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}
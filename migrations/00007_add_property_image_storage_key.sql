@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE property_images
+    ADD COLUMN storage_key TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE property_images
+    DROP COLUMN IF EXISTS storage_key;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE property_images
+    ADD COLUMN width                 INT,
+    ADD COLUMN height                INT,
+    ADD COLUMN blurhash              TEXT,
+    ADD COLUMN processing_status     TEXT NOT NULL DEFAULT 'pending'
+        CHECK (processing_status IN ('pending', 'processing', 'ready', 'failed')),
+    ADD COLUMN processing_error      TEXT,
+    ADD COLUMN processing_started_at TIMESTAMP;
+
+CREATE INDEX idx_property_images_processing ON property_images (processing_status)
+    WHERE processing_status IN ('pending', 'processing');
+
+CREATE TABLE IF NOT EXISTS property_image_variants (
+    id          UUID PRIMARY KEY DEFAULT GEN_RANDOM_UUID(),
+    image_id    UUID NOT NULL REFERENCES property_images(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    url         TEXT NOT NULL,
+    storage_key TEXT,
+    width       INT NOT NULL,
+    height      INT NOT NULL,
+    UNIQUE (image_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS property_image_variants;
+ALTER TABLE property_images
+    DROP COLUMN IF EXISTS width,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS blurhash,
+    DROP COLUMN IF EXISTS processing_status,
+    DROP COLUMN IF EXISTS processing_error,
+    DROP COLUMN IF EXISTS processing_started_at;
+-- +goose StatementEnd
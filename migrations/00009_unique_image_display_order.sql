@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Renumber existing images so every property has distinct display orders.
+UPDATE property_images pi
+SET display_order = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY property_id ORDER BY display_order, id) - 1 AS position
+    FROM property_images
+) ordered
+WHERE pi.id = ordered.id;
+
+-- Deferred so a reorder can swap positions inside one transaction.
+ALTER TABLE property_images
+    ADD CONSTRAINT uq_property_images_display_order
+    UNIQUE (property_id, display_order) DEFERRABLE INITIALLY DEFERRED;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE property_images
+    DROP CONSTRAINT IF EXISTS uq_property_images_display_order;
+-- +goose StatementEnd
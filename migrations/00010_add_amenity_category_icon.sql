@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE amenities
+    ADD COLUMN category TEXT NOT NULL DEFAULT 'general',
+    ADD COLUMN icon     TEXT;
+
+CREATE INDEX idx_amenities_category ON amenities (category, name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_amenities_category;
+ALTER TABLE amenities
+    DROP COLUMN IF EXISTS category,
+    DROP COLUMN IF EXISTS icon;
+-- +goose StatementEnd
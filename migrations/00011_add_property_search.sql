@@ -0,0 +1,108 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE properties
+    ADD COLUMN search_vector TSVECTOR;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Weighted document for a property: title (A), location and amenity names (B),
+-- description (C). Locations and amenities use the 'simple' config so place
+-- names are not stemmed.
+CREATE OR REPLACE FUNCTION property_search_vector(p_id UUID, p_title TEXT, p_location TEXT, p_description TEXT)
+RETURNS TSVECTOR AS $$
+    SELECT
+        setweight(to_tsvector('english', COALESCE(p_title, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(p_location, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE((
+            SELECT string_agg(a.name, ' ')
+            FROM property_amenities pa
+            JOIN amenities a ON a.id = pa.amenity_id
+            WHERE pa.property_id = p_id
+        ), '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(p_description, '')), 'C');
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION properties_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := property_search_vector(NEW.id, NEW.title, NEW.location, NEW.description);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_properties_search_vector
+BEFORE INSERT OR UPDATE OF title, location, description ON properties
+FOR EACH ROW EXECUTE FUNCTION properties_search_vector_trigger();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION property_amenities_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE properties p
+        SET search_vector = property_search_vector(p.id, p.title, p.location, p.description)
+        WHERE p.id = NEW.property_id;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE properties p
+        SET search_vector = property_search_vector(p.id, p.title, p.location, p.description)
+        WHERE p.id = OLD.property_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_property_amenities_search_vector
+AFTER INSERT OR UPDATE OR DELETE ON property_amenities
+FOR EACH ROW EXECUTE FUNCTION property_amenities_search_vector_trigger();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION amenities_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE properties p
+    SET search_vector = property_search_vector(p.id, p.title, p.location, p.description)
+    WHERE p.id IN (SELECT property_id FROM property_amenities WHERE amenity_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_amenities_search_vector
+AFTER UPDATE OF name ON amenities
+FOR EACH ROW EXECUTE FUNCTION amenities_search_vector_trigger();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE properties
+SET search_vector = property_search_vector(id, title, location, description);
+
+CREATE INDEX idx_properties_search_vector ON properties USING GIN (search_vector);
+CREATE INDEX idx_properties_title_trgm ON properties USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_properties_location_trgm ON properties USING GIN (location gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS trg_amenities_search_vector ON amenities;
+DROP TRIGGER IF EXISTS trg_property_amenities_search_vector ON property_amenities;
+DROP TRIGGER IF EXISTS trg_properties_search_vector ON properties;
+DROP FUNCTION IF EXISTS amenities_search_vector_trigger();
+DROP FUNCTION IF EXISTS property_amenities_search_vector_trigger();
+DROP FUNCTION IF EXISTS properties_search_vector_trigger();
+DROP FUNCTION IF EXISTS property_search_vector(UUID, TEXT, TEXT, TEXT);
+DROP INDEX IF EXISTS idx_properties_location_trgm;
+DROP INDEX IF EXISTS idx_properties_title_trgm;
+DROP INDEX IF EXISTS idx_properties_search_vector;
+ALTER TABLE properties DROP COLUMN IF EXISTS search_vector;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- latitude/longitude are the real position and are only shown to the host,
+-- admins and guests with a booking. public_latitude/public_longitude are a
+-- randomly offset copy used for everything else, including search.
+ALTER TABLE properties
+    ADD COLUMN latitude         DOUBLE PRECISION,
+    ADD COLUMN longitude        DOUBLE PRECISION,
+    ADD COLUMN public_latitude  DOUBLE PRECISION,
+    ADD COLUMN public_longitude DOUBLE PRECISION,
+    ADD CONSTRAINT chk_properties_latitude CHECK (latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT chk_properties_longitude CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT chk_properties_public_latitude CHECK (public_latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT chk_properties_public_longitude CHECK (public_longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT chk_properties_coordinates CHECK (
+        (latitude IS NULL) = (longitude IS NULL)
+        AND (latitude IS NULL) = (public_latitude IS NULL)
+        AND (public_latitude IS NULL) = (public_longitude IS NULL)
+    );
+
+CREATE INDEX idx_properties_public_coordinates ON properties (public_latitude, public_longitude)
+    WHERE public_latitude IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_properties_public_coordinates;
+ALTER TABLE properties
+    DROP CONSTRAINT IF EXISTS chk_properties_coordinates,
+    DROP CONSTRAINT IF EXISTS chk_properties_public_longitude,
+    DROP CONSTRAINT IF EXISTS chk_properties_public_latitude,
+    DROP CONSTRAINT IF EXISTS chk_properties_longitude,
+    DROP CONSTRAINT IF EXISTS chk_properties_latitude,
+    DROP COLUMN IF EXISTS public_longitude,
+    DROP COLUMN IF EXISTS public_latitude,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE properties
+    ADD COLUMN property_type   TEXT NOT NULL DEFAULT 'other'
+        CHECK (property_type IN ('apartment', 'house', 'villa', 'cabin', 'cottage', 'condo', 'guesthouse', 'hotel_room', 'other')),
+    ADD COLUMN bedrooms        INTEGER NOT NULL DEFAULT 0 CHECK (bedrooms >= 0),
+    ADD COLUMN beds            INTEGER NOT NULL DEFAULT 0 CHECK (beds >= 0),
+    ADD COLUMN bathrooms       NUMERIC(3, 1) NOT NULL DEFAULT 0 CHECK (bathrooms >= 0),
+    ADD COLUMN check_in_time   TIME NOT NULL DEFAULT '15:00',
+    ADD COLUMN check_out_time  TIME NOT NULL DEFAULT '11:00',
+    ADD COLUMN pets_allowed    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN smoking_allowed BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN events_allowed  BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_properties_property_type ON properties (property_type);
+CREATE INDEX idx_properties_bedrooms ON properties (bedrooms);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_properties_bedrooms;
+DROP INDEX IF EXISTS idx_properties_property_type;
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS events_allowed,
+    DROP COLUMN IF EXISTS smoking_allowed,
+    DROP COLUMN IF EXISTS pets_allowed,
+    DROP COLUMN IF EXISTS check_out_time,
+    DROP COLUMN IF EXISTS check_in_time,
+    DROP COLUMN IF EXISTS bathrooms,
+    DROP COLUMN IF EXISTS beds,
+    DROP COLUMN IF EXISTS bedrooms,
+    DROP COLUMN IF EXISTS property_type;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE properties
+    ADD COLUMN status       TEXT NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'pending_review', 'published', 'suspended', 'archived')),
+    ADD COLUMN submitted_at TIMESTAMP,
+    ADD COLUMN reviewed_at  TIMESTAMP,
+    ADD COLUMN reviewed_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN review_note  TEXT;
+
+-- Existing listings were already public; new ones start as drafts.
+ALTER TABLE properties ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE INDEX idx_properties_status ON properties (status);
+CREATE INDEX idx_properties_pending_review ON properties (submitted_at)
+    WHERE status = 'pending_review';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_properties_pending_review;
+DROP INDEX IF EXISTS idx_properties_status;
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS review_note,
+    DROP COLUMN IF EXISTS reviewed_by,
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS submitted_at,
+    DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
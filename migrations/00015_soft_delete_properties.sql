@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE properties
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_properties_deleted_at ON properties (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+-- Purging a listing must not erase guests' booking history: bookings keep a
+-- copy of the listing's title and location and lose only the reference.
+ALTER TABLE bookings
+    ADD COLUMN property_title    TEXT,
+    ADD COLUMN property_location TEXT,
+    ALTER COLUMN property_id DROP NOT NULL,
+    DROP CONSTRAINT bookings_property_id_fkey;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_property_id_fkey
+        FOREIGN KEY (property_id) REFERENCES properties(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM bookings WHERE property_id IS NULL;
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_property_id_fkey;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_property_id_fkey
+        FOREIGN KEY (property_id) REFERENCES properties(id) ON DELETE CASCADE,
+    ALTER COLUMN property_id SET NOT NULL,
+    DROP COLUMN IF EXISTS property_location,
+    DROP COLUMN IF EXISTS property_title;
+
+DROP INDEX IF EXISTS idx_properties_deleted_at;
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
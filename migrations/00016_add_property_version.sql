@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Incremented on every change to the listing's own fields; exposed as the
+-- ETag for optimistic concurrency.
+ALTER TABLE properties
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE properties DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One row per listing version: the editable fields, images and amenities as
+-- they were once that version was saved.
+CREATE TABLE property_revisions (
+    id             BIGSERIAL PRIMARY KEY,
+    property_id    UUID NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    version        INTEGER NOT NULL,
+    action         TEXT NOT NULL,
+    source_version INTEGER,
+    snapshot       JSONB NOT NULL,
+    created_by     UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (property_id, version)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS property_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,85 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Stay rules that apply unless a dated override covers the check-in date.
+-- check_in_days holds day-of-week numbers (0 = Sunday); NULL allows any day.
+ALTER TABLE properties
+    ADD COLUMN min_nights    INTEGER NOT NULL DEFAULT 1 CHECK (min_nights >= 1),
+    ADD COLUMN max_nights    INTEGER CHECK (max_nights >= 1),
+    ADD COLUMN check_in_days INTEGER[] CHECK (check_in_days <@ ARRAY[0, 1, 2, 3, 4, 5, 6]),
+    ADD CONSTRAINT chk_properties_stay_length CHECK (max_nights IS NULL OR max_nights >= min_nights);
+
+-- Date ranges are half-open: start_date is the first night, end_date the
+-- first night that is not covered.
+CREATE TABLE property_blocked_dates (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    property_id UUID NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    start_date  DATE NOT NULL,
+    end_date    DATE NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    created_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_blocked_date_range CHECK (end_date > start_date)
+);
+
+CREATE INDEX idx_property_blocked_dates_range ON property_blocked_dates
+    USING gist (property_id, daterange(start_date, end_date, '[)'));
+
+-- Overrides of the property's stay rules for check-ins within a date range;
+-- NULL columns inherit the property default.
+CREATE TABLE property_stay_rules (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    property_id   UUID NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    start_date    DATE NOT NULL,
+    end_date      DATE NOT NULL,
+    min_nights    INTEGER CHECK (min_nights >= 1),
+    max_nights    INTEGER CHECK (max_nights >= 1),
+    check_in_days INTEGER[] CHECK (check_in_days <@ ARRAY[0, 1, 2, 3, 4, 5, 6]),
+    CONSTRAINT chk_stay_rule_date_range CHECK (end_date > start_date),
+    CONSTRAINT property_stay_rules_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    )
+);
+
+-- Bookings made before this constraint may already share nights. Rather
+-- than pick which guest loses their stay, refuse to migrate and name the
+-- clashing bookings so they can be moved or cancelled through the API
+-- first, with the usual refunds and notifications.
+DO $$
+DECLARE
+    clashes TEXT;
+BEGIN
+    SELECT string_agg(a.id || ' overlaps ' || b.id, ', ' ORDER BY a.id, b.id)
+    INTO clashes
+    FROM bookings a
+    JOIN bookings b ON b.property_id = a.property_id AND a.id < b.id
+    WHERE a.status = 'booked' AND b.status = 'booked'
+      AND daterange(a.start_date, a.end_date, '[)') && daterange(b.start_date, b.end_date, '[)');
+
+    IF clashes IS NOT NULL THEN
+        RAISE EXCEPTION 'bookings share nights and must be resolved before this migration: %', clashes;
+    END IF;
+END
+$$;
+
+-- Two active bookings can never share a night.
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status = 'booked');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+DROP TABLE IF EXISTS property_stay_rules;
+DROP TABLE IF EXISTS property_blocked_dates;
+ALTER TABLE properties
+    DROP CONSTRAINT IF EXISTS chk_properties_stay_length,
+    DROP COLUMN IF EXISTS check_in_days,
+    DROP COLUMN IF EXISTS max_nights,
+    DROP COLUMN IF EXISTS min_nights;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Secret token of the property's public iCal feed; NULL until first requested.
+ALTER TABLE properties
+    ADD COLUMN ical_token TEXT UNIQUE;
+
+-- External calendars whose events become blocked ranges. Uploaded files
+-- have no url and are never refreshed.
+CREATE TABLE property_calendar_imports (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    property_id    UUID NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    name           TEXT NOT NULL,
+    url            TEXT,
+    event_count    INTEGER NOT NULL DEFAULT 0,
+    conflict_count INTEGER NOT NULL DEFAULT 0,
+    last_synced_at TIMESTAMP,
+    last_error     TEXT,
+    next_sync_at   TIMESTAMP,
+    created_by     UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_property_calendar_imports_property ON property_calendar_imports (property_id);
+CREATE INDEX idx_property_calendar_imports_due ON property_calendar_imports (next_sync_at NULLS FIRST)
+    WHERE url IS NOT NULL;
+
+ALTER TABLE property_blocked_dates
+    ADD COLUMN import_id UUID REFERENCES property_calendar_imports(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_property_blocked_dates_import ON property_blocked_dates (import_id)
+    WHERE import_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE property_blocked_dates DROP COLUMN IF EXISTS import_id;
+DROP TABLE IF EXISTS property_calendar_imports;
+ALTER TABLE properties DROP COLUMN IF EXISTS ical_token;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE properties
+    ADD COLUMN cleaning_fee             NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK (cleaning_fee >= 0),
+    ADD COLUMN extra_guest_fee          NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK (extra_guest_fee >= 0),
+    ADD COLUMN guests_included          INTEGER NOT NULL DEFAULT 1 CHECK (guests_included >= 1),
+    ADD COLUMN weekly_discount_percent  NUMERIC(5, 2) NOT NULL DEFAULT 0
+        CHECK (weekly_discount_percent BETWEEN 0 AND 100),
+    ADD COLUMN monthly_discount_percent NUMERIC(5, 2) NOT NULL DEFAULT 0
+        CHECK (monthly_discount_percent BETWEEN 0 AND 100);
+
+-- Nightly rates replacing price_per_night for nights in a date range
+-- (seasonal) and/or on given weekdays (0 = Sunday). The most specific match
+-- wins; position breaks ties, higher first.
+CREATE TABLE property_rate_rules (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    property_id     UUID NOT NULL REFERENCES properties(id) ON DELETE CASCADE,
+    position        INTEGER NOT NULL,
+    name            TEXT NOT NULL DEFAULT '',
+    start_date      DATE,
+    end_date        DATE,
+    weekdays        INTEGER[] CHECK (weekdays <@ ARRAY[0, 1, 2, 3, 4, 5, 6]),
+    price_per_night NUMERIC(10, 2) NOT NULL CHECK (price_per_night >= 0),
+    CONSTRAINT uq_property_rate_rules_position UNIQUE (property_id, position),
+    CONSTRAINT chk_rate_rule_range CHECK ((start_date IS NULL) = (end_date IS NULL) AND (end_date IS NULL OR end_date > start_date)),
+    CONSTRAINT chk_rate_rule_scope CHECK (start_date IS NOT NULL OR weekdays IS NOT NULL)
+);
+
+-- The quote a booking was priced with.
+ALTER TABLE bookings
+    ADD COLUMN guests          INTEGER NOT NULL DEFAULT 1 CHECK (guests >= 1),
+    ADD COLUMN price_breakdown JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS price_breakdown,
+    DROP COLUMN IF EXISTS guests;
+DROP TABLE IF EXISTS property_rate_rules;
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS monthly_discount_percent,
+    DROP COLUMN IF EXISTS weekly_discount_percent,
+    DROP COLUMN IF EXISTS guests_included,
+    DROP COLUMN IF EXISTS extra_guest_fee,
+    DROP COLUMN IF EXISTS cleaning_fee;
+-- +goose StatementEnd
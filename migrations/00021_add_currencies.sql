@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- ISO 4217 currency of a listing's price, fees and rate rules. Existing
+-- listings were priced in dollars.
+ALTER TABLE properties
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+
+-- total_price is in currency, the listing's at booking time. When the guest
+-- booked in another currency, the rate used and the converted total are
+-- locked here so later rate changes do not change what they were shown.
+ALTER TABLE bookings
+    ADD COLUMN currency         CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$'),
+    ADD COLUMN display_currency CHAR(3) CHECK (display_currency ~ '^[A-Z]{3}$'),
+    ADD COLUMN display_total    NUMERIC(12, 2) CHECK (display_total >= 0),
+    ADD COLUMN fx_rate          NUMERIC(18, 8) CHECK (fx_rate > 0),
+    ADD COLUMN fx_rate_as_of    TIMESTAMP,
+    ADD CONSTRAINT chk_bookings_display CHECK (
+        (display_currency IS NULL) = (display_total IS NULL)
+        AND (display_currency IS NULL) = (fx_rate IS NULL)
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS chk_bookings_display,
+    DROP COLUMN IF EXISTS fx_rate_as_of,
+    DROP COLUMN IF EXISTS fx_rate,
+    DROP COLUMN IF EXISTS display_total,
+    DROP COLUMN IF EXISTS display_currency,
+    DROP COLUMN IF EXISTS currency;
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS currency;
+-- +goose StatementEnd
@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Where a listing is for tax purposes. Country is ISO 3166-1 alpha-2; an
+-- empty country matches no tax rules.
+ALTER TABLE properties
+    ADD COLUMN country TEXT NOT NULL DEFAULT '' CHECK (country ~ '^([A-Z]{2})?$'),
+    ADD COLUMN region  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN city    TEXT NOT NULL DEFAULT '';
+
+-- Occupancy and sales taxes. A rule applies to listings in its country and,
+-- when set, region and city (compared case-insensitively); every matching
+-- rule is charged. Percentage rules tax the stay's price, per_night rules
+-- charge a flat amount for each night, both only for nights from
+-- effective_from up to effective_to.
+CREATE TABLE tax_rules (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name           TEXT NOT NULL,
+    country        TEXT NOT NULL CHECK (country ~ '^[A-Z]{2}$'),
+    region         TEXT NOT NULL DEFAULT '',
+    city           TEXT NOT NULL DEFAULT '',
+    kind           TEXT NOT NULL CHECK (kind IN ('percentage', 'per_night')),
+    percent        NUMERIC(6, 3) CHECK (percent > 0 AND percent <= 100),
+    amount         NUMERIC(10, 2) CHECK (amount > 0),
+    currency       CHAR(3) CHECK (currency ~ '^[A-Z]{3}$'),
+    effective_from DATE NOT NULL,
+    effective_to   DATE,
+    created_by     UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_tax_rules_kind CHECK (
+        (kind = 'percentage' AND percent IS NOT NULL AND amount IS NULL AND currency IS NULL)
+        OR (kind = 'per_night' AND percent IS NULL AND amount IS NOT NULL AND currency IS NOT NULL)
+    ),
+    CONSTRAINT chk_tax_rules_dates CHECK (effective_to IS NULL OR effective_to > effective_from)
+);
+
+CREATE INDEX idx_tax_rules_country ON tax_rules (country);
+
+-- Taxes charged on a booking, copied from the rules at booking time and in
+-- the booking's currency, so revenue and tax can be reported apart.
+ALTER TABLE bookings
+    ADD COLUMN tax_total NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (tax_total >= 0);
+
+CREATE TABLE booking_taxes (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id  UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    tax_rule_id UUID REFERENCES tax_rules(id) ON DELETE SET NULL,
+    name        TEXT NOT NULL,
+    country     TEXT NOT NULL,
+    region      TEXT NOT NULL DEFAULT '',
+    city        TEXT NOT NULL DEFAULT '',
+    kind        TEXT NOT NULL,
+    percent     NUMERIC(6, 3),
+    nights      INTEGER NOT NULL,
+    amount      NUMERIC(12, 2) NOT NULL CHECK (amount >= 0)
+);
+
+CREATE INDEX idx_booking_taxes_booking ON booking_taxes (booking_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS booking_taxes;
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS tax_total;
+DROP TABLE IF EXISTS tax_rules;
+ALTER TABLE properties
+    DROP COLUMN IF EXISTS city,
+    DROP COLUMN IF EXISTS region,
+    DROP COLUMN IF EXISTS country;
+-- +goose StatementEnd
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Promo codes. Codes are stored upper-case and matched case-insensitively.
+-- A coupon scoped to a property or host only applies to that listing or
+-- that host's listings. Percentage coupons take percent off the nightly
+-- subtotal after length-of-stay discounts; fixed ones take amount off it,
+-- converted into the listing's currency.
+CREATE TABLE coupons (
+    id                        UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code                      TEXT NOT NULL UNIQUE CHECK (code ~ '^[A-Z0-9_-]{3,32}$'),
+    description               TEXT NOT NULL DEFAULT '',
+    kind                      TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed')),
+    percent                   NUMERIC(6, 3) CHECK (percent > 0 AND percent <= 100),
+    amount                    NUMERIC(10, 2) CHECK (amount > 0),
+    currency                  CHAR(3) CHECK (currency ~ '^[A-Z]{3}$'),
+    valid_from                TIMESTAMP NOT NULL DEFAULT NOW(),
+    valid_until               TIMESTAMP,
+    max_redemptions           INTEGER CHECK (max_redemptions > 0),
+    max_redemptions_per_user  INTEGER CHECK (max_redemptions_per_user > 0),
+    min_nights                INTEGER NOT NULL DEFAULT 1 CHECK (min_nights >= 1),
+    property_id               UUID REFERENCES properties(id) ON DELETE CASCADE,
+    host_id                   UUID REFERENCES users(id) ON DELETE CASCADE,
+    active                    BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Kept in step with coupon_redemptions; updating it locks the coupon
+    -- row, which serialises redemptions of a code.
+    redemption_count          INTEGER NOT NULL DEFAULT 0 CHECK (redemption_count >= 0),
+    created_by                UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at                TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at                TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_coupons_kind CHECK (
+        (kind = 'percentage' AND percent IS NOT NULL AND amount IS NULL AND currency IS NULL)
+        OR (kind = 'fixed' AND percent IS NULL AND amount IS NOT NULL AND currency IS NOT NULL)
+    ),
+    CONSTRAINT chk_coupons_validity CHECK (valid_until IS NULL OR valid_until > valid_from),
+    CONSTRAINT chk_coupons_scope CHECK (property_id IS NULL OR host_id IS NULL)
+);
+
+ALTER TABLE bookings
+    ADD COLUMN coupon_id       UUID REFERENCES coupons(id) ON DELETE SET NULL,
+    ADD COLUMN coupon_code     TEXT,
+    ADD COLUMN coupon_discount NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (coupon_discount >= 0);
+
+-- One row per booking a coupon was used on, while the booking stands.
+CREATE TABLE coupon_redemptions (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    coupon_id  UUID NOT NULL REFERENCES coupons(id) ON DELETE CASCADE,
+    booking_id UUID NOT NULL UNIQUE REFERENCES bookings(id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    discount   NUMERIC(12, 2) NOT NULL,
+    currency   CHAR(3) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_coupon_redemptions_coupon_user ON coupon_redemptions (coupon_id, user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS coupon_redemptions;
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS coupon_discount,
+    DROP COLUMN IF EXISTS coupon_code,
+    DROP COLUMN IF EXISTS coupon_id;
+DROP TABLE IF EXISTS coupons;
+-- +goose StatementEnd
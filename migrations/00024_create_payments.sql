@@ -0,0 +1,91 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Bookings now wait for payment before they are confirmed. Existing
+-- bookings were taken without payment and count as confirmed.
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+
+UPDATE bookings SET status = 'confirmed' WHERE status = 'booked' OR status IS NULL;
+
+ALTER TABLE bookings
+    ALTER COLUMN status SET DEFAULT 'pending_payment',
+    ALTER COLUMN status SET NOT NULL,
+    ADD CONSTRAINT bookings_status_check
+        CHECK (status IN ('pending_payment', 'confirmed', 'payment_failed', 'cancelled'));
+
+-- A booking waiting for payment holds its nights like a confirmed one.
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status IN ('pending_payment', 'confirmed'));
+
+-- One row per attempt to charge a booking. amount and currency are what the
+-- guest was shown; provider_ref is the gateway's authorization id.
+CREATE TABLE payments (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id      UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    provider        TEXT NOT NULL,
+    provider_ref    TEXT,
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN (
+        'pending', 'authorized', 'captured', 'failed', 'expired', 'partially_refunded', 'refunded'
+    )),
+    amount          NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+    currency        CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    refunded_amount NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (refunded_amount >= 0 AND refunded_amount <= amount),
+    failure_reason  TEXT,
+    -- payments not captured by then are expired and their booking released
+    expires_at      TIMESTAMP NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_payments_provider_ref UNIQUE (provider, provider_ref)
+);
+
+CREATE INDEX idx_payments_booking ON payments (booking_id);
+CREATE INDEX idx_payments_open ON payments (expires_at) WHERE status IN ('pending', 'authorized');
+
+CREATE TABLE payment_refunds (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id   UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    provider_ref TEXT NOT NULL,
+    amount       NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    reason       TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_payment_refunds_payment ON payment_refunds (payment_id);
+
+-- Webhook deliveries already handled, so gateway retries are ignored.
+CREATE TABLE payment_events (
+    provider    TEXT NOT NULL,
+    event_id    TEXT NOT NULL,
+    type        TEXT NOT NULL,
+    payload     JSONB NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS payment_events;
+DROP TABLE IF EXISTS payment_refunds;
+DROP TABLE IF EXISTS payments;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+
+DELETE FROM bookings WHERE status IN ('pending_payment', 'payment_failed');
+UPDATE bookings SET status = 'booked' WHERE status = 'confirmed';
+
+ALTER TABLE bookings
+    ALTER COLUMN status DROP NOT NULL,
+    ALTER COLUMN status SET DEFAULT 'booked',
+    ADD CONSTRAINT bookings_status_check CHECK (status IN ('booked', 'cancelled'));
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status = 'booked');
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE properties
+    ADD COLUMN cancellation_policy TEXT NOT NULL DEFAULT 'moderate'
+        CHECK (cancellation_policy IN ('flexible', 'moderate', 'strict', 'non_refundable'));
+
+-- Bookings keep the policy they were made under, and the refund worked out
+-- when they were cancelled.
+ALTER TABLE bookings
+    ADD COLUMN cancellation_policy TEXT NOT NULL DEFAULT 'moderate'
+        CHECK (cancellation_policy IN ('flexible', 'moderate', 'strict', 'non_refundable')),
+    ADD COLUMN cancelled_at        TIMESTAMP,
+    ADD COLUMN cancelled_by        TEXT CHECK (cancelled_by IN ('guest', 'host')),
+    ADD COLUMN refund_amount       NUMERIC(12, 2) CHECK (refund_amount >= 0),
+    ADD COLUMN refund_breakdown    JSONB;
+
+-- What hosts are charged for cancelling guests' bookings.
+CREATE TABLE host_cancellation_penalties (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id  UUID NOT NULL UNIQUE REFERENCES bookings(id) ON DELETE CASCADE,
+    host_id     UUID REFERENCES users(id) ON DELETE SET NULL,
+    property_id UUID REFERENCES properties(id) ON DELETE SET NULL,
+    reason      TEXT NOT NULL,
+    amount      NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+    currency    CHAR(3) NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_host_cancellation_penalties_host ON host_cancellation_penalties (host_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS host_cancellation_penalties;
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS refund_breakdown,
+    DROP COLUMN IF EXISTS refund_amount,
+    DROP COLUMN IF EXISTS cancelled_by,
+    DROP COLUMN IF EXISTS cancelled_at,
+    DROP COLUMN IF EXISTS cancellation_policy;
+ALTER TABLE properties DROP COLUMN IF EXISTS cancellation_policy;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Each requested change to a booking's dates or guest count, with the stay
+-- before and after and what it does to the price. The new quote is priced
+-- when the change is requested and applied as is.
+CREATE TABLE booking_modifications (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id        UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    requested_by      UUID REFERENCES users(id) ON DELETE SET NULL,
+    status            TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'applied', 'declined', 'payment_failed')),
+    requires_approval BOOLEAN NOT NULL,
+    old_start_date    DATE NOT NULL,
+    old_end_date      DATE NOT NULL,
+    old_guests        INTEGER NOT NULL,
+    new_start_date    DATE NOT NULL,
+    new_end_date      DATE NOT NULL,
+    new_guests        INTEGER NOT NULL CHECK (new_guests >= 1),
+    old_total         NUMERIC(12, 2) NOT NULL,
+    new_total         NUMERIC(12, 2) NOT NULL,
+    price_delta       NUMERIC(12, 2) NOT NULL,
+    currency          CHAR(3) NOT NULL,
+    -- the difference in the currency the guest pays in, at the booking's
+    -- locked exchange rate
+    payment_delta     NUMERIC(12, 2) NOT NULL,
+    payment_currency  CHAR(3) NOT NULL,
+    price_breakdown   JSONB NOT NULL,
+    -- gateway token charged for a price increase once the change is approved
+    payment_method    TEXT NOT NULL DEFAULT '',
+    decided_by        UUID REFERENCES users(id) ON DELETE SET NULL,
+    decision_reason   TEXT,
+    decided_at        TIMESTAMP,
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (new_end_date > new_start_date)
+);
+
+CREATE INDEX idx_booking_modifications_booking ON booking_modifications (booking_id, created_at);
+
+-- one open request per booking at a time
+CREATE UNIQUE INDEX idx_booking_modifications_pending ON booking_modifications (booking_id)
+    WHERE status = 'pending';
+
+-- Payments collecting a modification's price increase.
+ALTER TABLE payments
+    ADD COLUMN modification_id UUID REFERENCES booking_modifications(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE payments DROP COLUMN IF EXISTS modification_id;
+DROP TABLE IF EXISTS booking_modifications;
+-- +goose StatementEnd
@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Who is coming on a stay. guests stays the number that counts towards
+-- max_guests and the extra-guest fee: adults and children, not infants.
+ALTER TABLE bookings
+    ADD COLUMN adults   INTEGER NOT NULL DEFAULT 1 CHECK (adults >= 1),
+    ADD COLUMN children INTEGER NOT NULL DEFAULT 0 CHECK (children >= 0),
+    ADD COLUMN infants  INTEGER NOT NULL DEFAULT 0 CHECK (infants >= 0),
+    ADD COLUMN pets     INTEGER NOT NULL DEFAULT 0 CHECK (pets >= 0);
+
+UPDATE bookings SET adults = guests;
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_guests_party CHECK (guests = adults + children);
+
+ALTER TABLE booking_modifications
+    ADD COLUMN old_adults   INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN old_children INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN old_infants  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN old_pets     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN new_adults   INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN new_children INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN new_infants  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN new_pets     INTEGER NOT NULL DEFAULT 0;
+
+UPDATE booking_modifications SET old_adults = old_guests, new_adults = new_guests;
+
+-- Optional names of the rest of the party; the guest who booked is not
+-- listed.
+CREATE TABLE booking_guests (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    first_name TEXT NOT NULL,
+    last_name  TEXT NOT NULL DEFAULT '',
+    age_group  TEXT NOT NULL CHECK (age_group IN ('adult', 'child', 'infant')),
+    UNIQUE (booking_id, position)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS booking_guests;
+ALTER TABLE booking_modifications
+    DROP COLUMN IF EXISTS new_pets,
+    DROP COLUMN IF EXISTS new_infants,
+    DROP COLUMN IF EXISTS new_children,
+    DROP COLUMN IF EXISTS new_adults,
+    DROP COLUMN IF EXISTS old_pets,
+    DROP COLUMN IF EXISTS old_infants,
+    DROP COLUMN IF EXISTS old_children,
+    DROP COLUMN IF EXISTS old_adults;
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_guests_party,
+    DROP COLUMN IF EXISTS pets,
+    DROP COLUMN IF EXISTS infants,
+    DROP COLUMN IF EXISTS children,
+    DROP COLUMN IF EXISTS adults;
+-- +goose StatementEnd
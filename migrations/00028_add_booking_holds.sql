@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A hold is a booking that keeps its dates for a few minutes while the
+-- guest checks out, priced but not yet paid for. It becomes a booking
+-- waiting for payment when confirmed, or gives its dates back when it
+-- expires or the guest lets it go.
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_status_check CHECK (status IN (
+        'held', 'hold_expired', 'hold_released', 'pending_payment', 'confirmed', 'payment_failed', 'cancelled'
+    )),
+    -- set on bookings that started as a hold, and kept once it is confirmed
+    ADD COLUMN hold_expires_at   TIMESTAMP,
+    ADD COLUMN hold_converted_at TIMESTAMP,
+    ADD CONSTRAINT bookings_hold_expiry CHECK (status <> 'held' OR hold_expires_at IS NOT NULL);
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status IN ('held', 'pending_payment', 'confirmed'));
+
+CREATE INDEX idx_bookings_held ON bookings (hold_expires_at) WHERE status = 'held';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+DROP INDEX IF EXISTS idx_bookings_held;
+
+UPDATE bookings SET status = 'payment_failed' WHERE status IN ('held', 'hold_expired', 'hold_released');
+
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_hold_expiry,
+    DROP COLUMN IF EXISTS hold_converted_at,
+    DROP COLUMN IF EXISTS hold_expires_at,
+    ADD CONSTRAINT bookings_status_check
+        CHECK (status IN ('pending_payment', 'confirmed', 'payment_failed', 'cancelled'));
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status IN ('pending_payment', 'confirmed'));
+-- +goose StatementEnd
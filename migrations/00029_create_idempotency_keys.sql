@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Responses to mutating requests sent with an Idempotency-Key header, so a
+-- retried request gets the first response back instead of running twice.
+-- status_code is NULL while the first request is still being handled.
+CREATE TABLE idempotency_keys (
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key           TEXT NOT NULL,
+    -- hash of the method, path, query, display currency and body
+    fingerprint   TEXT NOT NULL,
+    status_code   INTEGER,
+    content_type  TEXT,
+    response_body BYTEA,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
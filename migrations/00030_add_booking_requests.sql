@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Listings are booked instantly or on request. A request is a booking that
+-- keeps its dates while the host decides, until respond_by; the guest is
+-- charged with the stored payment method once the host accepts.
+ALTER TABLE properties
+    ADD COLUMN booking_mode TEXT NOT NULL DEFAULT 'instant'
+        CHECK (booking_mode IN ('instant', 'request'));
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_status_check CHECK (status IN (
+        'held', 'hold_expired', 'hold_released', 'requested', 'declined', 'request_expired',
+        'pending_payment', 'confirmed', 'payment_failed', 'cancelled'
+    )),
+    -- set on bookings that started as a request
+    ADD COLUMN respond_by             TIMESTAMP,
+    ADD COLUMN host_responded_at      TIMESTAMP,
+    ADD COLUMN decline_reason         TEXT,
+    -- gateway token charged when the host accepts; cleared once decided
+    ADD COLUMN request_payment_method TEXT,
+    ADD CONSTRAINT bookings_request_deadline CHECK (status <> 'requested' OR respond_by IS NOT NULL);
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status IN ('held', 'requested', 'pending_payment', 'confirmed'));
+
+CREATE INDEX idx_bookings_requested ON bookings (respond_by) WHERE status = 'requested';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+DROP INDEX IF EXISTS idx_bookings_requested;
+
+UPDATE bookings SET status = 'cancelled' WHERE status IN ('requested', 'declined', 'request_expired');
+
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_request_deadline,
+    DROP COLUMN IF EXISTS request_payment_method,
+    DROP COLUMN IF EXISTS decline_reason,
+    DROP COLUMN IF EXISTS host_responded_at,
+    DROP COLUMN IF EXISTS respond_by,
+    ADD CONSTRAINT bookings_status_check CHECK (status IN (
+        'held', 'hold_expired', 'hold_released', 'pending_payment', 'confirmed', 'payment_failed', 'cancelled'
+    ));
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        property_id WITH =,
+        daterange(start_date, end_date, '[)') WITH &&
+    ) WHERE (status IN ('held', 'pending_payment', 'confirmed'));
+
+ALTER TABLE properties DROP COLUMN IF EXISTS booking_mode;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- What a cancellation owes the guest is recorded on each captured payment
+-- with the cancellation, then refunded through the gateway. A refund the
+-- gateway does not accept keeps its refund_due and refund_error, and is
+-- tried again from refund_retry_at.
+ALTER TABLE payments
+    ADD COLUMN refund_due      NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (refund_due >= 0),
+    ADD COLUMN refund_reason   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN refund_error    TEXT,
+    ADD COLUMN refund_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN refund_retry_at TIMESTAMP,
+    ADD CONSTRAINT payments_refund_due_within_amount CHECK (refunded_amount + refund_due <= amount);
+
+CREATE INDEX idx_payments_refund_due ON payments (refund_retry_at) WHERE refund_due > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_payments_refund_due;
+
+ALTER TABLE payments
+    DROP CONSTRAINT IF EXISTS payments_refund_due_within_amount,
+    DROP COLUMN IF EXISTS refund_retry_at,
+    DROP COLUMN IF EXISTS refund_attempts,
+    DROP COLUMN IF EXISTS refund_error,
+    DROP COLUMN IF EXISTS refund_reason,
+    DROP COLUMN IF EXISTS refund_due;
+-- +goose StatementEnd